@@ -0,0 +1,247 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/cassandra"
+	"github.com/censys/scan-takehome/internal/repositories/repotest"
+	"github.com/censys/scan-takehome/internal/serializer"
+	"github.com/gocql/gocql"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// setupCassandra creates an ephemeral single node Cassandra container and
+// returns a session to a bootstrapped keyspace
+func setupCassandra(t *testing.T) (*gocql.Session, func()) {
+	ctx := context.Background()
+
+	req := testcontainers.ContainerRequest{
+		Image:        "cassandra:4.1",
+		ExposedPorts: []string{"9042/tcp"},
+		Env: map[string]string{
+			"MAX_HEAP_SIZE": "512M",
+			"HEAP_NEWSIZE":  "128M",
+		},
+		WaitingFor: wait.ForLog("Starting listening for CQL clients").WithStartupTimeout(3 * time.Minute),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to start Cassandra container: %v", err)
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get container host: %v", err)
+	}
+
+	port, err := container.MappedPort(ctx, "9042")
+	if err != nil {
+		t.Fatalf("Failed to get container port: %v", err)
+	}
+
+	cluster := gocql.NewCluster(fmt.Sprintf("%s:%s", host, port.Port()))
+	// The node advertises its container address, which isn't reachable
+	// from the host
+	cluster.DisableInitialHostLookup = true
+	cluster.Timeout = 10 * time.Second
+
+	session, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("Failed to connect to Cassandra: %v", err)
+	}
+
+	if err := cassandra.Bootstrap(ctx, &cassandra.BootstrapConfig{Session: session}); err != nil {
+		t.Fatalf("Failed to bootstrap keyspace: %v", err)
+	}
+
+	cleanup := func() {
+		session.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("Failed to terminate container: %v", err)
+		}
+	}
+
+	return session, cleanup
+}
+
+// truncateCassandra empties the scan tables between tests sharing a container
+func truncateCassandra(t *testing.T, session *gocql.Session) {
+	for _, table := range []string{"scans", "scans_by_ip", "scans_by_service", "scans_by_port"} {
+		if err := session.Query(`TRUNCATE ` + cassandra.DefaultKeyspace + `.` + table).Exec(); err != nil {
+			t.Fatalf("Failed to truncate %s: %v", table, err)
+		}
+	}
+}
+
+func TestIntegration_CassandraOutOfOrderMessages(t *testing.T) {
+	session, cleanup := setupCassandra(t)
+	defer cleanup()
+
+	store, err := cassandra.NewCassandra(&cassandra.CassandraConfig{Session: session})
+	if err != nil {
+		t.Fatalf("Failed to create Cassandra store: %v", err)
+	}
+
+	manager, err := scan_manager.NewScanManager(&scan_manager.ScanManagerConfig{Repo: store})
+	if err != nil {
+		t.Fatalf("Failed to create scan manager: %v", err)
+	}
+
+	// First message with newer timestamp
+	jsonData1 := `{
+		"ip": "172.16.0.1",
+		"port": 443,
+		"service": "https",
+		"timestamp": 5000000000,
+		"data_version": 2,
+		"data": {
+			"response_str": "newer response"
+		}
+	}`
+
+	result1, _ := serializer.ParseScanMessage([]byte(jsonData1))
+	_, err = manager.PutScan(context.Background(), result1)
+	if err != nil {
+		t.Fatalf("Failed to put first scan: %v", err)
+	}
+
+	// Second message with older timestamp (should be rejected)
+	jsonData2 := `{
+		"ip": "172.16.0.1",
+		"port": 443,
+		"service": "https",
+		"timestamp": 3000000000,
+		"data_version": 2,
+		"data": {
+			"response_str": "older response"
+		}
+	}`
+
+	result2, _ := serializer.ParseScanMessage([]byte(jsonData2))
+	outcome, err := manager.PutScan(context.Background(), result2)
+	if err != nil {
+		t.Fatalf("Failed to put second scan: %v", err)
+	}
+
+	if outcome != scan_manager.StaleIgnored {
+		t.Errorf("Expected outcome stale_ignored, got %s", outcome)
+	}
+
+	// Redelivering the first message is a duplicate
+	outcome, err = manager.PutScan(context.Background(), result1)
+	if err != nil {
+		t.Fatalf("Failed to redeliver first scan: %v", err)
+	}
+
+	if outcome != scan_manager.Duplicate {
+		t.Errorf("Expected outcome duplicate, got %s", outcome)
+	}
+
+	// Verify only the newer response is stored, in the latest table and
+	// the query tables
+	stored, err := store.Get(context.Background(), result1.Key())
+	if err != nil {
+		t.Fatalf("Failed to get scan: %v", err)
+	}
+
+	if stored.Response != "newer response" {
+		t.Errorf("Expected 'newer response', got '%s' - older message overwrote newer", stored.Response)
+	}
+
+	page, err := store.ListByService(context.Background(), "https", scan_manager.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list scans: %v", err)
+	}
+
+	if len(page.Results) != 1 || page.Results[0].Response != "newer response" {
+		t.Errorf("Expected the newer response to be listed, got %+v", page.Results)
+	}
+}
+
+func TestIntegration_CassandraConformance(t *testing.T) {
+	session, cleanup := setupCassandra(t)
+	defer cleanup()
+
+	repotest.Run(t, func(t *testing.T) scan_manager.Repository {
+		truncateCassandra(t, session)
+
+		store, err := cassandra.NewCassandra(&cassandra.CassandraConfig{Session: session})
+		if err != nil {
+			t.Fatalf("Failed to create Cassandra store: %v", err)
+		}
+
+		return store
+	})
+}
+
+func TestIntegration_CassandraConcurrentWriters(t *testing.T) {
+	session, cleanup := setupCassandra(t)
+	defer cleanup()
+
+	store, err := cassandra.NewCassandra(&cassandra.CassandraConfig{Session: session})
+	if err != nil {
+		t.Fatalf("Failed to create Cassandra store: %v", err)
+	}
+
+	// Every writer races to insert the key first, then to replace it
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	inserted := 0
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(ts int64) {
+			defer wg.Done()
+
+			put, err := store.Put(context.Background(), &scan_manager.ScanResult{
+				IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: ts, Response: fmt.Sprintf("response %d", ts),
+			})
+			if err != nil {
+				t.Errorf("Failed to put scan %d: %v", ts, err)
+				return
+			}
+
+			if put.Outcome == scan_manager.Inserted {
+				mu.Lock()
+				inserted++
+				mu.Unlock()
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	if inserted != 1 {
+		t.Errorf("Expected exactly one insert, got %d", inserted)
+	}
+
+	stored, err := store.Get(context.Background(), scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"})
+	if err != nil {
+		t.Fatalf("Failed to get scan: %v", err)
+	}
+
+	if stored.Timestamp != 20 {
+		t.Errorf("Expected the newest scan to win, got timestamp %d", stored.Timestamp)
+	}
+
+	// The query tables keep the newest copy whatever order they were written in
+	page, err := store.ListByIP(context.Background(), "10.0.0.1", scan_manager.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list scans: %v", err)
+	}
+
+	if len(page.Results) != 1 || page.Results[0].Timestamp != 20 {
+		t.Errorf("Expected the newest scan to be listed, got %+v", page.Results)
+	}
+}
@@ -0,0 +1,48 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/health"
+	"github.com/censys/scan-takehome/internal/metrics"
+)
+
+// startAdminServer serves /metrics, /healthz and /readyz on addr in the
+// background. The returned func shuts the server down. An empty addr
+// disables the server.
+func startAdminServer(addr string, m *metrics.Metrics, h *health.Health, logger *slog.Logger) func() {
+	if addr == "" {
+		return func() {}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m.Handler())
+	mux.Handle("GET /healthz", h.LivenessHandler())
+	mux.Handle("GET /readyz", h.ReadinessHandler())
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Info("admin server listening", slog.String("addr", addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("failed to serve admin endpoints", slog.Any("error", err))
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Error("failed to shut down admin server", slog.Any("error", err))
+		}
+	}
+}
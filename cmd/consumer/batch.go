@@ -0,0 +1,38 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/batch"
+	"github.com/spf13/cobra"
+)
+
+type batchOptions struct {
+	Size  int
+	Delay time.Duration
+}
+
+func (o *batchOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&o.Size, "batch-size", 0, "Group up to this many concurrent writes into one batch, 0 writes each scan on its own")
+	cmd.Flags().DurationVar(&o.Delay, "batch-delay", batch.DefaultMaxDelay, "Longest a write waits for its batch to fill before it is flushed")
+}
+
+// Wrap batches writes to repo when --batch-size is set. The returned close
+// func flushes any pending writes.
+func (o *batchOptions) Wrap(repo scan_manager.Repository) (scan_manager.Repository, func(), error) {
+	if o.Size <= 0 {
+		return repo, func() {}, nil
+	}
+
+	b, err := batch.NewBatcher(&batch.BatcherConfig{
+		Repo:     repo,
+		MaxSize:  o.Size,
+		MaxDelay: o.Delay,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, b.Close, nil
+}
@@ -0,0 +1,89 @@
+package consumer
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/metrics"
+	"github.com/censys/scan-takehome/internal/repositories/breaker"
+	"github.com/censys/scan-takehome/internal/source"
+	"github.com/spf13/cobra"
+)
+
+type breakerOptions struct {
+	Failures    int
+	OpenTimeout time.Duration
+}
+
+func (o *breakerOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&o.Failures, "breaker-failures", breaker.DefaultFailureThreshold, "Consecutive failed writes that open the circuit breaker and pause receiving, 0 disables it")
+	cmd.Flags().DurationVar(&o.OpenTimeout, "breaker-open-timeout", breaker.DefaultOpenTimeout, "How long the circuit breaker stays open before probing the repository")
+}
+
+// gate pauses message receipt while the repository is failing
+type gate interface {
+	// Wait blocks until writes may be attempted again
+	Wait(ctx context.Context) error
+}
+
+// Wrap guards repo with a circuit breaker when --breaker-failures is set.
+// The returned channel is signalled each time the breaker opens. Transitions
+// are logged as warnings, which are never sampled, and exported to m.
+func (o *breakerOptions) Wrap(repo scan_manager.Repository, m *metrics.Metrics, logger *slog.Logger) (scan_manager.Repository, gate, <-chan struct{}, error) {
+	if o.Failures <= 0 {
+		return repo, nil, nil, nil
+	}
+
+	tripped := make(chan struct{}, 1)
+
+	b, err := breaker.NewBreaker(&breaker.BreakerConfig{
+		Repo:             repo,
+		FailureThreshold: o.Failures,
+		OpenTimeout:      o.OpenTimeout,
+		OnStateChange: func(from, to breaker.State) {
+			logger.Warn("circuit breaker changed state",
+				slog.String("from", from.String()),
+				slog.String("to", to.String()),
+			)
+			m.ObserveBreakerState(to)
+
+			if to == breaker.Open {
+				select {
+				case tripped <- struct{}{}:
+				default:
+				}
+			}
+		},
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return b, b, tripped, nil
+}
+
+// receive runs src.Receive until ctx ends, the source is exhausted or
+// tripped is signalled, and reports whether it was paused by tripped.
+// Messages pulled but not yet handled when it stops are redelivered.
+func receive(ctx context.Context, src source.Source, tripped <-chan struct{}, handler func(context.Context, *source.Message)) (bool, error) {
+	receiveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var paused atomic.Bool
+	go func() {
+		select {
+		case <-tripped:
+			paused.Store(true)
+			cancel()
+		case <-receiveCtx.Done():
+		}
+	}()
+
+	err := src.Receive(receiveCtx, handler)
+	cancel()
+
+	return paused.Load() && ctx.Err() == nil, err
+}
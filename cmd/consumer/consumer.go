@@ -2,28 +2,47 @@ package consumer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"sync/atomic"
 	"syscall"
 
 	"cloud.google.com/go/pubsub"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/censys/scan-takehome/cmd/logs"
+	"github.com/censys/scan-takehome/cmd/store"
+	"github.com/censys/scan-takehome/cmd/traces"
+	"github.com/censys/scan-takehome/internal/health"
+	"github.com/censys/scan-takehome/internal/logging"
 	"github.com/censys/scan-takehome/internal/managers/scan_manager"
-	dynamodbstore "github.com/censys/scan-takehome/internal/repositories/dynamodb"
+	"github.com/censys/scan-takehome/internal/metrics"
 	"github.com/censys/scan-takehome/internal/serializer"
+	"github.com/censys/scan-takehome/internal/source"
+	"github.com/censys/scan-takehome/internal/tracing"
+	"github.com/censys/scan-takehome/internal/workload"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
 	projectID      string
-	subscriptionID string
 	numConsumers   int
 	maxOutstanding int
+	adminAddr      string
+	sourceOpts     sourceOptions
+	storeOpts      store.Options
+	logOpts        logs.Options
+	traceOpts      traces.Options
+	deadLetterOpts deadLetterOptions
+	notifierOpts   notifierOptions
+	batchOpts      batchOptions
+	retryOpts      retryOptions
+	breakerOpts    breakerOptions
 )
 
 func NewConsumerCmd() *cobra.Command {
@@ -35,9 +54,18 @@ func NewConsumerCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&projectID, "project", "p", "test-project", "GCP Project ID")
-	cmd.Flags().StringVarP(&subscriptionID, "subscription", "s", "scan-sub", "GCP PubSub Subscription ID")
+	sourceOpts.AddFlags(cmd)
 	cmd.Flags().IntVarP(&numConsumers, "consumers", "c", 10, "Number of concurrent consumers")
 	cmd.Flags().IntVarP(&maxOutstanding, "max-outstanding", "m", 1000, "Max outstanding messages")
+	cmd.Flags().StringVar(&adminAddr, "admin-addr", ":9090", "Address for the admin HTTP server exposing /metrics, /healthz and /readyz, empty disables it")
+	storeOpts.AddFlags(cmd)
+	logOpts.AddFlags(cmd)
+	traceOpts.AddFlags(cmd)
+	deadLetterOpts.AddFlags(cmd)
+	notifierOpts.AddFlags(cmd)
+	batchOpts.AddFlags(cmd)
+	retryOpts.AddFlags(cmd)
+	breakerOpts.AddFlags(cmd)
 
 	return cmd
 }
@@ -47,60 +75,129 @@ func runConsumer(cmd *cobra.Command, args []string) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	fmt.Printf("Starting consumer for project: %s, subscription: %s\n", projectID, subscriptionID)
-	fmt.Printf("Concurrent consumers: %d, Max outstanding messages: %d\n", numConsumers, maxOutstanding)
+	logger, err := logOpts.NewLogger()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing logger: %v\n", err)
+		return
+	}
 
-	// Create DynamoDB client for local development
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion("us-east-1"),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			"dummy", "dummy", "",
-		)),
+	logger.Info("starting consumer",
+		slog.String("project", projectID),
+		slog.String("source", sourceOpts.Type),
+		slog.String("source_name", sourceOpts.Name()),
+		slog.Int("consumers", numConsumers),
+		slog.Int("max_outstanding", maxOutstanding),
+		slog.String("store", storeOpts.Type),
 	)
 
+	shutdownTracing, err := traceOpts.Setup(ctx, "mini-scan-consumer")
 	if err != nil {
-		fmt.Printf("Error loading AWS config: %v\n", err)
+		logger.Error("failed to initialize tracing", slog.Any("error", err))
 		return
 	}
 
-	dynamoClient := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
-		o.BaseEndpoint = aws.String("http://localhost:8000")
-	})
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("failed to flush traces", slog.Any("error", err))
+		}
+	}()
 
-	// Initialize the dynamoDB repository for storing scan results
-	store, err := dynamodbstore.NewDynamoDB(&dynamodbstore.DynamoDBConfig{
-		Client: dynamoClient,
-	})
+	m := metrics.NewMetrics()
+	h := health.NewHealth()
+	stopAdmin := startAdminServer(adminAddr, m, h, logger)
+	defer stopAdmin()
+
+	repo, closeRepo, err := storeOpts.NewRepository(ctx, logger)
+	if err != nil {
+		logger.Error("failed to initialize scanner store", slog.Any("error", err))
+		return
+	}
+
+	defer closeRepo()
 
+	repo, closeBatcher, err := batchOpts.Wrap(repo)
 	if err != nil {
-		fmt.Printf("Error initializing scanner store: %v\n", err)
+		logger.Error("failed to initialize write batching", slog.Any("error", err))
 		return
 	}
 
+	defer closeBatcher()
+
+	// Retries sit above the batcher so a failed batch is retried scan by
+	// scan in later batches
+	repo, err = retryOpts.Wrap(repo, m)
+	if err != nil {
+		logger.Error("failed to initialize write retries", slog.Any("error", err))
+		return
+	}
+
+	// The breaker sits above the retries so it only counts writes that
+	// failed every attempt
+	repo, breakerGate, tripped, err := breakerOpts.Wrap(repo, m, logger)
+	if err != nil {
+		logger.Error("failed to initialize circuit breaker", slog.Any("error", err))
+		return
+	}
+
+	// Instrumented last so write latency covers batching, retries and the breaker
+	repo = m.InstrumentRepository(repo)
+
+	// Replaying a file needs no PubSub client unless a sink or notifier publishes
+	var client *pubsub.Client
+	if sourceOpts.NeedsPubSub() || deadLetterOpts.Topic != "" || notifierOpts.Topic != "" {
+		client, err = pubsub.NewClient(ctx, projectID)
+		if err != nil {
+			logger.Error("failed to create PubSub client", slog.Any("error", err))
+			return
+		}
+
+		defer client.Close()
+	}
+
+	changeNotifier, closeNotifier, err := notifierOpts.NewNotifier(client)
+	if err != nil {
+		logger.Error("failed to initialize change notifier", slog.Any("error", err))
+		return
+	}
+
+	defer closeNotifier()
+
 	// Initialize scan manager with store as repository
 	manager, err := scan_manager.NewScanManager(&scan_manager.ScanManagerConfig{
-		Repo: store,
+		Repo:          repo,
+		Notifier:      changeNotifier,
+		NotifyTimeout: notifierOpts.Timeout,
+		Logger:        logger,
 	})
 
 	if err != nil {
-		fmt.Printf("Error initializing scan manager: %v\n", err)
+		logger.Error("failed to initialize scan manager", slog.Any("error", err))
 		return
 	}
 
-	client, err := pubsub.NewClient(ctx, projectID)
+	sink, err := deadLetterOpts.NewSink(client)
 	if err != nil {
-		fmt.Printf("Error creating PubSub client: %v\n", err)
+		logger.Error("failed to initialize dead-letter sink", slog.Any("error", err))
 		return
 	}
 
-	defer client.Close()
+	if sink != nil {
+		defer sink.Close()
+	}
+
+	src, err := sourceOpts.NewSource(client, numConsumers, maxOutstanding)
+	if err != nil {
+		logger.Error("failed to initialize message source", slog.Any("error", err))
+		return
+	}
 
-	sub := client.Subscription(subscriptionID)
+	defer src.Close()
 
-	sub.ReceiveSettings.NumGoroutines = numConsumers
-	sub.ReceiveSettings.MaxOutstandingMessages = maxOutstanding
+	h.AddCheck("repository", repo.Ping)
+	h.AddCheck("source", src.Ping)
 
-	var processed, failed atomic.Int64
+	var processed, failed, deadLettered, dropped atomic.Int64
+	var outcomes outcomeCounts
 
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
@@ -108,35 +205,148 @@ func runConsumer(cmd *cobra.Command, args []string) {
 
 	go func() {
 		<-sigChan
-		fmt.Println("\nReceived shutdown signal, stopping consumer...")
+		logger.Info("received shutdown signal, stopping consumer")
+
+		// Report not ready while in-flight messages drain
+		h.SetReady(false)
 		cancel()
 	}()
 
-	fmt.Println("Consumer started, waiting for messages...")
-	err = sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
-		result, err := serializer.ParseScanMessage(msg.Data)
+	// permanentFailure dead-letters a message redelivery can't fix
+	permanentFailure := func(ctx context.Context, msg *source.Message, err error) {
+		switch deadLetter(ctx, logger, sink, msg, err) {
+		case letterSent:
+			deadLettered.Add(1)
+			m.ObserveDeadLetter()
+		case letterDropped:
+			dropped.Add(1)
+			m.ObserveDropped()
+		}
+	}
+
+	handler := func(ctx context.Context, msg *source.Message) {
+		// Continue the trace the scanner started when publishing
+		ctx, span := tracer.Start(tracing.Extract(ctx, msg.Attributes), "process "+sourceOpts.Name(),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(sourceOpts.spanAttributes()...),
+			trace.WithAttributes(
+				semconv.MessagingOperationTypeProcess,
+				semconv.MessagingMessageID(msg.ID),
+			),
+		)
+		defer span.End()
+
+		// Everything logged while handling the message carries its ID, and
+		// the fault the scanner injected into it, if any
+		ctx = logging.WithAttrs(ctx, slog.String("message_id", msg.ID))
+		if fault := msg.Attributes[workload.FaultAttribute]; fault != "" {
+			ctx = logging.WithAttrs(ctx, slog.String("fault", fault))
+			span.SetAttributes(attribute.String("scan.fault", fault))
+		}
+
+		result, err := parseScanMessage(ctx, msg.Data)
 		if err != nil {
-			fmt.Printf("Error parsing scan message: %v\n", err)
+			span.SetStatus(codes.Error, "failed to parse scan message")
+			logger.WarnContext(ctx, "failed to parse scan message", slog.Any("error", err))
 			failed.Add(1)
+			m.ObserveResult(metrics.ResultParseError)
+
+			// Invalid messages will fail the same way on every redelivery, so
+			// hand them to the dead-letter sink instead of nacking forever
+			if errors.Is(err, serializer.ErrInvalidMessage) {
+				permanentFailure(ctx, msg, err)
+				return
+			}
+
 			msg.Nack()
 			return
 		}
 
-		if err := manager.PutScan(ctx, result); err != nil {
-			fmt.Printf("Error storing scan: %v\n", err)
+		m.ObserveScan(result)
+
+		// Transient repository errors are retried within the message
+		// timeout, then left to redelivery
+		putCtx, cancelPut := context.WithTimeout(ctx, retryOpts.MessageTimeout)
+		outcome, err := manager.PutScan(putCtx, result)
+		cancelPut()
+		if err != nil {
+			span.SetStatus(codes.Error, "failed to store scan")
+			logger.ErrorContext(ctx, "failed to store scan",
+				slog.String("key", result.Key().String()),
+				slog.Any("error", err),
+			)
 			failed.Add(1)
+			m.ObserveResult(metrics.ResultRepoError)
+
+			// The store rejected the scan itself, redelivery can't fix it
+			if errors.Is(err, scan_manager.ErrInvalidScan) {
+				permanentFailure(ctx, msg, err)
+				return
+			}
+
 			msg.Nack()
 			return
 		}
 
+		// Stale and duplicate scans are expected with out-of-order delivery
+		// and are acked like stored ones, redelivering them can't change the result
+		outcomes.Add(outcome)
 		processed.Add(1)
+		m.ObserveResult(outcome.String())
+		if !msg.PublishTime.IsZero() {
+			m.ObserveEndToEnd(msg.PublishTime)
+		}
 		msg.Ack()
-	})
+	}
 
-	if err != nil && err != context.Canceled {
-		fmt.Printf("Error receiving messages: %v\n", err)
-		return
+	logger.Info("consumer started, waiting for messages")
+	for {
+		h.SetReady(true)
+		paused, err := receive(ctx, src, tripped, handler)
+		if err != nil && err != context.Canceled {
+			logger.Error("failed to receive messages", slog.Any("error", err))
+			return
+		}
+
+		// Stopped by shutdown, or a file source ran out of messages
+		if !paused {
+			break
+		}
+
+		// The breaker opened. Stop pulling messages that would only fail
+		// until it lets a probe through.
+		logger.Warn("repository failing, pausing message receipt")
+		h.SetReady(false)
+		if err := breakerGate.Wait(ctx); err != nil {
+			break
+		}
+		logger.Warn("resuming message receipt")
+	}
+
+	logger.Info("consumer stopped",
+		slog.Int64("processed", processed.Load()),
+		slog.Any("outcomes", &outcomes),
+		slog.Int64("failed", failed.Load()),
+		slog.Int64("dead_lettered", deadLettered.Load()),
+		slog.Int64("dropped", dropped.Load()),
+	)
+}
+
+// outcomeCounts tallies put outcomes, indexed by scan_manager.PutOutcome
+type outcomeCounts [scan_manager.Duplicate + 1]atomic.Int64
+
+func (c *outcomeCounts) Add(outcome scan_manager.PutOutcome) {
+	if outcome > 0 && int(outcome) < len(c) {
+		c[outcome].Add(1)
+	}
+}
+
+// LogValue reports the tallies as a group keyed by outcome
+func (c *outcomeCounts) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(c)-1)
+	for outcome := scan_manager.Inserted; int(outcome) < len(c); outcome++ {
+		attrs = append(attrs, slog.Int64(outcome.String(), c[outcome].Load()))
 	}
 
-	fmt.Printf("\nConsumer stopped. Final stats - Processed: %d, Failed: %d\n", processed.Load(), failed.Load())
+	return slog.GroupValue(attrs...)
 }
@@ -0,0 +1,82 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/censys/scan-takehome/internal/deadletter"
+	"github.com/censys/scan-takehome/internal/source"
+	"github.com/spf13/cobra"
+)
+
+type deadLetterOptions struct {
+	Topic string
+	File  string
+}
+
+func (o *deadLetterOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Topic, "dead-letter-topic", "", "PubSub topic for messages that can't be parsed or that the store rejects as invalid, unset acks and drops them")
+	cmd.Flags().StringVar(&o.File, "dead-letter-file", "", "JSONL file for messages that can't be parsed or that the store rejects as invalid, unset acks and drops them")
+}
+
+// NewSink builds the configured dead-letter sink. It returns a nil sink when
+// neither flag is set, in which case invalid messages are logged and dropped.
+func (o *deadLetterOptions) NewSink(client *pubsub.Client) (deadletter.Sink, error) {
+	switch {
+	case o.Topic != "" && o.File != "":
+		return nil, errors.New("only one of --dead-letter-topic and --dead-letter-file can be set")
+	case o.Topic != "":
+		return deadletter.NewPubSubSink(&deadletter.PubSubSinkConfig{
+			Topic: client.Topic(o.Topic),
+		})
+	case o.File != "":
+		return deadletter.NewFileSink(&deadletter.FileSinkConfig{
+			Path: o.File,
+		})
+	default:
+		return nil, nil
+	}
+}
+
+// letterResult is what deadLetter did with a message
+type letterResult int
+
+const (
+	// letterSent was handed to the sink and acked
+	letterSent letterResult = iota
+	// letterDropped was acked without a sink, redelivering it can't help
+	letterDropped
+	// letterNacked couldn't be recorded by the sink and is left to redelivery
+	letterNacked
+)
+
+// deadLetter records a permanently failed message and acks it. The message is
+// only nacked if the sink can't record it, so nothing is lost once a sink is
+// configured. Without one it is dropped with a warning.
+func deadLetter(ctx context.Context, logger *slog.Logger, sink deadletter.Sink, msg *source.Message, cause error) letterResult {
+	if sink == nil {
+		logger.WarnContext(ctx, "dropping invalid message, no dead-letter sink configured", slog.Any("error", cause))
+		msg.Ack()
+		return letterDropped
+	}
+
+	err := sink.Send(ctx, &deadletter.Letter{
+		MessageID:   msg.ID,
+		Data:        msg.Data,
+		Attributes:  msg.Attributes,
+		PublishTime: msg.PublishTime,
+		Error:       cause.Error(),
+		FailedAt:    time.Now(),
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to dead-letter message", slog.Any("error", err))
+		msg.Nack()
+		return letterNacked
+	}
+
+	msg.Ack()
+	return letterSent
+}
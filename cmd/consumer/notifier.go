@@ -0,0 +1,61 @@
+package consumer
+
+import (
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/notifier"
+	"github.com/spf13/cobra"
+)
+
+type notifierOptions struct {
+	Topic   string
+	Webhook string
+	Timeout time.Duration
+}
+
+func (o *notifierOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Topic, "change-topic", "", "PubSub topic for service change events")
+	cmd.Flags().StringVar(&o.Webhook, "change-webhook", "", "URL to POST service change events to")
+	cmd.Flags().DurationVar(&o.Timeout, "change-timeout", scan_manager.DefaultNotifyTimeout, "Deadline for sending a change event, separate from --message-timeout")
+}
+
+// NewNotifier builds the configured change notifiers. It returns nil when no
+// notifier is configured, along with a cleanup func that is always safe to call.
+func (o *notifierOptions) NewNotifier(client *pubsub.Client) (scan_manager.Notifier, func(), error) {
+	var notifiers []scan_manager.Notifier
+	cleanup := func() {}
+
+	if o.Topic != "" {
+		n, err := notifier.NewPubSubNotifier(&notifier.PubSubNotifierConfig{
+			Topic: client.Topic(o.Topic),
+		})
+		if err != nil {
+			return nil, cleanup, err
+		}
+
+		notifiers = append(notifiers, n)
+		cleanup = func() { n.Close() }
+	}
+
+	if o.Webhook != "" {
+		n, err := notifier.NewWebhookNotifier(&notifier.WebhookNotifierConfig{
+			URL: o.Webhook,
+		})
+		if err != nil {
+			return nil, cleanup, err
+		}
+
+		notifiers = append(notifiers, n)
+	}
+
+	switch len(notifiers) {
+	case 0:
+		return nil, cleanup, nil
+	case 1:
+		return notifiers[0], cleanup, nil
+	default:
+		return notifier.Multi(notifiers...), cleanup, nil
+	}
+}
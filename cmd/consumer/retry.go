@@ -0,0 +1,40 @@
+package consumer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/metrics"
+	"github.com/censys/scan-takehome/internal/repositories/retry"
+	"github.com/spf13/cobra"
+)
+
+type retryOptions struct {
+	Attempts       int
+	MessageTimeout time.Duration
+}
+
+func (o *retryOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&o.Attempts, "retry-attempts", retry.DefaultMaxAttempts, "Attempts to store a scan before its message is nacked, 1 disables retries and values below 1 are rejected")
+	cmd.Flags().DurationVar(&o.MessageTimeout, "message-timeout", 30*time.Second, "Deadline for storing a single message, including retries")
+}
+
+// Wrap retries failed writes to repo and counts each retry in m
+func (o *retryOptions) Wrap(repo scan_manager.Repository, m *metrics.Metrics) (scan_manager.Repository, error) {
+	if o.Attempts < 1 {
+		return nil, fmt.Errorf("--retry-attempts must be at least 1, got %d", o.Attempts)
+	}
+
+	if o.Attempts == 1 {
+		return repo, nil
+	}
+
+	return retry.NewRetrier(&retry.RetryConfig{
+		Repo:        repo,
+		MaxAttempts: o.Attempts,
+		OnRetry: func(class retry.Class) {
+			m.ObserveRetry(class.String())
+		},
+	})
+}
@@ -0,0 +1,76 @@
+package consumer
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/censys/scan-takehome/internal/source"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+const (
+	pubSubSource = "pubsub"
+	fileSource   = "file"
+)
+
+type sourceOptions struct {
+	Type          string
+	Subscription  string
+	File          string
+	MaxDeliveries int
+}
+
+func (o *sourceOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Type, "source", pubSubSource, "Where messages come from (pubsub, file)")
+	cmd.Flags().StringVarP(&o.Subscription, "subscription", "s", "scan-sub", "GCP PubSub Subscription ID")
+	cmd.Flags().StringVar(&o.File, "source-file", source.Stdin, "JSONL file of scan payloads or captured messages to replay with --source file, - reads stdin")
+	cmd.Flags().IntVar(&o.MaxDeliveries, "source-max-deliveries", source.DefaultMaxDeliveries, "Deliveries of a nacked message before --source file drops it")
+}
+
+// NeedsPubSub reports whether the source needs a PubSub client
+func (o *sourceOptions) NeedsPubSub() bool {
+	return o.Type == pubSubSource
+}
+
+// Name identifies the source in logs and spans
+func (o *sourceOptions) Name() string {
+	if o.Type == fileSource {
+		return o.File
+	}
+	return o.Subscription
+}
+
+// NewSource builds the configured source. Up to concurrency messages are
+// handled at once, and up to maxOutstanding are pulled from Pub/Sub.
+func (o *sourceOptions) NewSource(client *pubsub.Client, concurrency, maxOutstanding int) (source.Source, error) {
+	switch o.Type {
+	case pubSubSource:
+		sub := client.Subscription(o.Subscription)
+		sub.ReceiveSettings.NumGoroutines = concurrency
+		sub.ReceiveSettings.MaxOutstandingMessages = maxOutstanding
+
+		return source.NewPubSubSource(&source.PubSubSourceConfig{Subscription: sub})
+	case fileSource:
+		return source.NewFileSource(&source.FileSourceConfig{
+			Path:          o.File,
+			Concurrency:   concurrency,
+			MaxDeliveries: o.MaxDeliveries,
+		})
+	default:
+		return nil, fmt.Errorf("unknown source: %s", o.Type)
+	}
+}
+
+// spanAttributes describes the source on message spans
+func (o *sourceOptions) spanAttributes() []attribute.KeyValue {
+	if o.Type != pubSubSource {
+		return nil
+	}
+
+	return []attribute.KeyValue{
+		semconv.MessagingSystemGCPPubSub,
+		semconv.MessagingDestinationSubscriptionName(o.Subscription),
+	}
+}
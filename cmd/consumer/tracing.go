@@ -0,0 +1,26 @@
+package consumer
+
+import (
+	"context"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/serializer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var tracer = otel.Tracer("github.com/censys/scan-takehome/cmd/consumer")
+
+// parseScanMessage parses a message under its own span
+func parseScanMessage(ctx context.Context, data []byte) (*scan_manager.ScanResult, error) {
+	_, span := tracer.Start(ctx, "ParseScanMessage")
+	defer span.End()
+
+	result, err := serializer.ParseScanMessage(data)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid scan message")
+	}
+
+	return result, err
+}
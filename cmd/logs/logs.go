@@ -0,0 +1,53 @@
+package logs
+
+import (
+	"log/slog"
+
+	"github.com/censys/scan-takehome/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+// Options configures the structured logger of a command
+type Options struct {
+	Level            string
+	Format           string
+	SampleFirst      int
+	SampleThereafter int
+}
+
+// AddFlags registers the logging flags on cmd
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Level, "log-level", "info", "Minimum log level (debug, info, warn, error)")
+	cmd.Flags().StringVar(&o.Format, "log-format", logging.JSON, "Log output format (json, text)")
+	cmd.Flags().IntVar(&o.SampleFirst, "log-sample-first", 0, "Log only the first N info and debug records with the same message each second, 0 disables sampling")
+	cmd.Flags().IntVar(&o.SampleThereafter, "log-sample-thereafter", 100, "When sampling, also log every Nth record past the first")
+}
+
+// NewLogger builds the configured logger and installs it as the slog default
+func (o *Options) NewLogger() (*slog.Logger, error) {
+	level, err := logging.ParseLevel(o.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &logging.LoggerConfig{
+		Format: o.Format,
+		Level:  level,
+	}
+
+	if o.SampleFirst > 0 {
+		cfg.Sampling = &logging.SamplingConfig{
+			First:      o.SampleFirst,
+			Thereafter: o.SampleThereafter,
+		}
+	}
+
+	logger, err := logging.NewLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.SetDefault(logger)
+
+	return logger, nil
+}
@@ -0,0 +1,211 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/censys/scan-takehome/cmd/store"
+	bigtablestore "github.com/censys/scan-takehome/internal/repositories/bigtable"
+	"github.com/censys/scan-takehome/internal/repositories/cassandra"
+	dynamodbstore "github.com/censys/scan-takehome/internal/repositories/dynamodb"
+	"github.com/censys/scan-takehome/internal/repositories/postgres"
+	"github.com/spf13/cobra"
+)
+
+var (
+	storeType     string
+	dynamoOpts    store.DynamoDBOptions
+	postgresOpts  store.PostgresOptions
+	cassandraOpts store.CassandraOptions
+	bigtableOpts  store.BigtableOptions
+	billingMode   string
+	readCapacity  int64
+	writeCapacity int64
+	dryRun        bool
+)
+
+func NewMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Create or upgrade the DynamoDB tables, Postgres schema, Cassandra keyspace or Bigtable table",
+		Long:  "Idempotently creates the scan and history tables and applies pending schema migrations, tracked by a version item in the DynamoDB scan table or the schema_migrations table in Postgres. Cassandra and Bigtable have no versions, their keyspace, tables and column families are created if missing.",
+		RunE:  runMigrate,
+		// Exit non-zero on failure without dumping usage, so deploy scripts can gate on it
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVar(&storeType, "store", store.DynamoDB, "Store to migrate (dynamodb, postgres, cassandra, bigtable)")
+	dynamoOpts.AddFlags(cmd)
+	postgresOpts.AddFlags(cmd)
+	cassandraOpts.AddFlags(cmd)
+	bigtableOpts.AddFlags(cmd)
+	cmd.Flags().StringVar(&billingMode, "billing-mode", string(types.BillingModePayPerRequest), "Billing mode for new DynamoDB tables (PAY_PER_REQUEST, PROVISIONED)")
+	cmd.Flags().Int64Var(&readCapacity, "read-capacity", 0, "Read capacity units for PROVISIONED tables")
+	cmd.Flags().Int64Var(&writeCapacity, "write-capacity", 0, "Write capacity units for PROVISIONED tables")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print pending migrations without applying them")
+
+	return cmd
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	switch storeType {
+	case store.DynamoDB:
+		return migrateDynamoDB(ctx)
+	case store.Postgres:
+		return migratePostgres(ctx)
+	case store.Cassandra:
+		return migrateCassandra(ctx)
+	case store.Bigtable:
+		return migrateBigtable(ctx)
+	default:
+		return fmt.Errorf("unknown store type: %s", storeType)
+	}
+}
+
+func migrateDynamoDB(ctx context.Context) error {
+	client, err := dynamoOpts.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrator, err := dynamodbstore.NewMigrator(&dynamodbstore.MigratorConfig{
+		Client:        client,
+		Table:         dynamoOpts.Table,
+		HistoryTable:  dynamoOpts.HistoryTable,
+		BillingMode:   types.BillingMode(billingMode),
+		ReadCapacity:  readCapacity,
+		WriteCapacity: writeCapacity,
+	})
+	if err != nil {
+		return err
+	}
+
+	return migrate(ctx, migrator, func(m dynamodbstore.Migration) (int, string) {
+		return m.Version, m.Description
+	})
+}
+
+func migratePostgres(ctx context.Context) error {
+	pool, err := postgresOpts.NewPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := postgres.NewMigrator(&postgres.MigratorConfig{Pool: pool})
+	if err != nil {
+		return err
+	}
+
+	return migrate(ctx, migrator, func(m postgres.Migration) (int, string) {
+		return m.Version, m.Description
+	})
+}
+
+// migrateCassandra creates the keyspace and any missing tables. CQL schema
+// changes can't be versioned like the other stores, so --dry-run only
+// checks the cluster is reachable.
+func migrateCassandra(ctx context.Context) error {
+	session, err := cassandraOpts.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if dryRun {
+		fmt.Printf("Would create keyspace %s and its tables if missing\n", cassandraOpts.Keyspace)
+		return nil
+	}
+
+	err = cassandra.Bootstrap(ctx, &cassandra.BootstrapConfig{
+		Session:           session,
+		Keyspace:          cassandraOpts.Keyspace,
+		ReplicationFactor: cassandraOpts.ReplicationFactor,
+		Datacenter:        cassandraOpts.Datacenter,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Keyspace %s is up to date\n", cassandraOpts.Keyspace)
+	return nil
+}
+
+// migrateBigtable creates the table and column family if missing. Like
+// Cassandra's, --dry-run only reports what would be created.
+func migrateBigtable(ctx context.Context) error {
+	admin, err := bigtableOpts.NewAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	if dryRun {
+		fmt.Printf("Would create table %s and its column family if missing\n", bigtableOpts.Table)
+		return nil
+	}
+
+	err = bigtablestore.Bootstrap(ctx, &bigtablestore.BootstrapConfig{
+		Admin: admin,
+		Table: bigtableOpts.Table,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Table %s is up to date\n", bigtableOpts.Table)
+	return nil
+}
+
+// schemaMigrator is implemented by each store's migrator
+type schemaMigrator[M any] interface {
+	Version(ctx context.Context) (int, error)
+	Pending(ctx context.Context) ([]M, error)
+	Migrate(ctx context.Context) ([]M, error)
+}
+
+// migrate reports the schema version and applies, or with --dry-run lists,
+// pending migrations. describe returns a migration's version and description.
+func migrate[M any](ctx context.Context, migrator schemaMigrator[M], describe func(M) (int, string)) error {
+	current, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Current schema version: %d\n", current)
+
+	if dryRun {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range pending {
+			version, description := describe(migration)
+			fmt.Printf("Pending migration %d: %s\n", version, description)
+		}
+		fmt.Printf("%d pending migrations\n", len(pending))
+		return nil
+	}
+
+	applied, err := migrator.Migrate(ctx)
+	for _, migration := range applied {
+		version, description := describe(migration)
+		fmt.Printf("Applied migration %d: %s\n", version, description)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("Schema is up to date")
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package scanner
+
+import (
+	"github.com/censys/scan-takehome/internal/workload"
+	"github.com/spf13/cobra"
+)
+
+// addFaultFlags registers the fault injection flags, which set the percent
+// of messages carrying each fault
+func addFaultFlags(cmd *cobra.Command, cfg *workload.FaultConfig) {
+	cmd.Flags().Float64Var(&cfg.Backdated, "fault-backdated", 0, "Percent of messages repeating a recent scan's key with an older timestamp")
+	cmd.Flags().Float64Var(&cfg.Duplicate, "fault-duplicate", 0, "Percent of messages resending a recent message exactly")
+	cmd.Flags().Float64Var(&cfg.Conflict, "fault-conflict", 0, "Percent of messages repeating a recent scan's key and timestamp with a different response")
+	cmd.Flags().Float64Var(&cfg.UnknownVersion, "fault-unknown-version", 0, "Percent of messages with an unknown data_version")
+	cmd.Flags().Float64Var(&cfg.NullData, "fault-null-data", 0, "Percent of messages with null data")
+	cmd.Flags().Float64Var(&cfg.Truncated, "fault-truncated", 0, "Percent of messages with truncated JSON")
+	cmd.Flags().Float64Var(&cfg.Oversize, "fault-oversize", 0, "Percent of messages with a response over DynamoDB's item size limit")
+	cmd.Flags().IntVar(&cfg.OversizeBytes, "fault-oversize-bytes", workload.DefaultOversizeBytes, "Response size of oversize messages")
+}
@@ -0,0 +1,231 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/censys/scan-takehome/cmd/logs"
+	"github.com/censys/scan-takehome/cmd/traces"
+	"github.com/censys/scan-takehome/internal/tracing"
+	"github.com/censys/scan-takehome/internal/workload"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	projectID      string
+	topicID        string
+	rate           float64
+	count          int
+	duration       time.Duration
+	startTimestamp int64
+	generatorCfg   workload.GeneratorConfig
+	logOpts        logs.Options
+	traceOpts      traces.Options
+)
+
+var tracer = otel.Tracer("github.com/censys/scan-takehome/cmd/scanner")
+
+func NewScannerCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scanner",
+		Short: "Publish simulated scan results",
+		Long:  "Scanner publishing random scan results to a PubSub topic, reproducibly when seeded",
+		Run:   runScanner,
+	}
+
+	cmd.Flags().StringVarP(&projectID, "project", "p", "test-project", "GCP Project ID")
+	cmd.Flags().StringVarP(&topicID, "topic", "t", "scan-topic", "GCP PubSub Topic ID")
+	cmd.Flags().Float64VarP(&rate, "rate", "r", 1, "Scans published per second")
+	cmd.Flags().IntVarP(&count, "count", "n", 0, "Stop after publishing this many scans, 0 publishes until stopped")
+	cmd.Flags().DurationVarP(&duration, "duration", "d", 0, "Stop after running this long, 0 runs until stopped")
+	cmd.Flags().Int64Var(&startTimestamp, "start-timestamp", 0, "Unix time of the first scan, later scans advance with the rate instead of the clock. 0 uses the clock")
+	cmd.Flags().StringVar(&generatorCfg.IPs, "ips", workload.DefaultIPs, "IPv4 CIDR or inclusive range (10.0.0.1-10.0.0.50) to scan")
+	cmd.Flags().StringVar(&generatorCfg.Ports, "ports", workload.DefaultPorts, "Comma separated ports and inclusive port ranges to scan, e.g. 22,80,8000-8100")
+	cmd.Flags().StringSliceVar(&generatorCfg.Services, "services", workload.DefaultServices, "Services to report")
+	cmd.Flags().Float64Var(&generatorCfg.V2Ratio, "v2-ratio", workload.DefaultV2Ratio, "Fraction of scans encoded as data version 2, the rest use version 1")
+	cmd.Flags().Int64Var(&generatorCfg.Seed, "seed", 0, "Seed for reproducible scans, 0 picks a random seed which is logged on start")
+	addFaultFlags(cmd, &generatorCfg.Faults)
+	logOpts.AddFlags(cmd)
+	traceOpts.AddFlags(cmd)
+
+	return cmd
+}
+
+func runScanner(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	logger, err := logOpts.NewLogger()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing logger: %v\n", err)
+		return
+	}
+
+	if rate <= 0 {
+		logger.Error("--rate must be positive", slog.Float64("rate", rate))
+		return
+	}
+
+	shutdownTracing, err := traceOpts.Setup(ctx, "mini-scan-scanner")
+	if err != nil {
+		logger.Error("failed to initialize tracing", slog.Any("error", err))
+		return
+	}
+
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("failed to flush traces", slog.Any("error", err))
+		}
+	}()
+
+	generator, err := workload.NewGenerator(&generatorCfg)
+	if err != nil {
+		logger.Error("failed to initialize scan generator", slog.Any("error", err))
+		return
+	}
+
+	logger.Info("starting scanner",
+		slog.String("project", projectID),
+		slog.String("topic", topicID),
+		slog.Float64("rate", rate),
+		slog.Int64("seed", generator.Seed()),
+	)
+
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		logger.Error("failed to create PubSub client", slog.Any("error", err))
+		return
+	}
+
+	defer client.Close()
+
+	topic := client.Topic(topicID)
+	defer topic.Stop()
+
+	if duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	// Handle shutdown signals
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigChan:
+			logger.Info("received shutdown signal, stopping scanner")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	interval := time.Duration(float64(time.Second) / rate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var published, failed atomic.Int64
+	var wg sync.WaitGroup
+	faults := map[string]int{}
+
+	for i := 0; count == 0 || i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+			}
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		timestamp := time.Now().Unix()
+		if startTimestamp > 0 {
+			timestamp = startTimestamp + int64(float64(i)/rate)
+		}
+
+		msg, err := generator.NextMessage(timestamp)
+		if err != nil {
+			logger.Error("failed to encode scan", slog.Any("error", err))
+			return
+		}
+
+		if msg.Fault != "" {
+			faults[msg.Fault]++
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := publish(topic, msg); err != nil {
+				failed.Add(1)
+				logger.Error("failed to publish scan", slog.Any("error", err))
+				return
+			}
+			published.Add(1)
+		}()
+	}
+
+	// Wait for outstanding publishes, they aren't canceled on shutdown
+	wg.Wait()
+
+	faultAttrs := make([]any, 0, len(faults))
+	for _, fault := range slices.Sorted(maps.Keys(faults)) {
+		faultAttrs = append(faultAttrs, slog.Int(fault, faults[fault]))
+	}
+
+	logger.Info("scanner stopped",
+		slog.Int64("published", published.Load()),
+		slog.Int64("failed", failed.Load()),
+		slog.Group("faults", faultAttrs...),
+	)
+}
+
+// publish sends a scan under a new trace, which the consumer continues from
+// the message attributes. Injected faults are labelled in the attributes too.
+func publish(topic *pubsub.Topic, message *workload.Message) error {
+	ctx, span := tracer.Start(context.Background(), "send "+topic.ID(),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemGCPPubSub,
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(topic.ID()),
+		),
+	)
+	defer span.End()
+
+	msg := &pubsub.Message{Data: message.Data, Attributes: map[string]string{}}
+	if message.Fault != "" {
+		msg.Attributes[workload.FaultAttribute] = message.Fault
+		span.SetAttributes(attribute.String("scan.fault", message.Fault))
+	}
+	tracing.Inject(ctx, msg.Attributes)
+
+	id, err := topic.Publish(ctx, msg).Get(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to publish scan")
+		return err
+	}
+
+	span.SetAttributes(semconv.MessagingMessageID(id))
+	return nil
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/censys/scan-takehome/cmd/logs"
+	"github.com/censys/scan-takehome/cmd/store"
+	"github.com/censys/scan-takehome/internal/api"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	addr      string
+	storeOpts store.Options
+	logOpts   logs.Options
+)
+
+func NewServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the scan query API",
+		Long:  "HTTP JSON API for looking up the current scan state of hosts and services",
+		Run:   runServer,
+	}
+
+	cmd.Flags().StringVarP(&addr, "addr", "a", ":8080", "Address to listen on")
+	storeOpts.AddFlags(cmd)
+	logOpts.AddFlags(cmd)
+
+	return cmd
+}
+
+func runServer(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	logger, err := logOpts.NewLogger()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing logger: %v\n", err)
+		return
+	}
+
+	logger.Info("starting API server", slog.String("addr", addr), slog.String("store", storeOpts.Type))
+
+	repo, closeRepo, err := storeOpts.NewRepository(ctx, logger)
+	if err != nil {
+		logger.Error("failed to initialize scanner store", slog.Any("error", err))
+		return
+	}
+
+	defer closeRepo()
+
+	manager, err := scan_manager.NewScanManager(&scan_manager.ScanManagerConfig{
+		Repo:   repo,
+		Logger: logger,
+	})
+
+	if err != nil {
+		logger.Error("failed to initialize scan manager", slog.Any("error", err))
+		return
+	}
+
+	handler, err := api.NewAPI(&api.APIConfig{
+		Manager: manager,
+		Logger:  logger,
+	})
+
+	if err != nil {
+		logger.Error("failed to initialize API", slog.Any("error", err))
+		return
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	// Handle shutdown signals
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	// done is closed once in-flight requests have drained, the repository
+	// must stay open until then
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		<-sigChan
+		logger.Info("received shutdown signal, stopping server")
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 10*time.Second)
+		defer shutdownCancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("failed to shut down server", slog.Any("error", err))
+		}
+	}()
+
+	// ListenAndServe returns as soon as Shutdown starts
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("failed to serve API", slog.Any("error", err))
+		return
+	}
+
+	<-done
+
+	logger.Info("server stopped")
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/bigtable"
+	bigtablestore "github.com/censys/scan-takehome/internal/repositories/bigtable"
+	"github.com/spf13/cobra"
+)
+
+// BigtableOptions configures the Bigtable clients. Both connect to the
+// emulator at BIGTABLE_EMULATOR_HOST when it is set. Every flag can also be
+// set through the environment variable named in its usage.
+type BigtableOptions struct {
+	Project  string
+	Instance string
+	Table    string
+}
+
+func (o *BigtableOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Project, "bigtable-project", envOr("BIGTABLE_PROJECT", "test-project"),
+		"GCP project of the Bigtable instance [BIGTABLE_PROJECT]")
+	cmd.Flags().StringVar(&o.Instance, "bigtable-instance", envOr("BIGTABLE_INSTANCE", "mini-scan"),
+		"Bigtable instance ID [BIGTABLE_INSTANCE]")
+	cmd.Flags().StringVar(&o.Table, "bigtable-table", envOr("BIGTABLE_TABLE", bigtablestore.DefaultTable),
+		"Table holding scan results [BIGTABLE_TABLE]")
+}
+
+func (o *BigtableOptions) NewClient(ctx context.Context) (*bigtable.Client, error) {
+	client, err := bigtable.NewClient(ctx, o.Project, o.Instance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Bigtable client: %w", err)
+	}
+
+	return client, nil
+}
+
+// NewAdminClient builds the client migrate creates tables with
+func (o *BigtableOptions) NewAdminClient(ctx context.Context) (*bigtable.AdminClient, error) {
+	admin, err := bigtable.NewAdminClient(ctx, o.Project, o.Instance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Bigtable admin client: %w", err)
+	}
+
+	return admin, nil
+}
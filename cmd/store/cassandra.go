@@ -0,0 +1,86 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/repositories/cassandra"
+	"github.com/gocql/gocql"
+	"github.com/spf13/cobra"
+)
+
+// CassandraOptions configures the CQL session for Cassandra or ScyllaDB. Every
+// flag can also be set through the environment variable named in its usage.
+type CassandraOptions struct {
+	Hosts       string
+	Keyspace    string
+	Consistency string
+	Datacenter  string
+	Timeout     time.Duration
+	// ReplicationFactor is only used when migrate creates the keyspace
+	ReplicationFactor int
+}
+
+func (o *CassandraOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Hosts, "cassandra-hosts", envOr("CASSANDRA_HOSTS", "localhost"),
+		"Comma separated contact points, the rest of the cluster is discovered from them [CASSANDRA_HOSTS]")
+	cmd.Flags().StringVar(&o.Keyspace, "cassandra-keyspace", envOr("CASSANDRA_KEYSPACE", cassandra.DefaultKeyspace),
+		"Keyspace holding the scan tables [CASSANDRA_KEYSPACE]")
+	cmd.Flags().StringVar(&o.Consistency, "cassandra-consistency", envOr("CASSANDRA_CONSISTENCY", gocql.Quorum.String()),
+		"Consistency level for reads and writes, lightweight transactions always agree at SERIAL [CASSANDRA_CONSISTENCY]")
+	cmd.Flags().StringVar(&o.Datacenter, "cassandra-datacenter", envOr("CASSANDRA_DATACENTER", ""),
+		"Local datacenter, queries are routed to it and new keyspaces are replicated with NetworkTopologyStrategy in it [CASSANDRA_DATACENTER]")
+	cmd.Flags().DurationVar(&o.Timeout, "cassandra-timeout", 5*time.Second,
+		"Timeout for each query")
+	cmd.Flags().IntVar(&o.ReplicationFactor, "cassandra-replication-factor", 1,
+		"Replicas of a keyspace created by migrate")
+}
+
+// NewSession connects to the cluster. Unlike the other stores this fails
+// when no contact point is reachable.
+func (o *CassandraOptions) NewSession() (*gocql.Session, error) {
+	var hosts []string
+	for _, host := range strings.Split(o.Hosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("--cassandra-hosts is empty")
+	}
+
+	consistency, err := gocql.ParseConsistencyWrapper(o.Consistency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --cassandra-consistency: %w", err)
+	}
+
+	cluster := gocql.NewCluster(hosts...)
+	cluster.Consistency = consistency
+	cluster.SerialConsistency = o.serialConsistency()
+	if o.Datacenter != "" {
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.DCAwareRoundRobinPolicy(o.Datacenter))
+	}
+
+	if o.Timeout > 0 {
+		cluster.Timeout = o.Timeout
+	}
+
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Cassandra: %w", err)
+	}
+
+	return session, nil
+}
+
+// serialConsistency keeps lightweight transactions within the local
+// datacenter when one is set
+func (o *CassandraOptions) serialConsistency() gocql.SerialConsistency {
+	if o.Datacenter != "" {
+		return gocql.LocalSerial
+	}
+
+	return gocql.Serial
+}
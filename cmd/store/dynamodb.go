@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbstore "github.com/censys/scan-takehome/internal/repositories/dynamodb"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+)
+
+// localRegion is used against an endpoint override when no region is
+// configured, DynamoDB Local accepts any region
+const localRegion = "us-east-1"
+
+// DynamoDBOptions configures the DynamoDB client and tables. Every flag can
+// also be set through the environment variable named in its usage.
+type DynamoDBOptions struct {
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Table           string
+	HistoryTable    string
+}
+
+func (o *DynamoDBOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Endpoint, "dynamodb-endpoint", envOr("DYNAMODB_ENDPOINT", ""),
+		"DynamoDB endpoint override, e.g. http://localhost:8000 for DynamoDB Local [DYNAMODB_ENDPOINT]")
+	cmd.Flags().StringVar(&o.Region, "dynamodb-region", envOr("DYNAMODB_REGION", ""),
+		"AWS region, defaults to the AWS SDK configuration [DYNAMODB_REGION]")
+	cmd.Flags().StringVar(&o.AccessKeyID, "dynamodb-access-key-id", envOr("DYNAMODB_ACCESS_KEY_ID", ""),
+		"Static access key ID, defaults to the AWS credential chain [DYNAMODB_ACCESS_KEY_ID]")
+	cmd.Flags().StringVar(&o.SecretAccessKey, "dynamodb-secret-access-key", envOr("DYNAMODB_SECRET_ACCESS_KEY", ""),
+		"Static secret access key [DYNAMODB_SECRET_ACCESS_KEY]")
+	cmd.Flags().StringVar(&o.Table, "dynamodb-table", envOr("DYNAMODB_TABLE", dynamodbstore.DefaultTable),
+		"Table holding the latest scan per service [DYNAMODB_TABLE]")
+	cmd.Flags().StringVar(&o.HistoryTable, "dynamodb-history-table", envOr("DYNAMODB_HISTORY_TABLE", dynamodbstore.DefaultHistoryTable),
+		"Table holding scan history when --history is set [DYNAMODB_HISTORY_TABLE]")
+}
+
+// NewClient builds a DynamoDB client. Without an endpoint override it uses
+// the default AWS credential chain and region resolution. With an override
+// it falls back to dummy credentials and us-east-1 for DynamoDB Local.
+// Calls are traced.
+func (o *DynamoDBOptions) NewClient(ctx context.Context) (*dynamodb.Client, error) {
+	if (o.AccessKeyID == "") != (o.SecretAccessKey == "") {
+		return nil, errors.New("both or neither of --dynamodb-access-key-id and --dynamodb-secret-access-key must be set")
+	}
+
+	var opts []func(*config.LoadOptions) error
+
+	if o.Region != "" {
+		opts = append(opts, config.WithRegion(o.Region))
+	}
+
+	switch {
+	case o.AccessKeyID != "":
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			o.AccessKeyID, o.SecretAccessKey, "",
+		)))
+	case o.Endpoint != "":
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			"dummy", "dummy", "",
+		)))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	if cfg.Region == "" {
+		if o.Endpoint == "" {
+			return nil, errors.New("no AWS region configured, set --dynamodb-region or AWS_REGION")
+		}
+		cfg.Region = localRegion
+	}
+
+	// Every DynamoDB call gets a span under the caller's, using the global
+	// tracer provider once a command installs one
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
+
+	return dynamodb.NewFromConfig(cfg, func(opts *dynamodb.Options) {
+		if o.Endpoint != "" {
+			opts.BaseEndpoint = aws.String(o.Endpoint)
+		}
+	}), nil
+}
@@ -0,0 +1,18 @@
+package store
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// DefaultSQLitePath is the database file used when --sqlite-path is unset
+const DefaultSQLitePath = "scans.db"
+
+// SQLiteOptions configures the embedded SQLite database
+type SQLiteOptions struct {
+	Path string
+}
+
+func (o *SQLiteOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Path, "sqlite-path", envOr("SQLITE_PATH", DefaultSQLitePath),
+		"SQLite database file, created if missing, or :memory: [SQLITE_PATH]")
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/censys/scan-takehome/internal/logging"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	bigtablestore "github.com/censys/scan-takehome/internal/repositories/bigtable"
+	"github.com/censys/scan-takehome/internal/repositories/cassandra"
+	dynamodbstore "github.com/censys/scan-takehome/internal/repositories/dynamodb"
+	"github.com/censys/scan-takehome/internal/repositories/memory"
+	"github.com/censys/scan-takehome/internal/repositories/postgres"
+	"github.com/censys/scan-takehome/internal/repositories/sqlite"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DynamoDB  = "dynamodb"
+	Memory    = "memory"
+	SQLite    = "sqlite"
+	Postgres  = "postgres"
+	Cassandra = "cassandra"
+	Bigtable  = "bigtable"
+)
+
+// Options selects and configures the Repository backing a command
+type Options struct {
+	Type        string
+	History     bool
+	RecordStale bool
+	DynamoDB    DynamoDBOptions
+	SQLite      SQLiteOptions
+	Postgres    PostgresOptions
+	Cassandra   CassandraOptions
+	Bigtable    BigtableOptions
+}
+
+// AddFlags registers the store flags on cmd
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Type, "store", envOr("STORE", DynamoDB), "Repository backend for scan results (dynamodb, memory, sqlite, postgres, cassandra, bigtable) [STORE]")
+	cmd.Flags().BoolVar(&o.History, "history", false, "Keep a history of every accepted scan alongside the latest state")
+	cmd.Flags().BoolVar(&o.RecordStale, "history-record-stale", false, "Also record scans rejected as stale in the history (requires --history)")
+	o.DynamoDB.AddFlags(cmd)
+	o.SQLite.AddFlags(cmd)
+	o.Postgres.AddFlags(cmd)
+	o.Cassandra.AddFlags(cmd)
+	o.Bigtable.AddFlags(cmd)
+}
+
+// NewRepository builds the Repository selected by the --store flag, and a
+// func that releases it once the caller is done. logger is optional and
+// passed to backends that log.
+func (o *Options) NewRepository(ctx context.Context, logger *slog.Logger) (scan_manager.Repository, func(), error) {
+	noop := func() {}
+
+	switch o.Type {
+	case Memory:
+		repo, err := memory.NewMemory(&memory.MemoryConfig{
+			History:     o.History,
+			RecordStale: o.RecordStale,
+		})
+		return repo, noop, err
+	case DynamoDB:
+		repo, err := o.newDynamoDB(ctx, logger)
+		return repo, noop, err
+	case SQLite:
+		return o.newSQLite(logger)
+	case Postgres:
+		return o.newPostgres(ctx)
+	case Cassandra:
+		return o.newCassandra()
+	case Bigtable:
+		return o.newBigtable(ctx, logger)
+	default:
+		return nil, nil, fmt.Errorf("unknown store type: %s", o.Type)
+	}
+}
+
+func (o *Options) newSQLite(logger *slog.Logger) (scan_manager.Repository, func(), error) {
+	repo, err := sqlite.NewSQLite(&sqlite.SQLiteConfig{
+		Path:        o.SQLite.Path,
+		History:     o.History,
+		RecordStale: o.RecordStale,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closeRepo := func() {
+		if err := repo.Close(); err != nil {
+			logging.OrDefault(logger).Error("failed to close SQLite database", slog.Any("error", err))
+		}
+	}
+
+	return repo, closeRepo, nil
+}
+
+func (o *Options) newDynamoDB(ctx context.Context, logger *slog.Logger) (scan_manager.Repository, error) {
+	client, err := o.DynamoDB.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var historyTable string
+	if o.History {
+		historyTable = o.DynamoDB.HistoryTable
+	}
+
+	// Initialize the dynamoDB repository for storing scan results
+	return dynamodbstore.NewDynamoDB(&dynamodbstore.DynamoDBConfig{
+		Client:       client,
+		Table:        o.DynamoDB.Table,
+		HistoryTable: historyTable,
+		RecordStale:  o.RecordStale,
+		Logger:       logger,
+	})
+}
+
+func (o *Options) newPostgres(ctx context.Context) (scan_manager.Repository, func(), error) {
+	pool, err := o.Postgres.NewPool(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo, err := postgres.NewPostgres(&postgres.PostgresConfig{
+		Pool:        pool,
+		History:     o.History,
+		RecordStale: o.RecordStale,
+	})
+	if err != nil {
+		pool.Close()
+		return nil, nil, err
+	}
+
+	return repo, pool.Close, nil
+}
+
+func (o *Options) newCassandra() (scan_manager.Repository, func(), error) {
+	// Keeping history would need a table per key and time range, which the
+	// store doesn't have yet
+	if o.History {
+		return nil, nil, fmt.Errorf("--history is not supported by the %s store", Cassandra)
+	}
+
+	session, err := o.Cassandra.NewSession()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo, err := cassandra.NewCassandra(&cassandra.CassandraConfig{
+		Session:           session,
+		Keyspace:          o.Cassandra.Keyspace,
+		SerialConsistency: o.Cassandra.serialConsistency(),
+	})
+	if err != nil {
+		session.Close()
+		return nil, nil, err
+	}
+
+	return repo, session.Close, nil
+}
+
+func (o *Options) newBigtable(ctx context.Context, logger *slog.Logger) (scan_manager.Repository, func(), error) {
+	// Bigtable keeps cell versions, but the store only reads the newest
+	if o.History {
+		return nil, nil, fmt.Errorf("--history is not supported by the %s store", Bigtable)
+	}
+
+	client, err := o.Bigtable.NewClient(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closeClient := func() {
+		if err := client.Close(); err != nil {
+			logging.OrDefault(logger).Error("failed to close Bigtable client", slog.Any("error", err))
+		}
+	}
+
+	repo, err := bigtablestore.NewBigtable(&bigtablestore.BigtableConfig{
+		Client: client,
+		Table:  o.Bigtable.Table,
+	})
+	if err != nil {
+		closeClient()
+		return nil, nil, err
+	}
+
+	return repo, closeClient, nil
+}
+
+// envOr returns the environment variable key, or def when it is unset
+func envOr(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewRepository(t *testing.T) {
+	t.Run("should build a memory repository", func(t *testing.T) {
+		opts := &Options{Type: Memory}
+
+		repo, closeRepo, err := opts.NewRepository(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		defer closeRepo()
+
+		if err := repo.Ping(context.Background()); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("should build a SQLite repository", func(t *testing.T) {
+		opts := &Options{Type: SQLite, SQLite: SQLiteOptions{Path: t.TempDir() + "/scans.db"}}
+
+		repo, closeRepo, err := opts.NewRepository(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		defer closeRepo()
+
+		if err := repo.Ping(context.Background()); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("should build a Postgres repository without connecting", func(t *testing.T) {
+		opts := &Options{Type: Postgres, Postgres: PostgresOptions{URL: DefaultPostgresURL}}
+
+		_, closeRepo, err := opts.NewRepository(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		closeRepo()
+	})
+
+	t.Run("should return error for Cassandra with history", func(t *testing.T) {
+		opts := &Options{Type: Cassandra, History: true}
+
+		if _, _, err := opts.NewRepository(context.Background(), nil); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should build a Bigtable repository without connecting", func(t *testing.T) {
+		t.Setenv("BIGTABLE_EMULATOR_HOST", "localhost:8086")
+		opts := &Options{Type: Bigtable, Bigtable: BigtableOptions{Project: "test-project", Instance: "mini-scan"}}
+
+		_, closeRepo, err := opts.NewRepository(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		closeRepo()
+	})
+
+	t.Run("should return error for Bigtable with history", func(t *testing.T) {
+		opts := &Options{Type: Bigtable, History: true}
+
+		if _, _, err := opts.NewRepository(context.Background(), nil); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error for unknown store types", func(t *testing.T) {
+		opts := &Options{Type: "cassette"}
+
+		if _, _, err := opts.NewRepository(context.Background(), nil); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestDynamoDBOptions_NewClient(t *testing.T) {
+	t.Run("should return error when only one static credential is set", func(t *testing.T) {
+		opts := &DynamoDBOptions{AccessKeyID: "key"}
+
+		if _, err := opts.NewClient(context.Background()); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should fall back to a local region with an endpoint override", func(t *testing.T) {
+		t.Setenv("AWS_REGION", "")
+		t.Setenv("AWS_DEFAULT_REGION", "")
+		t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/missing")
+
+		opts := &DynamoDBOptions{Endpoint: "http://localhost:8000"}
+
+		client, err := opts.NewClient(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if region := client.Options().Region; region != localRegion {
+			t.Errorf("expected region %s, got %s", localRegion, region)
+		}
+	})
+
+	t.Run("should use the configured region", func(t *testing.T) {
+		opts := &DynamoDBOptions{Region: "eu-west-1"}
+
+		client, err := opts.NewClient(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if region := client.Options().Region; region != "eu-west-1" {
+			t.Errorf("expected region eu-west-1, got %s", region)
+		}
+	})
+}
+
+func TestPostgresOptions_NewPool(t *testing.T) {
+	t.Run("should return error for a malformed URL", func(t *testing.T) {
+		opts := &PostgresOptions{URL: "postgres://localhost:port"}
+
+		if _, err := opts.NewPool(context.Background()); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error when the minimum exceeds the maximum", func(t *testing.T) {
+		opts := &PostgresOptions{URL: DefaultPostgresURL, MaxConns: 2, MinConns: 4}
+
+		if _, err := opts.NewPool(context.Background()); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should apply the pool settings", func(t *testing.T) {
+		opts := &PostgresOptions{URL: DefaultPostgresURL, MaxConns: 8, MinConns: 0, MaxConnLifetime: time.Minute}
+
+		pool, err := opts.NewPool(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		defer pool.Close()
+
+		if cfg := pool.Config(); cfg.MaxConns != 8 || cfg.MaxConnLifetime != time.Minute {
+			t.Errorf("expected 8 connections living a minute, got %d living %s", cfg.MaxConns, cfg.MaxConnLifetime)
+		}
+	})
+}
+
+func TestCassandraOptions_NewSession(t *testing.T) {
+	t.Run("should return error without hosts", func(t *testing.T) {
+		opts := &CassandraOptions{Hosts: " , ", Consistency: "quorum"}
+
+		if _, err := opts.NewSession(); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error for an unknown consistency", func(t *testing.T) {
+		opts := &CassandraOptions{Hosts: "localhost", Consistency: "most"}
+
+		if _, err := opts.NewSession(); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestEnvOr(t *testing.T) {
+	t.Setenv("MINI_SCAN_TEST_SET", "value")
+
+	if got := envOr("MINI_SCAN_TEST_SET", "default"); got != "value" {
+		t.Errorf("expected value, got %s", got)
+	}
+
+	if got := envOr("MINI_SCAN_TEST_UNSET", "default"); got != "default" {
+		t.Errorf("expected default, got %s", got)
+	}
+}
@@ -0,0 +1,40 @@
+package traces
+
+import (
+	"context"
+	"os"
+
+	"github.com/censys/scan-takehome/internal/tracing"
+	"github.com/spf13/cobra"
+)
+
+// Options configures trace export for a command
+type Options struct {
+	Exporter    string
+	SampleRatio float64
+}
+
+// AddFlags registers the tracing flags on cmd
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Exporter, "trace-exporter", envOr("TRACE_EXPORTER", tracing.None),
+		"Trace exporter (none, stdout, otlp). otlp is configured with the OTEL_EXPORTER_OTLP_* environment variables [TRACE_EXPORTER]")
+	cmd.Flags().Float64Var(&o.SampleRatio, "trace-sample-ratio", 1, "Fraction of new traces to record, traces started upstream follow the upstream decision")
+}
+
+// Setup installs the configured tracer provider globally. The returned func
+// flushes buffered spans and must be called before the command returns.
+func (o *Options) Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	return tracing.Setup(ctx, &tracing.ProviderConfig{
+		ServiceName: serviceName,
+		Exporter:    o.Exporter,
+		SampleRatio: o.SampleRatio,
+	})
+}
+
+// envOr returns the environment variable key, or def when it is unset
+func envOr(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -14,7 +15,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/censys/scan-takehome/internal/managers/scan_manager"
 	dynamodbstore "github.com/censys/scan-takehome/internal/repositories/dynamodb"
+	"github.com/censys/scan-takehome/internal/repositories/repotest"
 	"github.com/censys/scan-takehome/internal/serializer"
+	"github.com/censys/scan-takehome/internal/workload"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
 )
@@ -67,25 +70,14 @@ func setupDynamoDB(t *testing.T) (*dynamodb.Client, func()) {
 		o.BaseEndpoint = aws.String(endpoint)
 	})
 
-	// Create the scan-results table
-	_, err = client.CreateTable(ctx, &dynamodb.CreateTableInput{
-		TableName: aws.String("scan-results"),
-		KeySchema: []types.KeySchemaElement{
-			{
-				AttributeName: aws.String("pk"),
-				KeyType:       types.KeyTypeHash,
-			},
-		},
-		AttributeDefinitions: []types.AttributeDefinition{
-			{
-				AttributeName: aws.String("pk"),
-				AttributeType: types.ScalarAttributeTypeS,
-			},
-		},
-		BillingMode: types.BillingModePayPerRequest,
-	})
+	// Create the scan-results and scan-history tables
+	migrator, err := dynamodbstore.NewMigrator(&dynamodbstore.MigratorConfig{Client: client})
 	if err != nil {
-		t.Fatalf("Failed to create table: %v", err)
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	if _, err := migrator.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate tables: %v", err)
 	}
 
 	cleanup := func() {
@@ -142,7 +134,7 @@ func TestIntegration_OutOfOrderMessages(t *testing.T) {
 	}`
 
 	result1, _ := serializer.ParseScanMessage([]byte(jsonData1))
-	err = manager.PutScan(context.Background(), result1)
+	_, err = manager.PutScan(context.Background(), result1)
 	if err != nil {
 		t.Fatalf("Failed to put first scan: %v", err)
 	}
@@ -160,12 +152,26 @@ func TestIntegration_OutOfOrderMessages(t *testing.T) {
 	}`
 
 	result2, _ := serializer.ParseScanMessage([]byte(jsonData2))
-	err = manager.PutScan(context.Background(), result2)
-	// Should succeed (conditional check handles it silently)
+	outcome, err := manager.PutScan(context.Background(), result2)
+	// Should succeed (conditional check rejects it as stale)
 	if err != nil {
 		t.Fatalf("Failed to put second scan: %v", err)
 	}
 
+	if outcome != scan_manager.StaleIgnored {
+		t.Errorf("Expected outcome stale_ignored, got %s", outcome)
+	}
+
+	// Redelivering the first message is a duplicate
+	outcome, err = manager.PutScan(context.Background(), result1)
+	if err != nil {
+		t.Fatalf("Failed to redeliver first scan: %v", err)
+	}
+
+	if outcome != scan_manager.Duplicate {
+		t.Errorf("Expected outcome duplicate, got %s", outcome)
+	}
+
 	// Verify only the newer response is stored
 	item := getItemFromDynamoDB(t, client, "172.16.0.1", 443, "https")
 	if resp, ok := item["response"].(*types.AttributeValueMemberS); ok {
@@ -213,7 +219,7 @@ func TestIntegration_MultipleServices(t *testing.T) {
 		}`, svc.port, svc.service, svc.resp)
 
 		result, _ := serializer.ParseScanMessage([]byte(jsonData))
-		err = manager.PutScan(context.Background(), result)
+		_, err = manager.PutScan(context.Background(), result)
 		if err != nil {
 			t.Fatalf("Failed to put scan for %s: %v", svc.service, err)
 		}
@@ -234,3 +240,433 @@ func TestIntegration_MultipleServices(t *testing.T) {
 		}
 	}
 }
+
+func TestIntegration_ReadAPIs(t *testing.T) {
+	client, cleanup := setupDynamoDB(t)
+	defer cleanup()
+
+	store, err := dynamodbstore.NewDynamoDB(&dynamodbstore.DynamoDBConfig{Client: client})
+	if err != nil {
+		t.Fatalf("Failed to create DynamoDB store: %v", err)
+	}
+
+	manager, err := scan_manager.NewScanManager(&scan_manager.ScanManagerConfig{Repo: store})
+	if err != nil {
+		t.Fatalf("Failed to create scan manager: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := store.Ping(ctx); err != nil {
+		t.Fatalf("Failed to ping DynamoDB: %v", err)
+	}
+
+	// Five services on one host plus an unrelated host running SSH
+	for port := uint32(1); port <= 5; port++ {
+		_, err := manager.PutScan(ctx, &scan_manager.ScanResult{
+			IP: "10.1.1.1", Port: port, Service: "ssh", Timestamp: 100, Response: fmt.Sprintf("port %d", port), DataVersion: 2,
+		})
+		if err != nil {
+			t.Fatalf("Failed to put scan: %v", err)
+		}
+	}
+
+	_, err = manager.PutScan(ctx, &scan_manager.ScanResult{
+		IP: "10.1.1.2", Port: 80, Service: "http", Timestamp: 100, Response: "other host", DataVersion: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to put scan: %v", err)
+	}
+
+	result, err := manager.GetScan(ctx, scan_manager.ScanKey{IP: "10.1.1.1", Port: 3, Service: "ssh"})
+	if err != nil {
+		t.Fatalf("Failed to get scan: %v", err)
+	}
+
+	if result.Response != "port 3" {
+		t.Errorf("Expected 'port 3', got '%s'", result.Response)
+	}
+
+	_, err = manager.GetScan(ctx, scan_manager.ScanKey{IP: "10.1.1.1", Port: 99, Service: "ssh"})
+	if !errors.Is(err, scan_manager.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Page through the host two results at a time
+	var listed []*scan_manager.ScanResult
+	opts := scan_manager.ListOptions{Limit: 2}
+	for {
+		page, err := manager.ListScansByIP(ctx, "10.1.1.1", opts)
+		if err != nil {
+			t.Fatalf("Failed to list scans by IP: %v", err)
+		}
+
+		listed = append(listed, page.Results...)
+		if page.NextPageToken == "" {
+			break
+		}
+		opts.PageToken = page.NextPageToken
+	}
+
+	if len(listed) != 5 {
+		t.Errorf("Expected 5 scans for 10.1.1.1, got %d", len(listed))
+	}
+
+	page, err := manager.ListScansByService(ctx, "http", scan_manager.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list scans by service: %v", err)
+	}
+
+	if len(page.Results) != 1 || page.Results[0].IP != "10.1.1.2" {
+		t.Errorf("Expected only 10.1.1.2 running http, got %+v", page.Results)
+	}
+
+	page, err = manager.ListScansByIP(ctx, "10.1.1.1", scan_manager.ListOptions{Port: 4})
+	if err != nil {
+		t.Fatalf("Failed to list scans by IP and port: %v", err)
+	}
+
+	if len(page.Results) != 1 || page.Results[0].Port != 4 {
+		t.Errorf("Expected only port 4 on 10.1.1.1, got %+v", page.Results)
+	}
+
+	page, err = manager.ListScansByPort(ctx, 80, scan_manager.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list scans by port: %v", err)
+	}
+
+	if len(page.Results) != 1 || page.Results[0].Service != "http" {
+		t.Errorf("Expected only http on port 80, got %+v", page.Results)
+	}
+}
+
+func TestIntegration_History(t *testing.T) {
+	client, cleanup := setupDynamoDB(t)
+	defer cleanup()
+
+	store, err := dynamodbstore.NewDynamoDB(&dynamodbstore.DynamoDBConfig{
+		Client:       client,
+		HistoryTable: dynamodbstore.DefaultHistoryTable,
+		RecordStale:  true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create DynamoDB store: %v", err)
+	}
+
+	manager, err := scan_manager.NewScanManager(&scan_manager.ScanManagerConfig{Repo: store})
+	if err != nil {
+		t.Fatalf("Failed to create scan manager: %v", err)
+	}
+
+	ctx := context.Background()
+	key := scan_manager.ScanKey{IP: "172.16.0.9", Port: 22, Service: "ssh"}
+
+	// Arrives out of order: 300 is accepted, 200 is stale, 400 is accepted
+	for _, ts := range []int64{100, 300, 200, 400} {
+		_, err := manager.PutScan(ctx, &scan_manager.ScanResult{
+			IP: key.IP, Port: key.Port, Service: key.Service, Timestamp: ts, Response: fmt.Sprintf("response %d", ts), DataVersion: 2,
+		})
+		if err != nil {
+			t.Fatalf("Failed to put scan at %d: %v", ts, err)
+		}
+	}
+
+	entries, err := manager.ScanHistory(ctx, key, 150, 400)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+
+	expected := []struct {
+		ts    int64
+		stale bool
+	}{{200, true}, {300, false}, {400, false}}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d history entries, got %d", len(expected), len(entries))
+	}
+
+	for i, exp := range expected {
+		if entries[i].Timestamp != exp.ts || entries[i].Stale != exp.stale {
+			t.Errorf("Entry %d: expected ts=%d stale=%v, got ts=%d stale=%v",
+				i, exp.ts, exp.stale, entries[i].Timestamp, entries[i].Stale)
+		}
+	}
+}
+
+// recordingNotifier captures change events
+type recordingNotifier struct {
+	events []*scan_manager.ChangeEvent
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, event *scan_manager.ChangeEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestIntegration_ChangeEvents(t *testing.T) {
+	client, cleanup := setupDynamoDB(t)
+	defer cleanup()
+
+	store, err := dynamodbstore.NewDynamoDB(&dynamodbstore.DynamoDBConfig{Client: client})
+	if err != nil {
+		t.Fatalf("Failed to create DynamoDB store: %v", err)
+	}
+
+	notifier := &recordingNotifier{}
+	manager, err := scan_manager.NewScanManager(&scan_manager.ScanManagerConfig{Repo: store, Notifier: notifier})
+	if err != nil {
+		t.Fatalf("Failed to create scan manager: %v", err)
+	}
+
+	// Only the third scan is newer with a different response
+	scans := []struct {
+		ts   int64
+		resp string
+	}{{100, "banner v1"}, {200, "banner v1"}, {300, "banner v2"}, {250, "banner v3"}}
+
+	for _, scan := range scans {
+		_, err := manager.PutScan(context.Background(), &scan_manager.ScanResult{
+			IP: "172.16.0.20", Port: 80, Service: "http", Timestamp: scan.ts, Response: scan.resp, DataVersion: 2,
+		})
+		if err != nil {
+			t.Fatalf("Failed to put scan at %d: %v", scan.ts, err)
+		}
+	}
+
+	if len(notifier.events) != 1 {
+		t.Fatalf("Expected 1 change event, got %d", len(notifier.events))
+	}
+
+	event := notifier.events[0]
+	if event.OldResponse != "banner v1" || event.NewResponse != "banner v2" || event.OldTimestamp != 200 || event.NewTimestamp != 300 {
+		t.Errorf("Unexpected change event %+v", event)
+	}
+}
+
+func TestIntegration_Conformance(t *testing.T) {
+	client, cleanup := setupDynamoDB(t)
+	defer cleanup()
+
+	// Every test gets its own tables, so listings only see its scans
+	tables := 0
+	repotest.Run(t, func(t *testing.T) scan_manager.Repository {
+		tables++
+		table := fmt.Sprintf("conformance-%d", tables)
+
+		migrator, err := dynamodbstore.NewMigrator(&dynamodbstore.MigratorConfig{
+			Client:       client,
+			Table:        table,
+			HistoryTable: table + "-history",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create migrator: %v", err)
+		}
+
+		if _, err := migrator.Migrate(context.Background()); err != nil {
+			t.Fatalf("Failed to migrate tables: %v", err)
+		}
+
+		store, err := dynamodbstore.NewDynamoDB(&dynamodbstore.DynamoDBConfig{
+			Client:       client,
+			Table:        table,
+			HistoryTable: table + "-history",
+			RecordStale:  true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create DynamoDB store: %v", err)
+		}
+
+		return store
+	})
+}
+
+func TestIntegration_Migrate(t *testing.T) {
+	client, cleanup := setupDynamoDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	migrator, err := dynamodbstore.NewMigrator(&dynamodbstore.MigratorConfig{Client: client})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	// setupDynamoDB already migrated, so the schema is current
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+
+	latest := dynamodbstore.Migrations()[len(dynamodbstore.Migrations())-1].Version
+	if version != latest {
+		t.Errorf("Expected schema version %d, got %d", latest, version)
+	}
+
+	// Re-running is a no-op
+	applied, err := migrator.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("Failed to re-run migrations: %v", err)
+	}
+
+	if len(applied) != 0 {
+		t.Errorf("Expected no migrations to be applied, got %d", len(applied))
+	}
+
+	// Tables that already exist, e.g. from docker-compose, are adopted
+	fresh, err := dynamodbstore.NewMigrator(&dynamodbstore.MigratorConfig{Client: client, Table: "adopted-scans"})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	if _, err := fresh.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate with an existing history table: %v", err)
+	}
+}
+
+func TestIntegration_BatchedWrites(t *testing.T) {
+	client, cleanup := setupDynamoDB(t)
+	defer cleanup()
+
+	store, err := dynamodbstore.NewDynamoDB(&dynamodbstore.DynamoDBConfig{
+		Client:       client,
+		HistoryTable: dynamodbstore.DefaultHistoryTable,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create DynamoDB store: %v", err)
+	}
+
+	ctx := context.Background()
+	scan := func(ip string, ts int64) *scan_manager.ScanResult {
+		return &scan_manager.ScanResult{
+			IP: ip, Port: 22, Service: "ssh", Timestamp: ts, Response: fmt.Sprintf("response %d", ts), DataVersion: 2,
+		}
+	}
+
+	puts, err := store.PutBatch(ctx, []*scan_manager.ScanResult{scan("10.2.0.1", 100), scan("10.2.0.2", 100), scan("10.2.0.3", 100)})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	for i, put := range puts {
+		if put.Outcome != scan_manager.Inserted {
+			t.Errorf("Expected scan %d to be inserted, got %s", i, put.Outcome)
+		}
+	}
+
+	// Newer, stale, duplicate and new scans in one batch
+	puts, err = store.PutBatch(ctx, []*scan_manager.ScanResult{
+		scan("10.2.0.1", 200), scan("10.2.0.2", 50), scan("10.2.0.3", 100), scan("10.2.0.4", 100),
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	expected := []scan_manager.PutOutcome{scan_manager.Updated, scan_manager.StaleIgnored, scan_manager.Duplicate, scan_manager.Inserted}
+	for i, put := range puts {
+		if put.Outcome != expected[i] {
+			t.Errorf("Expected scan %d to be %s, got %s", i, expected[i], put.Outcome)
+		}
+	}
+
+	if puts[0].Previous == nil || puts[0].Previous.Timestamp != 100 {
+		t.Errorf("Expected the update to report the previous scan, got %+v", puts[0].Previous)
+	}
+
+	result, err := store.Get(ctx, scan_manager.ScanKey{IP: "10.2.0.2", Port: 22, Service: "ssh"})
+	if err != nil {
+		t.Fatalf("Failed to get scan: %v", err)
+	}
+
+	if result.Timestamp != 100 {
+		t.Errorf("Expected the stale scan to be ignored, got timestamp %d", result.Timestamp)
+	}
+
+	entries, err := store.History(ctx, scan_manager.ScanKey{IP: "10.2.0.1", Port: 22, Service: "ssh"}, 0, 1000)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 history entries, got %d", len(entries))
+	}
+}
+
+func TestIntegration_InjectedFaults(t *testing.T) {
+	client, cleanup := setupDynamoDB(t)
+	defer cleanup()
+
+	store, err := dynamodbstore.NewDynamoDB(&dynamodbstore.DynamoDBConfig{Client: client})
+	if err != nil {
+		t.Fatalf("Failed to create DynamoDB store: %v", err)
+	}
+
+	manager, err := scan_manager.NewScanManager(&scan_manager.ScanManagerConfig{Repo: store})
+	if err != nil {
+		t.Fatalf("Failed to create scan manager: %v", err)
+	}
+
+	generator, err := workload.NewGenerator(&workload.GeneratorConfig{
+		Seed:    1,
+		V2Ratio: 0.5,
+		Faults: workload.FaultConfig{
+			Backdated: 10, Duplicate: 10, Conflict: 10, UnknownVersion: 5, NullData: 5, Truncated: 5, Oversize: 2,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	ctx := context.Background()
+	counts := map[string]int{}
+
+	for i := range 300 {
+		msg, err := generator.NextMessage(int64(1_700_000_000 + i))
+		if err != nil {
+			t.Fatalf("Failed to generate message: %v", err)
+		}
+		counts[msg.Fault]++
+
+		result, err := serializer.ParseScanMessage(msg.Data)
+		switch msg.Fault {
+		case workload.FaultUnknownVersion, workload.FaultNullData, workload.FaultTruncated:
+			if !errors.Is(err, serializer.ErrInvalidMessage) {
+				t.Errorf("Expected %s message to be invalid, got %v", msg.Fault, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to parse %q message: %v", msg.Fault, err)
+		}
+
+		outcome, err := manager.PutScan(ctx, result)
+		if msg.Fault == workload.FaultOversize {
+			if !errors.Is(err, scan_manager.ErrInvalidScan) {
+				t.Errorf("Expected oversize scan to be rejected as invalid, got %v", err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to put %q scan: %v", msg.Fault, err)
+		}
+
+		switch msg.Fault {
+		case "":
+			if !outcome.Stored() {
+				t.Errorf("Expected valid scan to be stored, got %s", outcome)
+			}
+		case workload.FaultBackdated, workload.FaultConflict:
+			if outcome != scan_manager.StaleIgnored {
+				t.Errorf("Expected %s scan to be stale, got %s", msg.Fault, outcome)
+			}
+		case workload.FaultDuplicate:
+			// Stale when a newer scan for the key arrived since the original
+			if outcome != scan_manager.Duplicate && outcome != scan_manager.StaleIgnored {
+				t.Errorf("Expected duplicate scan to be a duplicate, got %s", outcome)
+			}
+		}
+	}
+
+	t.Logf("Injected faults: %v", counts)
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/censys/scan-takehome/internal/logging"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// ScanReader is the read side of the scan manager served by the API
+type ScanReader interface {
+	GetScan(ctx context.Context, key scan_manager.ScanKey) (*scan_manager.ScanResult, error)
+	ListScansByIP(ctx context.Context, ip string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error)
+	ListScansByService(ctx context.Context, service string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error)
+	ListScansByPort(ctx context.Context, port uint32, opts scan_manager.ListOptions) (*scan_manager.ListPage, error)
+}
+
+type APIConfig struct {
+	Manager ScanReader
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+type api struct {
+	manager ScanReader
+	logger  *slog.Logger
+	mux     *http.ServeMux
+}
+
+type scanResponse struct {
+	IP          string `json:"ip"`
+	Port        uint32 `json:"port"`
+	Service     string `json:"service"`
+	Timestamp   int64  `json:"timestamp"`
+	Response    string `json:"response"`
+	DataVersion int    `json:"data_version"`
+}
+
+type listResponse struct {
+	Results       []scanResponse `json:"results"`
+	NextPageToken string         `json:"next_page_token,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewAPI(cfg *APIConfig) (*api, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Manager == nil {
+		return nil, errors.New("manager is nil")
+	}
+
+	a := &api{
+		manager: cfg.Manager,
+		logger:  logging.OrDefault(cfg.Logger),
+		mux:     http.NewServeMux(),
+	}
+
+	a.mux.HandleFunc("GET /v1/scans/{ip}/{port}/{service}", a.getScan)
+	a.mux.HandleFunc("GET /v1/hosts/{ip}/scans", a.listByIP)
+	a.mux.HandleFunc("GET /v1/services/{service}/scans", a.listByService)
+	a.mux.HandleFunc("GET /v1/ports/{port}/scans", a.listByPort)
+
+	return a, nil
+}
+
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+// getScan returns the latest scan for ip#port#service
+func (a *api) getScan(w http.ResponseWriter, r *http.Request) {
+	port, err := parsePort(r.PathValue("port"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := a.manager.GetScan(r.Context(), scan_manager.ScanKey{
+		IP:      r.PathValue("ip"),
+		Port:    port,
+		Service: r.PathValue("service"),
+	})
+	if err != nil {
+		a.writeManagerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toScanResponse(result))
+}
+
+// listByIP returns every service seen on a host
+func (a *api) listByIP(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := a.manager.ListScansByIP(r.Context(), r.PathValue("ip"), opts)
+	if err != nil {
+		a.writeManagerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toListResponse(page))
+}
+
+// listByService returns every host running a service, optionally on one port
+func (a *api) listByService(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := a.manager.ListScansByService(r.Context(), r.PathValue("service"), opts)
+	if err != nil {
+		a.writeManagerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toListResponse(page))
+}
+
+// listByPort returns every service seen on a port across all hosts
+func (a *api) listByPort(w http.ResponseWriter, r *http.Request) {
+	port, err := parsePort(r.PathValue("port"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := a.manager.ListScansByPort(r.Context(), port, opts)
+	if err != nil {
+		a.writeManagerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toListResponse(page))
+}
+
+func parsePort(value string) (uint32, error) {
+	port, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", value)
+	}
+
+	return uint32(port), nil
+}
+
+func parseListOptions(r *http.Request) (scan_manager.ListOptions, error) {
+	query := r.URL.Query()
+	opts := scan_manager.ListOptions{
+		PageToken: query.Get("page_token"),
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > scan_manager.MaxListLimit {
+			return opts, fmt.Errorf("invalid limit %q, must be between 1 and %d", value, scan_manager.MaxListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	if value := query.Get("port"); value != "" {
+		port, err := parsePort(value)
+		if err != nil {
+			return opts, err
+		}
+		opts.Port = port
+	}
+
+	return opts, nil
+}
+
+func toScanResponse(result *scan_manager.ScanResult) scanResponse {
+	return scanResponse{
+		IP:          result.IP,
+		Port:        result.Port,
+		Service:     result.Service,
+		Timestamp:   result.Timestamp,
+		Response:    result.Response,
+		DataVersion: result.DataVersion,
+	}
+}
+
+func toListResponse(page *scan_manager.ListPage) listResponse {
+	resp := listResponse{
+		Results:       make([]scanResponse, 0, len(page.Results)),
+		NextPageToken: page.NextPageToken,
+	}
+
+	for _, result := range page.Results {
+		resp.Results = append(resp.Results, toScanResponse(result))
+	}
+
+	return resp
+}
+
+// writeManagerError maps repository errors onto HTTP status codes
+func (a *api) writeManagerError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, scan_manager.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, scan_manager.ErrInvalidPageToken):
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		a.logger.ErrorContext(r.Context(), "failed to serve request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Any("error", err),
+		)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Warn("failed to write response", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/memory"
+)
+
+func newTestAPI(t *testing.T) *api {
+	repo, err := memory.NewMemory(&memory.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+
+	manager, err := scan_manager.NewScanManager(&scan_manager.ScanManagerConfig{Repo: repo})
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+
+	scans := []*scan_manager.ScanResult{
+		{IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: 100, Response: "ssh banner", DataVersion: 2},
+		{IP: "10.0.0.1", Port: 80, Service: "HTTP", Timestamp: 100, Response: "http banner", DataVersion: 1},
+		{IP: "10.0.0.2", Port: 8080, Service: "HTTP", Timestamp: 100, Response: "alt http", DataVersion: 2},
+	}
+	for _, scan := range scans {
+		if _, err := repo.Put(context.Background(), scan); err != nil {
+			t.Fatalf("failed to seed scan: %v", err)
+		}
+	}
+
+	a, err := NewAPI(&APIConfig{Manager: manager})
+	if err != nil {
+		t.Fatalf("failed to create api: %v", err)
+	}
+
+	return a
+}
+
+func doRequest(t *testing.T, a *api, path string, body any) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+
+	if body != nil {
+		if err := json.NewDecoder(rec.Body).Decode(body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+
+	return rec.Code
+}
+
+func TestNewAPI(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewAPI(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if manager is nil", func(t *testing.T) {
+		_, err := NewAPI(&APIConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestGetScan(t *testing.T) {
+	a := newTestAPI(t)
+
+	t.Run("should return the stored scan", func(t *testing.T) {
+		var resp scanResponse
+		code := doRequest(t, a, "/v1/scans/10.0.0.1/22/SSH", &resp)
+
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+
+		if resp.Response != "ssh banner" || resp.DataVersion != 2 {
+			t.Errorf("unexpected response %+v", resp)
+		}
+	})
+
+	t.Run("should return 404 for unknown keys", func(t *testing.T) {
+		var resp errorResponse
+		if code := doRequest(t, a, "/v1/scans/10.0.0.1/443/HTTPS", &resp); code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", code)
+		}
+	})
+
+	t.Run("should return 400 for an invalid port", func(t *testing.T) {
+		var resp errorResponse
+		if code := doRequest(t, a, "/v1/scans/10.0.0.1/99999/SSH", &resp); code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", code)
+		}
+	})
+}
+
+func TestListScans(t *testing.T) {
+	a := newTestAPI(t)
+
+	t.Run("should list services for an ip", func(t *testing.T) {
+		var resp listResponse
+		code := doRequest(t, a, "/v1/hosts/10.0.0.1/scans", &resp)
+
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+
+		if len(resp.Results) != 2 {
+			t.Errorf("expected 2 results, got %d", len(resp.Results))
+		}
+	})
+
+	t.Run("should page through a service", func(t *testing.T) {
+		var first listResponse
+		if code := doRequest(t, a, "/v1/services/HTTP/scans?limit=1", &first); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+
+		if len(first.Results) != 1 || first.NextPageToken == "" {
+			t.Fatalf("expected one result and a next page token, got %+v", first)
+		}
+
+		var second listResponse
+		if code := doRequest(t, a, "/v1/services/HTTP/scans?limit=1&page_token="+first.NextPageToken, &second); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+
+		if len(second.Results) != 1 || second.Results[0].IP == first.Results[0].IP {
+			t.Errorf("expected a different second result, got %+v", second)
+		}
+	})
+
+	t.Run("should filter a service by port", func(t *testing.T) {
+		var resp listResponse
+		doRequest(t, a, "/v1/services/HTTP/scans?port=8080", &resp)
+
+		if len(resp.Results) != 1 || resp.Results[0].IP != "10.0.0.2" {
+			t.Errorf("expected only 10.0.0.2, got %+v", resp.Results)
+		}
+	})
+
+	t.Run("should list scans on a port", func(t *testing.T) {
+		var resp listResponse
+		doRequest(t, a, "/v1/ports/80/scans", &resp)
+
+		if len(resp.Results) != 1 || resp.Results[0].Service != "HTTP" {
+			t.Errorf("expected only the HTTP scan on 10.0.0.1, got %+v", resp.Results)
+		}
+
+		var bad errorResponse
+		if code := doRequest(t, a, "/v1/ports/70000/scans", &bad); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for an out of range port, got %d", code)
+		}
+	})
+
+	t.Run("should return 400 for bad paging parameters", func(t *testing.T) {
+		for _, path := range []string{
+			"/v1/services/HTTP/scans?limit=0",
+			"/v1/services/HTTP/scans?port=abc",
+			"/v1/services/HTTP/scans?page_token=!!",
+		} {
+			var resp errorResponse
+			if code := doRequest(t, a, path, &resp); code != http.StatusBadRequest {
+				t.Errorf("expected 400 for %s, got %d", path, code)
+			}
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package deadletter
+
+import (
+	"context"
+	"time"
+)
+
+// Letter is a message that failed permanently, with enough context to
+// inspect or replay it later
+type Letter struct {
+	MessageID   string            `json:"message_id"`
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	PublishTime time.Time         `json:"publish_time"`
+	Error       string            `json:"error"`
+	FailedAt    time.Time         `json:"failed_at"`
+}
+
+// Sink receives messages that can never be processed. Send must only return
+// nil once the letter is durably recorded, as the original message is acked.
+type Sink interface {
+	Send(ctx context.Context, letter *Letter) error
+	Close() error
+}
@@ -0,0 +1,177 @@
+package deadletter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func testLetter() *Letter {
+	return &Letter{
+		MessageID:   "msg-1",
+		Data:        []byte(`{"invalid json`),
+		Attributes:  map[string]string{"origin": "scanner"},
+		PublishTime: time.Unix(1700000000, 0),
+		Error:       "invalid scan message: unexpected end of JSON input",
+		FailedAt:    time.Unix(1700000005, 0),
+	}
+}
+
+func TestNewFileSink(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewFileSink(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if path is empty", func(t *testing.T) {
+		_, err := NewFileSink(&FileSinkConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestFileSink_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+
+	sink, err := NewFileSink(&FileSinkConfig{Path: path})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := sink.Send(context.Background(), testLetter()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open dead-letter file: %v", err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var letter Letter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			t.Fatalf("failed to decode line: %v", err)
+		}
+
+		if string(letter.Data) != `{"invalid json` {
+			t.Errorf("expected raw payload to round trip, got %q", letter.Data)
+		}
+
+		if letter.Attributes["origin"] != "scanner" {
+			t.Errorf("expected attributes to round trip, got %v", letter.Attributes)
+		}
+		lines++
+	}
+
+	if lines != 2 {
+		t.Errorf("expected 2 lines, got %d", lines)
+	}
+}
+
+func TestNewPubSubSink(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewPubSubSink(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if topic is nil", func(t *testing.T) {
+		_, err := NewPubSubSink(&PubSubSinkConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestPubSubSink_Send(t *testing.T) {
+	ctx := context.Background()
+
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial pstest: %v", err)
+	}
+	defer conn.Close()
+
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	topic, err := client.CreateTopic(ctx, "scan-dead-letter")
+	if err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	sink, err := NewPubSubSink(&PubSubSinkConfig{Topic: topic})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.Send(ctx, testLetter()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(msgs))
+	}
+
+	msg := msgs[0]
+	if string(msg.Data) != `{"invalid json` {
+		t.Errorf("expected raw payload, got %q", msg.Data)
+	}
+
+	if msg.Attributes["origin"] != "scanner" {
+		t.Errorf("expected original attributes to be kept, got %v", msg.Attributes)
+	}
+
+	if msg.Attributes[AttrOriginalMessageID] != "msg-1" || msg.Attributes[AttrError] == "" {
+		t.Errorf("expected dead-letter attributes, got %v", msg.Attributes)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Run("should keep short values", func(t *testing.T) {
+		if got := truncate("invalid scan", maxAttributeValue); got != "invalid scan" {
+			t.Errorf("expected the value unchanged, got %q", got)
+		}
+	})
+
+	t.Run("should cut long values to the limit on a rune boundary", func(t *testing.T) {
+		got := truncate(strings.Repeat("é", maxAttributeValue), maxAttributeValue)
+
+		if len(got) > maxAttributeValue || !utf8.ValidString(got) || !strings.HasSuffix(got, "…") {
+			t.Errorf("expected at most %d bytes of valid UTF-8 ending in an ellipsis, got %d bytes", maxAttributeValue, len(got))
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+type FileSinkConfig struct {
+	// Path of the JSONL file, created if missing and appended to otherwise
+	Path string
+}
+
+// fileSink appends one JSON encoded Letter per line to a local file
+type fileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(cfg *FileSinkConfig) (*fileSink, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Path == "" {
+		return nil, errors.New("path is empty")
+	}
+
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+
+	return &fileSink{file: file}, nil
+}
+
+func (f *fileSink) Send(ctx context.Context, letter *Letter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+
+	if err := f.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dead-letter file: %w", err)
+	}
+
+	return nil
+}
+
+func (f *fileSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
@@ -0,0 +1,89 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// Attributes added to the republished message alongside the original ones
+const (
+	AttrError             = "dead_letter_error"
+	AttrOriginalMessageID = "dead_letter_message_id"
+	AttrOriginalPublish   = "dead_letter_publish_time"
+	AttrFailedAt          = "dead_letter_failed_at"
+)
+
+// maxAttributeValue is the longest attribute value Pub/Sub accepts, in bytes
+const maxAttributeValue = 1024
+
+type PubSubSinkConfig struct {
+	Topic *pubsub.Topic
+}
+
+// pubSubSink republishes the raw payload to a dead-letter topic, keeping the
+// original attributes and recording the failure in extra attributes
+type pubSubSink struct {
+	topic *pubsub.Topic
+}
+
+func NewPubSubSink(cfg *PubSubSinkConfig) (*pubSubSink, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Topic == nil {
+		return nil, errors.New("topic is nil")
+	}
+
+	return &pubSubSink{topic: cfg.Topic}, nil
+}
+
+func (p *pubSubSink) Send(ctx context.Context, letter *Letter) error {
+	attrs := make(map[string]string, len(letter.Attributes)+4)
+	for k, v := range letter.Attributes {
+		attrs[k] = v
+	}
+
+	// An oversized attribute would fail every publish, and with it every
+	// redelivery. The consumer logs the full error.
+	attrs[AttrError] = truncate(letter.Error, maxAttributeValue)
+	attrs[AttrOriginalMessageID] = letter.MessageID
+	attrs[AttrOriginalPublish] = letter.PublishTime.UTC().Format(time.RFC3339Nano)
+	attrs[AttrFailedAt] = letter.FailedAt.UTC().Format(time.RFC3339Nano)
+
+	_, err := p.topic.Publish(ctx, &pubsub.Message{
+		Data:       letter.Data,
+		Attributes: attrs,
+	}).Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to publish dead letter: %w", err)
+	}
+
+	return nil
+}
+
+// truncate shortens s to at most n bytes, marking the cut with an ellipsis
+// and never splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	const ellipsis = "…"
+	cut := n - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return s[:cut] + ellipsis
+}
+
+func (p *pubSubSink) Close() error {
+	p.topic.Stop()
+	return nil
+}
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCheckTimeout = 2 * time.Second
+
+// Check reports whether a dependency is reachable
+type Check func(ctx context.Context) error
+
+type checkResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Health serves liveness and readiness probes. Readiness requires the
+// process to be marked ready and every registered check to pass.
+type Health struct {
+	ready   atomic.Bool
+	mu      sync.RWMutex
+	checks  map[string]Check
+	timeout time.Duration
+}
+
+func NewHealth() *Health {
+	return &Health{
+		checks:  make(map[string]Check),
+		timeout: defaultCheckTimeout,
+	}
+}
+
+// AddCheck registers a readiness check under name
+func (h *Health) AddCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks[name] = check
+}
+
+// SetReady marks whether the process is accepting work. It starts false and
+// should be flipped back to false when a shutdown drain begins.
+func (h *Health) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// LivenessHandler reports the process is alive and serving HTTP
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, checkResponse{Status: "ok"})
+	})
+}
+
+// ReadinessHandler runs every check concurrently and reports 503 unless the
+// process is ready and all checks pass
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.ready.Load() {
+			writeJSON(w, http.StatusServiceUnavailable, checkResponse{Status: "not ready"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+		defer cancel()
+
+		results := h.runChecks(ctx)
+
+		resp := checkResponse{Status: "ok", Checks: make(map[string]string, len(results))}
+		status := http.StatusOK
+		for name, err := range results {
+			if err != nil {
+				resp.Checks[name] = err.Error()
+				resp.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			resp.Checks[name] = "ok"
+		}
+
+		writeJSON(w, status, resp)
+	})
+}
+
+func (h *Health) runChecks(ctx context.Context) map[string]error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]error, len(h.checks))
+
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := check(ctx)
+
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Warn("failed to write health response", slog.Any("error", err))
+	}
+}
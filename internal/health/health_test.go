@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func probe(t *testing.T, h http.Handler) (int, checkResponse) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var resp checkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	return rec.Code, resp
+}
+
+func TestLiveness(t *testing.T) {
+	h := NewHealth()
+
+	if code, _ := probe(t, h.LivenessHandler()); code != http.StatusOK {
+		t.Errorf("expected 200, got %d", code)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	t.Run("should not be ready until marked ready", func(t *testing.T) {
+		h := NewHealth()
+
+		if code, _ := probe(t, h.ReadinessHandler()); code != http.StatusServiceUnavailable {
+			t.Errorf("expected 503, got %d", code)
+		}
+	})
+
+	t.Run("should be ready when all checks pass", func(t *testing.T) {
+		h := NewHealth()
+		h.AddCheck("repository", func(ctx context.Context) error { return nil })
+		h.SetReady(true)
+
+		code, resp := probe(t, h.ReadinessHandler())
+		if code != http.StatusOK {
+			t.Errorf("expected 200, got %d", code)
+		}
+
+		if resp.Checks["repository"] != "ok" {
+			t.Errorf("expected repository check to be ok, got %v", resp.Checks)
+		}
+	})
+
+	t.Run("should not be ready when a check fails", func(t *testing.T) {
+		h := NewHealth()
+		h.AddCheck("repository", func(ctx context.Context) error { return nil })
+		h.AddCheck("subscription", func(ctx context.Context) error { return errors.New("unreachable") })
+		h.SetReady(true)
+
+		code, resp := probe(t, h.ReadinessHandler())
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("expected 503, got %d", code)
+		}
+
+		if resp.Checks["subscription"] != "unreachable" {
+			t.Errorf("expected subscription check error, got %v", resp.Checks)
+		}
+	})
+
+	t.Run("should not be ready once draining", func(t *testing.T) {
+		h := NewHealth()
+		h.SetReady(true)
+		h.SetReady(false)
+
+		if code, _ := probe(t, h.ReadinessHandler()); code != http.StatusServiceUnavailable {
+			t.Errorf("expected 503, got %d", code)
+		}
+	})
+}
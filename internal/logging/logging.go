@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	JSON = "json"
+	Text = "text"
+)
+
+type LoggerConfig struct {
+	// Output defaults to os.Stderr
+	Output io.Writer
+	// Format is JSON or Text. Defaults to JSON.
+	Format string
+	// Level defaults to slog.LevelInfo
+	Level slog.Leveler
+	// Sampling is optional and thins out repeated Info and Debug records
+	Sampling *SamplingConfig
+}
+
+// NewLogger builds a logger that also records attributes added to the
+// context with WithAttrs and the trace and span IDs of the context's span,
+// when logged through the *Context methods
+func NewLogger(cfg *LoggerConfig) (*slog.Logger, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	output := cfg.Output
+	if output == nil {
+		output = os.Stderr
+	}
+
+	level := cfg.Level
+	if level == nil {
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch cfg.Format {
+	case "", JSON:
+		handler = slog.NewJSONHandler(output, opts)
+	case Text:
+		handler = slog.NewTextHandler(output, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+
+	if cfg.Sampling != nil {
+		handler = newSamplingHandler(handler, cfg.Sampling)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("unknown log level: %s", value)
+	}
+
+	return level, nil
+}
+
+// OrDefault returns logger, or slog.Default() when it is nil. Constructors
+// use it so a logger is always optional.
+func OrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+
+	return logger
+}
+
+type contextKey struct{}
+
+// WithAttrs returns a context carrying attrs, which loggers built by
+// NewLogger add to every record logged with it. This is how per-message
+// fields such as the Pub/Sub message ID reach logs written deep in the
+// manager and repository.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+
+	return context.WithValue(ctx, contextKey{}, merged)
+}
+
+// contextHandler adds the attributes from WithAttrs and the current span to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewLogger(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error for an unknown format", func(t *testing.T) {
+		_, err := NewLogger(&LoggerConfig{Format: "xml"})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should write JSON above the level", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := NewLogger(&LoggerConfig{Output: &buf, Level: slog.LevelWarn})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		logger.Info("dropped")
+		logger.Warn("kept", "key", "value")
+
+		var record map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("expected a single JSON record, got %q: %v", buf.String(), err)
+		}
+
+		if record["msg"] != "kept" || record["key"] != "value" {
+			t.Errorf("unexpected record %v", record)
+		}
+	})
+
+	t.Run("should add context attributes", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, _ := NewLogger(&LoggerConfig{Output: &buf, Format: Text})
+
+		ctx := WithAttrs(context.Background(), slog.String("message_id", "m-1"))
+		ctx = WithAttrs(ctx, slog.String("key", "10.0.0.1#22#ssh"))
+		logger.With("component", "test").InfoContext(ctx, "stored")
+
+		for _, want := range []string{"message_id=m-1", "key=10.0.0.1#22#ssh", "component=test"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("expected %q in %q", want, buf.String())
+			}
+		}
+	})
+
+	t.Run("should add the span's trace and span IDs", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, _ := NewLogger(&LoggerConfig{Output: &buf, Format: Text})
+
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{1},
+			SpanID:  trace.SpanID{2},
+		}))
+		logger.InfoContext(ctx, "stored")
+
+		for _, want := range []string{"trace_id=01000000000000000000000000000000", "span_id=0200000000000000"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("expected %q in %q", want, buf.String())
+			}
+		}
+	})
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("debug")
+	if err != nil || level != slog.LevelDebug {
+		t.Errorf("expected debug, got %v, %v", level, err)
+	}
+
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	t.Run("should log the first records then every nth", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, _ := NewLogger(&LoggerConfig{
+			Output:   &buf,
+			Sampling: &SamplingConfig{First: 2, Thereafter: 3, Tick: time.Hour},
+		})
+
+		for range 8 {
+			logger.Info("scan stored")
+		}
+		logger.Info("rare")
+
+		// Records 1, 2, 5 and 8 of "scan stored", and "rare"
+		if lines := strings.Count(buf.String(), "\n"); lines != 5 {
+			t.Errorf("expected 5 records, got %d:\n%s", lines, buf.String())
+		}
+	})
+
+	t.Run("should never sample warnings", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, _ := NewLogger(&LoggerConfig{
+			Output:   &buf,
+			Sampling: &SamplingConfig{First: 1, Tick: time.Hour},
+		})
+
+		for range 5 {
+			logger.Warn("repository failing")
+		}
+
+		if lines := strings.Count(buf.String(), "\n"); lines != 5 {
+			t.Errorf("expected 5 records, got %d", lines)
+		}
+	})
+
+	t.Run("should reset each tick", func(t *testing.T) {
+		state := newSamplingHandler(slog.DiscardHandler, &SamplingConfig{First: 1, Tick: time.Second}).state
+		now := time.Now()
+
+		if !state.allow("scan stored", now) || state.allow("scan stored", now) {
+			t.Fatalf("expected only the first record in a tick")
+		}
+
+		if !state.allow("scan stored", now.Add(time.Second)) {
+			t.Errorf("expected the first record of the next tick")
+		}
+	})
+}
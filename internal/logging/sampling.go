@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultSampleTick is the sampling window used when SamplingConfig.Tick is unset
+const DefaultSampleTick = time.Second
+
+// SamplingConfig logs the first First records with a given message in each
+// Tick, then every Thereafter-th one. Warnings and errors are never sampled,
+// and neither are messages logged less than First times a tick, so only
+// high-volume records such as per-scan successes are thinned out.
+type SamplingConfig struct {
+	First int
+	// Thereafter of zero drops everything past First until the next tick
+	Thereafter int
+	// Tick defaults to DefaultSampleTick
+	Tick time.Duration
+}
+
+type samplingHandler struct {
+	next  slog.Handler
+	state *samplingState
+}
+
+// samplingState is shared by every handler derived with WithAttrs and
+// WithGroup, so a message is counted the same however its logger was built
+type samplingState struct {
+	first      int
+	thereafter int
+	tick       time.Duration
+
+	mu        sync.Mutex
+	tickStart time.Time
+	counts    map[string]int
+}
+
+func newSamplingHandler(next slog.Handler, cfg *SamplingConfig) *samplingHandler {
+	tick := cfg.Tick
+	if tick <= 0 {
+		tick = DefaultSampleTick
+	}
+
+	return &samplingHandler{
+		next: next,
+		state: &samplingState{
+			first:      cfg.First,
+			thereafter: cfg.Thereafter,
+			tick:       tick,
+			counts:     make(map[string]int),
+		},
+	}
+}
+
+func (h *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level < slog.LevelWarn && !h.state.allow(record.Message, record.Time) {
+		return nil
+	}
+
+	return h.next.Handle(ctx, record)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{next: h.next.WithAttrs(attrs), state: h.state}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{next: h.next.WithGroup(name), state: h.state}
+}
+
+// allow counts a record with message at now and reports whether to log it
+func (s *samplingState) allow(message string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Messages are constant strings, so resetting each tick also bounds the map
+	if now.Sub(s.tickStart) >= s.tick {
+		s.tickStart = now
+		clear(s.counts)
+	}
+
+	s.counts[message]++
+	n := s.counts[message]
+
+	if n <= s.first {
+		return true
+	}
+
+	return s.thereafter > 0 && (n-s.first)%s.thereafter == 0
+}
@@ -4,6 +4,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/censys/scan-takehome/internal/managers/scan_manager")
+
+// DefaultNotifyTimeout bounds each Notify call when a config leaves it unset
+const DefaultNotifyTimeout = 5 * time.Second
+
+var (
+	// ErrNotFound is returned by repositories when no scan exists for a key
+	ErrNotFound = errors.New("scan result not found")
+	// ErrInvalidPageToken is returned by repositories when a page token can't be decoded
+	ErrInvalidPageToken = errors.New("invalid page token")
+	// ErrHistoryDisabled is returned by History when the repository doesn't keep history
+	ErrHistoryDisabled = errors.New("scan history is not enabled")
+
+	// Each repository classifies its driver's errors into the classes below,
+	// wrapping the original, so decorators such as retry and the circuit
+	// breaker can tell failures that outlasted the driver's own retries from
+	// ones retrying won't fix. Errors in no class are returned unwrapped.
+
+	// ErrThrottled marks repository errors caused by the store shedding load.
+	// Retrying helps, but only after backing off.
+	ErrThrottled = errors.New("repository throttled")
+	// ErrUnavailable marks transient repository errors such as timeouts,
+	// dropped connections and server faults
+	ErrUnavailable = errors.New("repository unavailable")
+	// ErrInvalidScan marks repository errors caused by the scan itself, such
+	// as an oversized item. Retrying fails the same way.
+	ErrInvalidScan = errors.New("scan rejected by repository")
 )
 
 type ScanResult struct {
@@ -15,16 +52,172 @@ type ScanResult struct {
 	DataVersion int
 }
 
+// ScanKey identifies the latest scan for a service on a host
+type ScanKey struct {
+	IP      string
+	Port    uint32
+	Service string
+}
+
+// String returns the composite key in the ip#port#service form used by the repositories
+func (k ScanKey) String() string {
+	return fmt.Sprintf("%s#%d#%s", k.IP, k.Port, k.Service)
+}
+
+func (r *ScanResult) Key() ScanKey {
+	return ScanKey{IP: r.IP, Port: r.Port, Service: r.Service}
+}
+
+const (
+	// DefaultListLimit is the page size used when ListOptions.Limit is unset
+	DefaultListLimit = 100
+	// MaxListLimit caps the page size a caller can request
+	MaxListLimit = 1000
+)
+
+// HistoryEntry is a single observation of a service, in the order it was scanned
+type HistoryEntry struct {
+	ScanResult
+	// Stale is set when the observation was not newer than the stored scan when it arrived
+	Stale bool
+}
+
+// ListOptions controls pagination for list queries
+type ListOptions struct {
+	// Limit is the maximum number of results per page, zero uses the repository default
+	Limit int
+	// PageToken resumes a listing from the NextPageToken of a previous page
+	PageToken string
+	// Port restricts the listing to a single port, zero matches any port
+	Port uint32
+}
+
+// PageSize returns the effective page size for the options
+func (o ListOptions) PageSize() int {
+	if o.Limit <= 0 {
+		return DefaultListLimit
+	}
+
+	if o.Limit > MaxListLimit {
+		return MaxListLimit
+	}
+
+	return o.Limit
+}
+
+// ListPage is a single page of results. NextPageToken is empty on the last page.
+type ListPage struct {
+	Results       []*ScanResult
+	NextPageToken string
+}
+
+// ChangeEvent describes a newer scan whose response differs from the stored one
+type ChangeEvent struct {
+	Key          ScanKey
+	OldResponse  string
+	NewResponse  string
+	OldTimestamp int64
+	NewTimestamp int64
+}
+
+// Notifier receives change events for accepted scans
+type Notifier interface {
+	Notify(ctx context.Context, event *ChangeEvent) error
+}
+
+// PutOutcome reports what a Put did with a scan
+type PutOutcome int
+
+const (
+	// Inserted is the first scan stored for its key
+	Inserted PutOutcome = iota + 1
+	// Updated replaced an older scan for its key
+	Updated
+	// StaleIgnored was rejected because the stored scan is newer, or has the
+	// same timestamp with a different response
+	StaleIgnored
+	// Duplicate was rejected because the stored scan has the same timestamp
+	// and response, typically a redelivered message
+	Duplicate
+)
+
+func (o PutOutcome) String() string {
+	switch o {
+	case Inserted:
+		return "inserted"
+	case Updated:
+		return "updated"
+	case StaleIgnored:
+		return "stale_ignored"
+	case Duplicate:
+		return "duplicate"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(o))
+	}
+}
+
+// Stored reports whether the scan was written
+func (o PutOutcome) Stored() bool {
+	return o == Inserted || o == Updated
+}
+
+// PutResult is the outcome of a Put
+type PutResult struct {
+	Outcome PutOutcome
+	// Previous is the scan that was replaced for Updated, or the stored scan
+	// that won for StaleIgnored and Duplicate. It is nil for Inserted.
+	Previous *ScanResult
+}
+
+// RejectedOutcome classifies a write rejected in favor of existing
+func RejectedOutcome(existing, result *ScanResult) PutOutcome {
+	if existing.Timestamp == result.Timestamp && existing.Response == result.Response {
+		return Duplicate
+	}
+
+	return StaleIgnored
+}
+
 type Repository interface {
-	Put(ctx context.Context, result *ScanResult) error
+	// Put stores result if it is newer than the stored scan for its key
+	Put(ctx context.Context, result *ScanResult) (*PutResult, error)
+	// Get returns the stored scan for key, reflecting every Put that returned
+	// before it, or ErrNotFound
+	Get(ctx context.Context, key ScanKey) (*ScanResult, error)
+	ListByIP(ctx context.Context, ip string, opts ListOptions) (*ListPage, error)
+	ListByService(ctx context.Context, service string, opts ListOptions) (*ListPage, error)
+	// ListByPort returns every scan on port, opts.Port is ignored
+	ListByPort(ctx context.Context, port uint32, opts ListOptions) (*ListPage, error)
+	// History returns observations for key with from <= timestamp <= to, oldest first
+	History(ctx context.Context, key ScanKey, from, to int64) ([]*HistoryEntry, error)
+	// Ping checks the backing store is reachable
+	Ping(ctx context.Context) error
+}
+
+// BatchPutter is implemented by repositories that can store several scans in
+// fewer round trips than a Put each
+type BatchPutter interface {
+	// PutBatch stores results, which must have distinct keys, with the same
+	// semantics as Put. The returned results line up with the input.
+	PutBatch(ctx context.Context, results []*ScanResult) ([]*PutResult, error)
 }
 
 type ScanManagerConfig struct {
 	Repo Repository
+	// Notifier is optional and receives an event whenever a scan changes a service's response
+	Notifier Notifier
+	// NotifyTimeout bounds each Notify call, independently of the put's own
+	// deadline. Defaults to DefaultNotifyTimeout.
+	NotifyTimeout time.Duration
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
 }
 
 type scanManager struct {
-	repo Repository
+	repo          Repository
+	notifier      Notifier
+	notifyTimeout time.Duration
+	logger        *slog.Logger
 }
 
 func NewScanManager(cfg *ScanManagerConfig) (*scanManager, error) {
@@ -37,17 +230,122 @@ func NewScanManager(cfg *ScanManagerConfig) (*scanManager, error) {
 	}
 
 	manager := &scanManager{
-		repo: cfg.Repo,
+		repo:          cfg.Repo,
+		notifier:      cfg.Notifier,
+		notifyTimeout: cfg.NotifyTimeout,
+		logger:        logging.OrDefault(cfg.Logger),
+	}
+
+	if manager.notifyTimeout <= 0 {
+		manager.notifyTimeout = DefaultNotifyTimeout
 	}
 
 	return manager, nil
 }
 
-func (m *scanManager) PutScan(ctx context.Context, result *ScanResult) error {
-	if err := m.repo.Put(ctx, result); err != nil {
-		return fmt.Errorf("failed to put scan: %w", err)
+func (m *scanManager) PutScan(ctx context.Context, result *ScanResult) (PutOutcome, error) {
+	ctx, span := tracer.Start(ctx, "PutScan", trace.WithAttributes(
+		attribute.String("scan.key", result.Key().String()),
+		attribute.Int64("scan.timestamp", result.Timestamp),
+	))
+	defer span.End()
+
+	put, err := m.repo.Put(ctx, result)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to put scan")
+		return 0, fmt.Errorf("failed to put scan: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("scan.outcome", put.Outcome.String()))
+	m.logger.InfoContext(ctx, "scan resolved",
+		slog.String("key", result.Key().String()),
+		slog.String("outcome", put.Outcome.String()),
+		slog.Int64("timestamp", result.Timestamp),
+		slog.Int("data_version", result.DataVersion),
+	)
+
+	if put.Outcome == Updated && put.Previous.Response != result.Response {
+		m.notifyChange(ctx, put.Previous, result)
+	}
+
+	return put.Outcome, nil
+}
+
+// notifyChange emits a change event. The scan is already stored, and a
+// redelivery would be rejected as stale without another event, so notifier
+// failures are logged rather than failing the put. The notifier gets its own
+// deadline, so a put that used up the message's time still sends its event
+// and a slow notifier can't hold the message for longer than notifyTimeout.
+func (m *scanManager) notifyChange(ctx context.Context, previous, result *ScanResult) {
+	if m.notifier == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.notifyTimeout)
+	defer cancel()
+
+	event := &ChangeEvent{
+		Key:          result.Key(),
+		OldResponse:  previous.Response,
+		NewResponse:  result.Response,
+		OldTimestamp: previous.Timestamp,
+		NewTimestamp: result.Timestamp,
+	}
+
+	if err := m.notifier.Notify(ctx, event); err != nil {
+		m.logger.WarnContext(ctx, "failed to notify change",
+			slog.String("key", event.Key.String()),
+			slog.Any("error", err),
+		)
+	}
+}
+
+func (m *scanManager) GetScan(ctx context.Context, key ScanKey) (*ScanResult, error) {
+	result, err := m.repo.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan %s: %w", key, err)
+	}
+
+	return result, nil
+}
+
+func (m *scanManager) ListScansByIP(ctx context.Context, ip string, opts ListOptions) (*ListPage, error) {
+	page, err := m.repo.ListByIP(ctx, ip, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scans for ip %s: %w", ip, err)
+	}
+
+	return page, nil
+}
+
+func (m *scanManager) ListScansByService(ctx context.Context, service string, opts ListOptions) (*ListPage, error) {
+	page, err := m.repo.ListByService(ctx, service, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scans for service %s: %w", service, err)
+	}
+
+	return page, nil
+}
+
+func (m *scanManager) ListScansByPort(ctx context.Context, port uint32, opts ListOptions) (*ListPage, error) {
+	page, err := m.repo.ListByPort(ctx, port, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scans for port %d: %w", port, err)
+	}
+
+	return page, nil
+}
+
+func (m *scanManager) ScanHistory(ctx context.Context, key ScanKey, from, to int64) ([]*HistoryEntry, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid history range: from %d is after to %d", from, to)
+	}
+
+	entries, err := m.repo.History(ctx, key, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history for %s: %w", key, err)
 	}
-	fmt.Printf("scan result stored: %+v\n", result)
 
-	return nil
+	return entries, nil
 }
@@ -4,14 +4,118 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 )
 
 // MockRepository for testing
 type MockRepository struct {
 	ShouldFail bool
+	Results    []*ScanResult
+	// PutResult is returned from Put, defaults to Inserted
+	PutResult *PutResult
 }
 
-func (m *MockRepository) Put(ctx context.Context, result *ScanResult) error {
+func (m *MockRepository) Put(ctx context.Context, result *ScanResult) (*PutResult, error) {
+	if m.ShouldFail {
+		return nil, errors.New("repository error")
+	}
+	if m.PutResult != nil {
+		return m.PutResult, nil
+	}
+	return &PutResult{Outcome: Inserted}, nil
+}
+
+// MockNotifier records change events
+type MockNotifier struct {
+	ShouldFail bool
+	Events     []*ChangeEvent
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, event *ChangeEvent) error {
+	m.Events = append(m.Events, event)
+	if m.ShouldFail {
+		return errors.New("notifier error")
+	}
+	return nil
+}
+
+// deadlineNotifier records the context each event is sent with
+type deadlineNotifier struct {
+	err      error
+	deadline time.Time
+}
+
+func (d *deadlineNotifier) Notify(ctx context.Context, event *ChangeEvent) error {
+	d.err = ctx.Err()
+	d.deadline, _ = ctx.Deadline()
+	return nil
+}
+
+func (m *MockRepository) Get(ctx context.Context, key ScanKey) (*ScanResult, error) {
+	if m.ShouldFail {
+		return nil, errors.New("repository error")
+	}
+	for _, r := range m.Results {
+		if r.Key() == key {
+			return r, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MockRepository) ListByIP(ctx context.Context, ip string, opts ListOptions) (*ListPage, error) {
+	if m.ShouldFail {
+		return nil, errors.New("repository error")
+	}
+	page := &ListPage{}
+	for _, r := range m.Results {
+		if r.IP == ip {
+			page.Results = append(page.Results, r)
+		}
+	}
+	return page, nil
+}
+
+func (m *MockRepository) ListByService(ctx context.Context, service string, opts ListOptions) (*ListPage, error) {
+	if m.ShouldFail {
+		return nil, errors.New("repository error")
+	}
+	page := &ListPage{}
+	for _, r := range m.Results {
+		if r.Service == service {
+			page.Results = append(page.Results, r)
+		}
+	}
+	return page, nil
+}
+
+func (m *MockRepository) ListByPort(ctx context.Context, port uint32, opts ListOptions) (*ListPage, error) {
+	if m.ShouldFail {
+		return nil, errors.New("repository error")
+	}
+	page := &ListPage{}
+	for _, r := range m.Results {
+		if r.Port == port {
+			page.Results = append(page.Results, r)
+		}
+	}
+	return page, nil
+}
+
+func (m *MockRepository) History(ctx context.Context, key ScanKey, from, to int64) ([]*HistoryEntry, error) {
+	if m.ShouldFail {
+		return nil, errors.New("repository error")
+	}
+	var entries []*HistoryEntry
+	for _, r := range m.Results {
+		if r.Key() == key && r.Timestamp >= from && r.Timestamp <= to {
+			entries = append(entries, &HistoryEntry{ScanResult: *r})
+		}
+	}
+	return entries, nil
+}
+
+func (m *MockRepository) Ping(ctx context.Context) error {
 	if m.ShouldFail {
 		return errors.New("repository error")
 	}
@@ -62,10 +166,14 @@ func TestPutScan(t *testing.T) {
 			DataVersion: 1,
 		}
 
-		err := manager.PutScan(context.Background(), result)
+		outcome, err := manager.PutScan(context.Background(), result)
 		if err != nil {
 			t.Errorf("expected no error, got %v", err)
 		}
+
+		if outcome != Inserted {
+			t.Errorf("expected outcome inserted, got %s", outcome)
+		}
 	})
 
 	t.Run("should fail when repository fails", func(t *testing.T) {
@@ -83,9 +191,262 @@ func TestPutScan(t *testing.T) {
 			DataVersion: 1,
 		}
 
-		err := manager.PutScan(context.Background(), result)
+		_, err := manager.PutScan(context.Background(), result)
 		if err == nil {
 			t.Errorf("expected error, got nil")
 		}
 	})
 }
+
+func TestPutScan_ChangeEvents(t *testing.T) {
+	result := &ScanResult{
+		IP:        "192.168.1.1",
+		Port:      80,
+		Service:   "http",
+		Timestamp: 200,
+		Response:  "new response",
+	}
+
+	t.Run("should notify when the response changes", func(t *testing.T) {
+		notifier := &MockNotifier{}
+		manager, _ := NewScanManager(&ScanManagerConfig{
+			Repo: &MockRepository{PutResult: &PutResult{
+				Outcome:  Updated,
+				Previous: &ScanResult{IP: "192.168.1.1", Port: 80, Service: "http", Timestamp: 100, Response: "old response"},
+			}},
+			Notifier: notifier,
+		})
+
+		if _, err := manager.PutScan(context.Background(), result); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(notifier.Events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(notifier.Events))
+		}
+
+		event := notifier.Events[0]
+		expected := ChangeEvent{
+			Key:          result.Key(),
+			OldResponse:  "old response",
+			NewResponse:  "new response",
+			OldTimestamp: 100,
+			NewTimestamp: 200,
+		}
+		if *event != expected {
+			t.Errorf("expected %+v, got %+v", expected, *event)
+		}
+	})
+
+	t.Run("should not notify when the response is unchanged", func(t *testing.T) {
+		notifier := &MockNotifier{}
+		manager, _ := NewScanManager(&ScanManagerConfig{
+			Repo:     &MockRepository{PutResult: &PutResult{Outcome: Updated, Previous: &ScanResult{Timestamp: 100, Response: "new response"}}},
+			Notifier: notifier,
+		})
+
+		_, _ = manager.PutScan(context.Background(), result)
+
+		if len(notifier.Events) != 0 {
+			t.Errorf("expected no events, got %d", len(notifier.Events))
+		}
+	})
+
+	t.Run("should not notify for new keys or stale scans", func(t *testing.T) {
+		for _, put := range []*PutResult{
+			{Outcome: Inserted},
+			{Outcome: StaleIgnored, Previous: &ScanResult{Timestamp: 300, Response: "newest response"}},
+		} {
+			notifier := &MockNotifier{}
+			manager, _ := NewScanManager(&ScanManagerConfig{
+				Repo:     &MockRepository{PutResult: put},
+				Notifier: notifier,
+			})
+
+			_, _ = manager.PutScan(context.Background(), result)
+
+			if len(notifier.Events) != 0 {
+				t.Errorf("expected no events for %s, got %d", put.Outcome, len(notifier.Events))
+			}
+		}
+	})
+
+	t.Run("should give the notifier its own deadline", func(t *testing.T) {
+		notifier := &deadlineNotifier{}
+		manager, _ := NewScanManager(&ScanManagerConfig{
+			Repo:          &MockRepository{PutResult: &PutResult{Outcome: Updated, Previous: &ScanResult{Timestamp: 100, Response: "old response"}}},
+			Notifier:      notifier,
+			NotifyTimeout: time.Minute,
+		})
+
+		// The put's deadline has passed by the time the event is sent
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		if _, err := manager.PutScan(ctx, result); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if notifier.err != nil {
+			t.Errorf("expected a live context, got %v", notifier.err)
+		}
+
+		if remaining := time.Until(notifier.deadline); remaining <= 0 || remaining > time.Minute {
+			t.Errorf("expected a deadline within a minute, got %v", remaining)
+		}
+	})
+
+	t.Run("should not fail the put when the notifier fails", func(t *testing.T) {
+		manager, _ := NewScanManager(&ScanManagerConfig{
+			Repo:     &MockRepository{PutResult: &PutResult{Outcome: Updated, Previous: &ScanResult{Timestamp: 100, Response: "old response"}}},
+			Notifier: &MockNotifier{ShouldFail: true},
+		})
+
+		if _, err := manager.PutScan(context.Background(), result); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}
+
+func TestRejectedOutcome(t *testing.T) {
+	existing := &ScanResult{Timestamp: 200, Response: "stored"}
+
+	tests := []struct {
+		name     string
+		result   *ScanResult
+		expected PutOutcome
+	}{
+		{"older timestamp", &ScanResult{Timestamp: 100, Response: "stored"}, StaleIgnored},
+		{"same timestamp and response", &ScanResult{Timestamp: 200, Response: "stored"}, Duplicate},
+		{"same timestamp, different response", &ScanResult{Timestamp: 200, Response: "conflict"}, StaleIgnored},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RejectedOutcome(existing, tt.result); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestScanKey(t *testing.T) {
+	key := (&ScanResult{IP: "192.168.1.1", Port: 80, Service: "http"}).Key()
+
+	if key.String() != "192.168.1.1#80#http" {
+		t.Errorf("Expected key 192.168.1.1#80#http, got %s", key.String())
+	}
+}
+
+func TestGetScan(t *testing.T) {
+	stored := &ScanResult{
+		IP:          "192.168.1.1",
+		Port:        80,
+		Service:     "http",
+		Timestamp:   1234567890,
+		Response:    "test response",
+		DataVersion: 1,
+	}
+
+	t.Run("should return the stored scan", func(t *testing.T) {
+		manager, _ := NewScanManager(&ScanManagerConfig{
+			Repo: &MockRepository{Results: []*ScanResult{stored}},
+		})
+
+		result, err := manager.GetScan(context.Background(), stored.Key())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if result.Response != stored.Response {
+			t.Errorf("expected response '%s', got '%s'", stored.Response, result.Response)
+		}
+	})
+
+	t.Run("should wrap ErrNotFound for missing keys", func(t *testing.T) {
+		manager, _ := NewScanManager(&ScanManagerConfig{
+			Repo: &MockRepository{},
+		})
+
+		_, err := manager.GetScan(context.Background(), stored.Key())
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestListScans(t *testing.T) {
+	repo := &MockRepository{Results: []*ScanResult{
+		{IP: "10.0.0.1", Port: 22, Service: "ssh"},
+		{IP: "10.0.0.1", Port: 80, Service: "http"},
+		{IP: "10.0.0.2", Port: 22, Service: "ssh"},
+	}}
+	manager, _ := NewScanManager(&ScanManagerConfig{Repo: repo})
+
+	t.Run("should list scans by ip", func(t *testing.T) {
+		page, err := manager.ListScansByIP(context.Background(), "10.0.0.1", ListOptions{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(page.Results) != 2 {
+			t.Errorf("expected 2 results, got %d", len(page.Results))
+		}
+	})
+
+	t.Run("should list scans by service", func(t *testing.T) {
+		page, err := manager.ListScansByService(context.Background(), "ssh", ListOptions{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(page.Results) != 2 {
+			t.Errorf("expected 2 results, got %d", len(page.Results))
+		}
+	})
+
+	t.Run("should list scans by port", func(t *testing.T) {
+		page, err := manager.ListScansByPort(context.Background(), 22, ListOptions{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(page.Results) != 2 {
+			t.Errorf("expected 2 results, got %d", len(page.Results))
+		}
+	})
+
+	t.Run("should fail when repository fails", func(t *testing.T) {
+		manager, _ := NewScanManager(&ScanManagerConfig{Repo: &MockRepository{ShouldFail: true}})
+
+		if _, err := manager.ListScansByIP(context.Background(), "10.0.0.1", ListOptions{}); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestScanHistory(t *testing.T) {
+	key := ScanKey{IP: "10.0.0.1", Port: 22, Service: "ssh"}
+	repo := &MockRepository{Results: []*ScanResult{
+		{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 100},
+		{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 200},
+	}}
+	manager, _ := NewScanManager(&ScanManagerConfig{Repo: repo})
+
+	t.Run("should return history in range", func(t *testing.T) {
+		entries, err := manager.ScanHistory(context.Background(), key, 150, 250)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(entries) != 1 || entries[0].Timestamp != 200 {
+			t.Errorf("expected only timestamp 200, got %+v", entries)
+		}
+	})
+
+	t.Run("should reject an inverted range", func(t *testing.T) {
+		if _, err := manager.ScanHistory(context.Background(), key, 250, 150); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
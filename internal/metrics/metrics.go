@@ -0,0 +1,217 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/breaker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "mini_scan"
+
+// Message results that aren't put outcomes
+const (
+	ResultParseError = "parse_error"
+	ResultRepoError  = "repo_error"
+)
+
+// maxServices caps the distinct service label values, scans report free form
+// service names and each one is a new time series
+const maxServices = 100
+
+// otherService is the label used once maxServices is reached
+const otherService = "other"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	messages      *prometheus.CounterVec
+	deadLetters   prometheus.Counter
+	dropped       prometheus.Counter
+	dataVersions  *prometheus.CounterVec
+	services      *prometheus.CounterVec
+	endToEnd      prometheus.Histogram
+	repoWrite     *prometheus.HistogramVec
+	repoRetries   *prometheus.CounterVec
+	breakerState  prometheus.Gauge
+	breakerTrips  *prometheus.CounterVec
+	mu            sync.Mutex
+	serviceLabels map[string]struct{}
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry:      prometheus.NewRegistry(),
+		serviceLabels: make(map[string]struct{}),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_total",
+			Help:      "Messages processed by result: a put outcome, parse_error or repo_error.",
+		}, []string{"result"}),
+		deadLetters: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dead_letters_total",
+			Help:      "Invalid messages handed to the dead-letter sink.",
+		}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dropped_messages_total",
+			Help:      "Invalid messages acked without a dead-letter sink to hand them to.",
+		}),
+		dataVersions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_by_data_version_total",
+			Help:      "Parsed messages by scan data version.",
+		}, []string{"data_version"}),
+		services: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_by_service_total",
+			Help:      "Parsed messages by scanned service.",
+		}, []string{"service"}),
+		endToEnd: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "end_to_end_latency_seconds",
+			Help:      "Time from Pub/Sub publish to the scan being resolved by the repository.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+		}),
+		repoWrite: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_write_seconds",
+			Help:      "Repository Put latency by outcome, or error.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		repoRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "repository_retries_total",
+			Help:      "Repository Put retries by error class: throttled or transient.",
+		}, []string{"class"}),
+		breakerState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "circuit_breaker_state",
+			Help:      "Repository circuit breaker state: 0 closed, 1 half-open, 2 open.",
+		}),
+		breakerTrips: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "circuit_breaker_transitions_total",
+			Help:      "Repository circuit breaker transitions by the state entered.",
+		}, []string{"state"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.messages,
+		m.deadLetters,
+		m.dropped,
+		m.dataVersions,
+		m.services,
+		m.endToEnd,
+		m.repoWrite,
+		m.repoRetries,
+		m.breakerState,
+		m.breakerTrips,
+	)
+
+	return m
+}
+
+// Registerer exposes the registry for collectors owned by other packages
+func (m *Metrics) Registerer() prometheus.Registerer {
+	return m.registry
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveResult counts a processed message by result
+func (m *Metrics) ObserveResult(result string) {
+	m.messages.WithLabelValues(result).Inc()
+}
+
+// ObserveDeadLetter counts a message handed to the dead-letter sink
+func (m *Metrics) ObserveDeadLetter() {
+	m.deadLetters.Inc()
+}
+
+// ObserveDropped counts an invalid message dropped for want of a dead-letter sink
+func (m *Metrics) ObserveDropped() {
+	m.dropped.Inc()
+}
+
+// ObserveRetry counts a repository write retried after an error of class
+func (m *Metrics) ObserveRetry(class string) {
+	m.repoRetries.WithLabelValues(class).Inc()
+}
+
+// ObserveBreakerState records a circuit breaker transition into state
+func (m *Metrics) ObserveBreakerState(state breaker.State) {
+	m.breakerState.Set(float64(state))
+	m.breakerTrips.WithLabelValues(state.String()).Inc()
+}
+
+// ObserveScan counts a parsed scan by data version and service
+func (m *Metrics) ObserveScan(result *scan_manager.ScanResult) {
+	m.dataVersions.WithLabelValues(strconv.Itoa(result.DataVersion)).Inc()
+	m.services.WithLabelValues(m.serviceLabel(result.Service)).Inc()
+}
+
+// ObserveEndToEnd records the latency since the message was published
+func (m *Metrics) ObserveEndToEnd(published time.Time) {
+	if published.IsZero() {
+		return
+	}
+
+	m.endToEnd.Observe(time.Since(published).Seconds())
+}
+
+func (m *Metrics) serviceLabel(service string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.serviceLabels[service]; ok {
+		return service
+	}
+
+	if len(m.serviceLabels) >= maxServices {
+		return otherService
+	}
+
+	m.serviceLabels[service] = struct{}{}
+	return service
+}
+
+// InstrumentRepository wraps repo so every Put records its latency
+func (m *Metrics) InstrumentRepository(repo scan_manager.Repository) scan_manager.Repository {
+	return &instrumentedRepository{Repository: repo, metrics: m}
+}
+
+type instrumentedRepository struct {
+	scan_manager.Repository
+	metrics *Metrics
+}
+
+func (r *instrumentedRepository) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	start := time.Now()
+	put, err := r.Repository.Put(ctx, result)
+
+	outcome := "error"
+	if err == nil {
+		outcome = put.Outcome.String()
+	} else if errors.Is(err, context.Canceled) {
+		outcome = "canceled"
+	}
+
+	r.metrics.repoWrite.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+
+	return put, err
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/breaker"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// stubRepository returns a fixed Put result
+type stubRepository struct {
+	scan_manager.Repository
+	put *scan_manager.PutResult
+	err error
+}
+
+func (s *stubRepository) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	return s.put, s.err
+}
+
+func TestObserve(t *testing.T) {
+	m := NewMetrics()
+
+	m.ObserveResult(scan_manager.Inserted.String())
+	m.ObserveResult(scan_manager.Inserted.String())
+	m.ObserveResult(ResultParseError)
+	m.ObserveScan(&scan_manager.ScanResult{Service: "SSH", DataVersion: 2})
+	m.ObserveEndToEnd(time.Now().Add(-time.Second))
+	m.ObserveRetry("throttled")
+	m.ObserveDropped()
+	m.ObserveBreakerState(breaker.Open)
+
+	if got := testutil.ToFloat64(m.messages.WithLabelValues("inserted")); got != 2 {
+		t.Errorf("expected 2 inserted, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(m.messages.WithLabelValues(ResultParseError)); got != 1 {
+		t.Errorf("expected 1 parse error, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(m.dataVersions.WithLabelValues("2")); got != 1 {
+		t.Errorf("expected 1 scan for data version 2, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(m.services.WithLabelValues("SSH")); got != 1 {
+		t.Errorf("expected 1 scan for SSH, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(m.repoRetries.WithLabelValues("throttled")); got != 1 {
+		t.Errorf("expected 1 throttled retry, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(m.dropped); got != 1 {
+		t.Errorf("expected 1 dropped message, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(m.breakerState); got != float64(breaker.Open) {
+		t.Errorf("expected breaker state %d, got %v", breaker.Open, got)
+	}
+
+	if got := testutil.ToFloat64(m.breakerTrips.WithLabelValues("open")); got != 1 {
+		t.Errorf("expected 1 transition to open, got %v", got)
+	}
+
+	if got := testutil.CollectAndCount(m.endToEnd); got != 1 {
+		t.Errorf("expected end-to-end histogram to be collected, got %d", got)
+	}
+}
+
+func TestServiceLabelCap(t *testing.T) {
+	m := NewMetrics()
+
+	for i := 0; i < maxServices+10; i++ {
+		m.ObserveScan(&scan_manager.ScanResult{Service: fmt.Sprintf("svc-%d", i), DataVersion: 1})
+	}
+
+	if got := testutil.CollectAndCount(m.services); got != maxServices+1 {
+		t.Errorf("expected %d service series, got %d", maxServices+1, got)
+	}
+
+	if got := testutil.ToFloat64(m.services.WithLabelValues(otherService)); got != 10 {
+		t.Errorf("expected 10 scans bucketed as other, got %v", got)
+	}
+}
+
+func TestInstrumentRepository(t *testing.T) {
+	m := NewMetrics()
+
+	stored := m.InstrumentRepository(&stubRepository{put: &scan_manager.PutResult{Outcome: scan_manager.Updated}})
+	if _, err := stored.Put(context.Background(), &scan_manager.ScanResult{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	failing := m.InstrumentRepository(&stubRepository{err: errors.New("repository error")})
+	if _, err := failing.Put(context.Background(), &scan_manager.ScanResult{}); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	// One series per outcome label
+	if got := testutil.CollectAndCount(m.repoWrite); got != 2 {
+		t.Errorf("expected 2 repository write series, got %d", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveResult(ResultRepoError)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	if !strings.Contains(rec.Body.String(), `mini_scan_messages_total{result="repo_error"} 1`) {
+		t.Errorf("expected messages counter in output")
+	}
+}
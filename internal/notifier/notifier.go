@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// EventTypeServiceChanged identifies change events on the wire
+const EventTypeServiceChanged = "service_changed"
+
+// Event is the JSON body published for a change event
+type Event struct {
+	Type         string `json:"type"`
+	IP           string `json:"ip"`
+	Port         uint32 `json:"port"`
+	Service      string `json:"service"`
+	OldResponse  string `json:"old_response"`
+	NewResponse  string `json:"new_response"`
+	OldTimestamp int64  `json:"old_timestamp"`
+	NewTimestamp int64  `json:"new_timestamp"`
+}
+
+func newEvent(event *scan_manager.ChangeEvent) *Event {
+	return &Event{
+		Type:         EventTypeServiceChanged,
+		IP:           event.Key.IP,
+		Port:         event.Key.Port,
+		Service:      event.Key.Service,
+		OldResponse:  event.OldResponse,
+		NewResponse:  event.NewResponse,
+		OldTimestamp: event.OldTimestamp,
+		NewTimestamp: event.NewTimestamp,
+	}
+}
+
+// multi fans an event out to several notifiers
+type multi []scan_manager.Notifier
+
+// Multi returns a Notifier that notifies every one of notifiers, returning
+// the joined errors of any that fail
+func Multi(notifiers ...scan_manager.Notifier) scan_manager.Notifier {
+	return multi(notifiers)
+}
+
+func (m multi) Notify(ctx context.Context, event *scan_manager.ChangeEvent) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,174 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func testEvent() *scan_manager.ChangeEvent {
+	return &scan_manager.ChangeEvent{
+		Key:          scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"},
+		OldResponse:  "SSH-2.0-OpenSSH_8.9",
+		NewResponse:  "SSH-2.0-OpenSSH_9.6",
+		OldTimestamp: 100,
+		NewTimestamp: 200,
+	}
+}
+
+func TestNewPubSubNotifier(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewPubSubNotifier(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if topic is nil", func(t *testing.T) {
+		_, err := NewPubSubNotifier(&PubSubNotifierConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestPubSubNotifier_Notify(t *testing.T) {
+	ctx := context.Background()
+
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial pstest: %v", err)
+	}
+	defer conn.Close()
+
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	topic, err := client.CreateTopic(ctx, "scan-changes")
+	if err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	n, err := NewPubSubNotifier(&PubSubNotifierConfig{Topic: topic})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer n.Close()
+
+	if err := n.Notify(ctx, testEvent()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(msgs))
+	}
+
+	var event Event
+	if err := json.Unmarshal(msgs[0].Data, &event); err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+
+	if event.Type != EventTypeServiceChanged || event.NewResponse != "SSH-2.0-OpenSSH_9.6" || event.OldTimestamp != 100 {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	if msgs[0].Attributes["service"] != "SSH" || msgs[0].Attributes["port"] != "22" {
+		t.Errorf("expected key attributes, got %v", msgs[0].Attributes)
+	}
+}
+
+func TestNewWebhookNotifier(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewWebhookNotifier(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if url is empty", func(t *testing.T) {
+		_, err := NewWebhookNotifier(&WebhookNotifierConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	t.Run("should post the event as JSON", func(t *testing.T) {
+		var received Event
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+			}
+
+			if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+				t.Errorf("failed to decode event: %v", err)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		n, _ := NewWebhookNotifier(&WebhookNotifierConfig{URL: srv.URL})
+		if err := n.Notify(context.Background(), testEvent()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if received.IP != "10.0.0.1" || received.OldResponse != "SSH-2.0-OpenSSH_8.9" {
+			t.Errorf("unexpected event %+v", received)
+		}
+	})
+
+	t.Run("should fail on non-2xx responses", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer srv.Close()
+
+		n, _ := NewWebhookNotifier(&WebhookNotifierConfig{URL: srv.URL})
+		if err := n.Notify(context.Background(), testEvent()); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(ctx context.Context, event *scan_manager.ChangeEvent) error {
+	return errors.New("notifier error")
+}
+
+func TestMulti(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+
+	webhook, _ := NewWebhookNotifier(&WebhookNotifierConfig{URL: srv.URL})
+
+	err := Multi(failingNotifier{}, webhook).Notify(context.Background(), testEvent())
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if calls != 1 {
+		t.Errorf("expected remaining notifiers to be called after a failure, got %d calls", calls)
+	}
+}
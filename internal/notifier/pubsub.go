@@ -0,0 +1,60 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+type PubSubNotifierConfig struct {
+	Topic *pubsub.Topic
+}
+
+// pubSubNotifier publishes change events as JSON to a Pub/Sub topic. The
+// event type and key are copied into attributes for subscription filters.
+type pubSubNotifier struct {
+	topic *pubsub.Topic
+}
+
+func NewPubSubNotifier(cfg *PubSubNotifierConfig) (*pubSubNotifier, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Topic == nil {
+		return nil, errors.New("topic is nil")
+	}
+
+	return &pubSubNotifier{topic: cfg.Topic}, nil
+}
+
+func (p *pubSubNotifier) Notify(ctx context.Context, event *scan_manager.ChangeEvent) error {
+	data, err := json.Marshal(newEvent(event))
+	if err != nil {
+		return fmt.Errorf("failed to encode change event: %w", err)
+	}
+
+	_, err = p.topic.Publish(ctx, &pubsub.Message{
+		Data: data,
+		Attributes: map[string]string{
+			"type":    EventTypeServiceChanged,
+			"ip":      event.Key.IP,
+			"port":    fmt.Sprintf("%d", event.Key.Port),
+			"service": event.Key.Service,
+		},
+	}).Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to publish change event: %w", err)
+	}
+
+	return nil
+}
+
+func (p *pubSubNotifier) Close() error {
+	p.topic.Stop()
+	return nil
+}
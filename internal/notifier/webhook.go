@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+const defaultWebhookTimeout = 10 * time.Second
+
+type WebhookNotifierConfig struct {
+	URL string
+	// Client is optional, a client with a 10s timeout is used when nil
+	Client *http.Client
+}
+
+// webhookNotifier POSTs change events as JSON to a URL. Any non-2xx
+// response is treated as a failure.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(cfg *WebhookNotifierConfig) (*webhookNotifier, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.URL == "" {
+		return nil, errors.New("url is empty")
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+
+	return &webhookNotifier{url: cfg.URL, client: client}, nil
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, event *scan_manager.ChangeEvent) error {
+	data, err := json.Marshal(newEvent(event))
+	if err != nil {
+		return fmt.Errorf("failed to encode change event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post change event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultMaxSize      = 25
+	DefaultMaxDelay     = 20 * time.Millisecond
+	DefaultFlushTimeout = 10 * time.Second
+)
+
+// ErrClosed is returned by Put once the batcher has been closed
+var ErrClosed = errors.New("batcher is closed")
+
+var tracer = otel.Tracer("github.com/censys/scan-takehome/internal/repositories/batch")
+
+type BatcherConfig struct {
+	// Repo must also implement scan_manager.BatchPutter
+	Repo scan_manager.Repository
+	// MaxSize flushes a batch once it holds this many scans. Defaults to DefaultMaxSize.
+	MaxSize int
+	// MaxDelay flushes a batch this long after its first scan arrived. Defaults to DefaultMaxDelay.
+	MaxDelay time.Duration
+	// FlushTimeout bounds each flush. Defaults to DefaultFlushTimeout.
+	FlushTimeout time.Duration
+}
+
+// batcher is a Repository decorator that groups Puts from concurrent callers
+// into PutBatch calls. Each Put blocks until the batch holding it has been
+// written, so a caller that acks after Put returns only acks durable scans.
+type batcher struct {
+	scan_manager.Repository
+	writer       scan_manager.BatchPutter
+	maxSize      int
+	maxDelay     time.Duration
+	flushTimeout time.Duration
+
+	mu      sync.Mutex
+	pending []*pendingPut
+	// gen identifies the pending batch so a delay timer that fires after a
+	// size-triggered flush doesn't flush the next batch early
+	gen      uint64
+	timer    *time.Timer
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+type pendingPut struct {
+	result *scan_manager.ScanResult
+	// span is the caller's span, which the flush links to
+	span trace.SpanContext
+	done chan putResponse
+}
+
+type putResponse struct {
+	put *scan_manager.PutResult
+	err error
+}
+
+func NewBatcher(cfg *BatcherConfig) (*batcher, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Repo == nil {
+		return nil, errors.New("repository is nil")
+	}
+
+	writer, ok := cfg.Repo.(scan_manager.BatchPutter)
+	if !ok {
+		return nil, fmt.Errorf("repository %T does not support batched writes", cfg.Repo)
+	}
+
+	b := &batcher{
+		Repository:   cfg.Repo,
+		writer:       writer,
+		maxSize:      cfg.MaxSize,
+		maxDelay:     cfg.MaxDelay,
+		flushTimeout: cfg.FlushTimeout,
+	}
+
+	if b.maxSize <= 0 {
+		b.maxSize = DefaultMaxSize
+	}
+
+	if b.maxDelay <= 0 {
+		b.maxDelay = DefaultMaxDelay
+	}
+
+	if b.flushTimeout <= 0 {
+		b.flushTimeout = DefaultFlushTimeout
+	}
+
+	return b, nil
+}
+
+// Put queues result for the next batch and waits for it to be written. If
+// ctx ends first the scan may still be written, which is safe to retry since
+// a redelivered scan comes back as a Duplicate.
+func (b *batcher) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	p := &pendingPut{
+		result: result,
+		span:   trace.SpanContextFromContext(ctx),
+		done:   make(chan putResponse, 1),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	b.pending = append(b.pending, p)
+	switch {
+	case len(b.pending) >= b.maxSize:
+		batch := b.take()
+		b.inflight.Add(1)
+		go b.flush(batch)
+	case len(b.pending) == 1:
+		gen := b.gen
+		b.timer = time.AfterFunc(b.maxDelay, func() { b.flushGen(gen) })
+	}
+	b.mu.Unlock()
+
+	select {
+	case resp := <-p.done:
+		return resp.put, resp.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close flushes any pending scans and waits for in-flight batches
+func (b *batcher) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	batch := b.take()
+	b.inflight.Add(1)
+	b.mu.Unlock()
+
+	b.flush(batch)
+	b.inflight.Wait()
+}
+
+// take removes the pending batch. Callers must hold mu.
+func (b *batcher) take() []*pendingPut {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	batch := b.pending
+	b.pending = nil
+	b.gen++
+
+	return batch
+}
+
+// flushGen flushes the pending batch if it is still generation gen
+func (b *batcher) flushGen(gen uint64) {
+	b.mu.Lock()
+	if b.gen != gen || b.closed {
+		b.mu.Unlock()
+		return
+	}
+	batch := b.take()
+	b.inflight.Add(1)
+	b.mu.Unlock()
+
+	b.flush(batch)
+}
+
+// flush writes the newest scan per key with one PutBatch. Older scans for a
+// key are resolved against the newest one: exact copies share its outcome and
+// the rest go through Put so the repository classifies and records them.
+func (b *batcher) flush(batch []*pendingPut) {
+	defer b.inflight.Done()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.flushTimeout)
+	defer cancel()
+
+	// A batch serves many traces, so its span is a root linked to each caller's
+	links := make([]trace.Link, 0, len(batch))
+	for _, p := range batch {
+		if p.span.IsValid() {
+			links = append(links, trace.Link{SpanContext: p.span})
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "flush batch",
+		trace.WithNewRoot(),
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("batch.size", len(batch))),
+	)
+	defer span.End()
+
+	newest := make(map[scan_manager.ScanKey]*pendingPut, len(batch))
+	var keys []scan_manager.ScanKey
+	for _, p := range batch {
+		key := p.result.Key()
+		current, ok := newest[key]
+		if !ok {
+			keys = append(keys, key)
+		}
+		if !ok || p.result.Timestamp > current.result.Timestamp {
+			newest[key] = p
+		}
+	}
+
+	results := make([]*scan_manager.ScanResult, len(keys))
+	for i, key := range keys {
+		results[i] = newest[key].result
+	}
+
+	puts, err := b.writer.PutBatch(ctx, results)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "batch write failed")
+		for _, p := range batch {
+			p.done <- putResponse{err: err}
+		}
+		return
+	}
+
+	written := make(map[scan_manager.ScanKey]*scan_manager.PutResult, len(keys))
+	for i, key := range keys {
+		written[key] = puts[i]
+		newest[key].done <- putResponse{put: puts[i]}
+	}
+
+	for _, p := range batch {
+		key := p.result.Key()
+		winner := newest[key]
+		if p == winner {
+			continue
+		}
+
+		if scan_manager.RejectedOutcome(winner.result, p.result) == scan_manager.Duplicate {
+			p.done <- putResponse{put: duplicateOf(winner.result, written[key])}
+			continue
+		}
+
+		put, err := b.Repository.Put(ctx, p.result)
+		p.done <- putResponse{put: put, err: err}
+	}
+}
+
+// duplicateOf is the outcome for an exact copy of a scan written as put
+func duplicateOf(result *scan_manager.ScanResult, put *scan_manager.PutResult) *scan_manager.PutResult {
+	if put.Outcome.Stored() {
+		stored := *result
+		return &scan_manager.PutResult{Outcome: scan_manager.Duplicate, Previous: &stored}
+	}
+
+	return put
+}
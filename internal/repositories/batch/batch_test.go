@@ -0,0 +1,231 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/memory"
+)
+
+// recordingRepo records every batch passed to PutBatch
+type recordingRepo struct {
+	scan_manager.Repository
+	mu      sync.Mutex
+	batches [][]*scan_manager.ScanResult
+	fail    error
+}
+
+func (r *recordingRepo) PutBatch(ctx context.Context, results []*scan_manager.ScanResult) ([]*scan_manager.PutResult, error) {
+	r.mu.Lock()
+	r.batches = append(r.batches, results)
+	r.mu.Unlock()
+
+	if r.fail != nil {
+		return nil, r.fail
+	}
+
+	return r.Repository.(scan_manager.BatchPutter).PutBatch(ctx, results)
+}
+
+func (r *recordingRepo) Batches() [][]*scan_manager.ScanResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.batches
+}
+
+func newTestBatcher(t *testing.T, cfg *BatcherConfig) (*batcher, *recordingRepo) {
+	repo, err := memory.NewMemory(&memory.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+
+	recording := &recordingRepo{Repository: repo}
+	cfg.Repo = recording
+
+	b, err := NewBatcher(cfg)
+	if err != nil {
+		t.Fatalf("failed to create batcher: %v", err)
+	}
+	t.Cleanup(b.Close)
+
+	return b, recording
+}
+
+// putAll runs a Put per result concurrently and returns the outcomes in order
+func putAll(t *testing.T, b *batcher, results ...*scan_manager.ScanResult) []scan_manager.PutOutcome {
+	outcomes := make([]scan_manager.PutOutcome, len(results))
+	var wg sync.WaitGroup
+	for i, result := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			put, err := b.Put(context.Background(), result)
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+				return
+			}
+			outcomes[i] = put.Outcome
+		}()
+	}
+	wg.Wait()
+
+	return outcomes
+}
+
+func TestNewBatcher(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewBatcher(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if repository is nil", func(t *testing.T) {
+		_, err := NewBatcher(&BatcherConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if repository can't batch", func(t *testing.T) {
+		repo, _ := memory.NewMemory(&memory.MemoryConfig{})
+		_, err := NewBatcher(&BatcherConfig{Repo: struct{ scan_manager.Repository }{repo}})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestBatcher(t *testing.T) {
+	t.Run("should flush when the batch is full", func(t *testing.T) {
+		b, repo := newTestBatcher(t, &BatcherConfig{MaxSize: 3, MaxDelay: time.Hour})
+
+		outcomes := putAll(t, b,
+			&scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 100},
+			&scan_manager.ScanResult{IP: "10.0.0.2", Port: 22, Service: "ssh", Timestamp: 100},
+			&scan_manager.ScanResult{IP: "10.0.0.3", Port: 22, Service: "ssh", Timestamp: 100},
+		)
+
+		for i, outcome := range outcomes {
+			if outcome != scan_manager.Inserted {
+				t.Errorf("expected put %d to be inserted, got %s", i, outcome)
+			}
+		}
+
+		if batches := repo.Batches(); len(batches) != 1 || len(batches[0]) != 3 {
+			t.Errorf("expected a single batch of 3, got %v", batches)
+		}
+	})
+
+	t.Run("should flush after the delay", func(t *testing.T) {
+		b, repo := newTestBatcher(t, &BatcherConfig{MaxSize: 100, MaxDelay: 10 * time.Millisecond})
+
+		outcomes := putAll(t, b, &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 100})
+
+		if outcomes[0] != scan_manager.Inserted {
+			t.Errorf("expected inserted, got %s", outcomes[0])
+		}
+
+		if batches := repo.Batches(); len(batches) != 1 {
+			t.Errorf("expected one batch, got %d", len(batches))
+		}
+	})
+
+	t.Run("should collapse a key to its newest scan", func(t *testing.T) {
+		b, repo := newTestBatcher(t, &BatcherConfig{MaxSize: 3, MaxDelay: time.Hour})
+
+		outcomes := putAll(t, b,
+			&scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 100, Response: "old"},
+			&scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 200, Response: "new"},
+			&scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 200, Response: "new"},
+		)
+
+		batches := repo.Batches()
+		if len(batches) != 1 || len(batches[0]) != 1 || batches[0][0].Timestamp != 200 {
+			t.Fatalf("expected one batch holding the newest scan, got %v", batches)
+		}
+
+		if outcomes[0] != scan_manager.StaleIgnored {
+			t.Errorf("expected the older scan to be stale, got %s", outcomes[0])
+		}
+
+		// One of the two copies is written and the other is its duplicate
+		if outcomes[1]+outcomes[2] != scan_manager.Inserted+scan_manager.Duplicate {
+			t.Errorf("expected inserted and duplicate, got %s and %s", outcomes[1], outcomes[2])
+		}
+
+		stored, err := repo.Get(context.Background(), scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "ssh"})
+		if err != nil || stored.Response != "new" {
+			t.Errorf("expected the newest scan to be stored, got %+v, %v", stored, err)
+		}
+	})
+
+	t.Run("should fail every put when the batch fails", func(t *testing.T) {
+		b, repo := newTestBatcher(t, &BatcherConfig{MaxSize: 2, MaxDelay: time.Hour})
+		repo.fail = errors.New("throttled")
+
+		var wg sync.WaitGroup
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := b.Put(context.Background(), &scan_manager.ScanResult{IP: ip, Port: 22, Service: "ssh", Timestamp: 100})
+				if !errors.Is(err, repo.fail) {
+					t.Errorf("expected batch error, got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("should return when the context ends first", func(t *testing.T) {
+		b, _ := newTestBatcher(t, &BatcherConfig{MaxSize: 100, MaxDelay: time.Hour})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := b.Put(ctx, &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 100})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("should flush pending scans on close", func(t *testing.T) {
+		b, repo := newTestBatcher(t, &BatcherConfig{MaxSize: 100, MaxDelay: time.Hour})
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := b.Put(context.Background(), &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 100})
+			done <- err
+		}()
+
+		// Wait for the put to be queued before closing
+		for {
+			b.mu.Lock()
+			queued := len(b.pending)
+			b.mu.Unlock()
+			if queued == 1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		b.Close()
+
+		if err := <-done; err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+
+		if batches := repo.Batches(); len(batches) != 1 {
+			t.Errorf("expected one batch, got %d", len(batches))
+		}
+
+		if _, err := b.Put(context.Background(), &scan_manager.ScanResult{IP: "10.0.0.2", Port: 22, Service: "ssh", Timestamp: 100}); !errors.Is(err, ErrClosed) {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,319 @@
+package bigtable
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// Columns in family. Numbers are 8 byte big-endian, as Bigtable's own
+// counters are, strings are stored as is.
+const (
+	colIP          = "ip"
+	colPort        = "port"
+	colService     = "service"
+	colTimestamp   = "timestamp"
+	colResponse    = "response"
+	colDataVersion = "data_version"
+)
+
+// latest reads the newest cell of each column
+var latest = bigtable.ChainFilters(bigtable.FamilyFilter(family), bigtable.LatestNFilter(1))
+
+type BigtableConfig struct {
+	// Client must reach an instance holding a table created by Bootstrap.
+	// It honours BIGTABLE_EMULATOR_HOST.
+	Client *bigtable.Client
+	// Table defaults to DefaultTable
+	Table string
+}
+
+// bigtableDB is a Repository on Bigtable. The latest scan per key is
+// compare-and-set with check-and-mutate on its timestamp cell, then copied
+// to the rows listed by service and port. Cells are written with the scan
+// timestamp as their version, so a copy of an older scan landing late is
+// never the newest cell read back.
+type bigtableDB struct {
+	table *bigtable.Table
+}
+
+func NewBigtable(cfg *BigtableConfig) (*bigtableDB, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Client == nil {
+		return nil, errors.New("Bigtable client is nil")
+	}
+
+	table := cfg.Table
+	if table == "" {
+		table = DefaultTable
+	}
+
+	return &bigtableDB{table: cfg.Client.Open(table)}, nil
+}
+
+// Put reads the latest row, then writes it conditionally on its timestamp
+// cell being absent, or holding the timestamp that was read. A failed
+// condition means another writer got in first, so it reads again and
+// retries. Each retry follows a write with a newer timestamp, so it
+// terminates.
+func (b *bigtableDB) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	if err := validKey(result.Key()); err != nil {
+		return nil, err
+	}
+
+	for {
+		existing, err := b.Get(ctx, result.Key())
+		if err != nil && !errors.Is(err, scan_manager.ErrNotFound) {
+			return nil, err
+		}
+
+		if existing != nil && existing.Timestamp >= result.Timestamp {
+			outcome := scan_manager.RejectedOutcome(existing, result)
+
+			// The listing rows are written outside the check-and-mutate
+			// and may have failed after it, rewrite them from the stored scan
+			if outcome == scan_manager.Duplicate {
+				if err := b.putCopies(ctx, existing); err != nil {
+					return nil, err
+				}
+			}
+
+			return &scan_manager.PutResult{Outcome: outcome, Previous: existing}, nil
+		}
+
+		applied, err := b.compareAndSet(ctx, result, existing)
+		if err != nil {
+			return nil, err
+		}
+
+		if !applied {
+			continue
+		}
+
+		if err := b.putCopies(ctx, result); err != nil {
+			return nil, err
+		}
+
+		if existing == nil {
+			return &scan_manager.PutResult{Outcome: scan_manager.Inserted}, nil
+		}
+
+		return &scan_manager.PutResult{Outcome: scan_manager.Updated, Previous: existing}, nil
+	}
+}
+
+// compareAndSet writes the latest row for result if it still holds existing
+func (b *bigtableDB) compareAndSet(ctx context.Context, result *scan_manager.ScanResult, existing *scan_manager.ScanResult) (bool, error) {
+	timestampCell := bigtable.ChainFilters(
+		bigtable.FamilyFilter(family),
+		bigtable.ColumnFilter(colTimestamp),
+		bigtable.LatestNFilter(1),
+	)
+
+	var mutation *bigtable.Mutation
+	if existing == nil {
+		// Written only when the row has no timestamp cell
+		mutation = bigtable.NewCondMutation(timestampCell, nil, scanMutation(result))
+	} else {
+		ts := encodeInt(existing.Timestamp)
+		match := bigtable.ChainFilters(timestampCell, bigtable.ValueRangeFilter(ts, append(ts, 0)))
+		mutation = bigtable.NewCondMutation(match, scanMutation(result), nil)
+	}
+
+	var matched bool
+	err := b.table.Apply(ctx, latestKey(result.Key()), mutation, bigtable.GetCondMutationResult(&matched))
+	if err != nil {
+		return false, fmt.Errorf("failed to write scan: %w", classify(err))
+	}
+
+	return matched == (existing != nil), nil
+}
+
+// putCopies writes result to the rows listed by service and port
+func (b *bigtableDB) putCopies(ctx context.Context, result *scan_manager.ScanResult) error {
+	key := result.Key()
+	rows := []string{serviceKey(key), portKey(key)}
+	mutations := []*bigtable.Mutation{scanMutation(result), scanMutation(result)}
+
+	errs, err := b.table.ApplyBulk(ctx, rows, mutations)
+	if err != nil {
+		return fmt.Errorf("failed to write listing rows: %w", classify(err))
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to write listing rows: %w", classify(err))
+		}
+	}
+
+	return nil
+}
+
+// scanMutation sets every column of result, versioned by its timestamp.
+// Versions are in microseconds at Bigtable's default millisecond
+// granularity, which whole seconds always fit.
+func scanMutation(result *scan_manager.ScanResult) *bigtable.Mutation {
+	version := bigtable.Timestamp(result.Timestamp * 1_000_000)
+
+	mutation := bigtable.NewMutation()
+	mutation.Set(family, colIP, version, []byte(result.IP))
+	mutation.Set(family, colPort, version, encodeInt(int64(result.Port)))
+	mutation.Set(family, colService, version, []byte(result.Service))
+	mutation.Set(family, colTimestamp, version, encodeInt(result.Timestamp))
+	mutation.Set(family, colResponse, version, []byte(result.Response))
+	mutation.Set(family, colDataVersion, version, encodeInt(int64(result.DataVersion)))
+
+	return mutation
+}
+
+// History is not supported, only the newest cells are kept
+func (b *bigtableDB) History(ctx context.Context, key scan_manager.ScanKey, from, to int64) ([]*scan_manager.HistoryEntry, error) {
+	return nil, scan_manager.ErrHistoryDisabled
+}
+
+func (b *bigtableDB) Get(ctx context.Context, key scan_manager.ScanKey) (*scan_manager.ScanResult, error) {
+	if validKey(key) != nil {
+		return nil, scan_manager.ErrNotFound
+	}
+
+	row, err := b.table.ReadRow(ctx, latestKey(key), bigtable.RowFilter(latest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan: %w", classify(err))
+	}
+
+	if len(row) == 0 {
+		return nil, scan_manager.ErrNotFound
+	}
+
+	return decodeRow(row)
+}
+
+func (b *bigtableDB) ListByIP(ctx context.Context, ip string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	if opts.Port != 0 {
+		return b.list(ctx, rowKey("ip", ip, port(opts.Port), ""), opts)
+	}
+
+	return b.list(ctx, rowKey("ip", ip, ""), opts)
+}
+
+func (b *bigtableDB) ListByService(ctx context.Context, service string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	if opts.Port != 0 {
+		return b.list(ctx, rowKey("service", service, port(opts.Port), ""), opts)
+	}
+
+	return b.list(ctx, rowKey("service", service, ""), opts)
+}
+
+func (b *bigtableDB) ListByPort(ctx context.Context, p uint32, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return b.list(ctx, rowKey("port", port(p), ""), opts)
+}
+
+// list reads a page of the rows beginning with prefix, which ends with the
+// separator. It reads one row more than the page holds to tell whether
+// another page follows.
+func (b *bigtableDB) list(ctx context.Context, prefix string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	rows := bigtable.PrefixRange(prefix)
+	if opts.PageToken != "" {
+		after, err := decodePageToken(opts.PageToken, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		rows = bigtable.NewRange(after+"\x00", prefixEnd(prefix))
+	}
+
+	limit := opts.PageSize()
+	page := &scan_manager.ListPage{}
+
+	var decodeErr error
+	var lastKey string
+	err := b.table.ReadRows(ctx, rows, func(row bigtable.Row) bool {
+		if len(page.Results) == limit {
+			page.NextPageToken = encodePageToken(lastKey)
+			return false
+		}
+
+		result, err := decodeRow(row)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+
+		page.Results = append(page.Results, result)
+		lastKey = row.Key()
+		return true
+	}, bigtable.RowFilter(latest), bigtable.LimitRows(int64(limit)+1))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scans: %w", classify(err))
+	}
+
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return page, nil
+}
+
+// decodeRow reads a scan from the newest cells of a row
+func decodeRow(row bigtable.Row) (*scan_manager.ScanResult, error) {
+	cells := make(map[string][]byte, len(row[family]))
+	for _, item := range row[family] {
+		// Columns are returned qualified by their family
+		cells[item.Column[len(family)+1:]] = item.Value
+	}
+
+	result := &scan_manager.ScanResult{
+		IP:       string(cells[colIP]),
+		Service:  string(cells[colService]),
+		Response: string(cells[colResponse]),
+	}
+
+	port, err := decodeInt(cells[colPort])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s of row %s: %w", colPort, row.Key(), err)
+	}
+	result.Port = uint32(port)
+
+	result.Timestamp, err = decodeInt(cells[colTimestamp])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s of row %s: %w", colTimestamp, row.Key(), err)
+	}
+
+	dataVersion, err := decodeInt(cells[colDataVersion])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s of row %s: %w", colDataVersion, row.Key(), err)
+	}
+	result.DataVersion = int(dataVersion)
+
+	return result, nil
+}
+
+func encodeInt(v int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(v))
+}
+
+func decodeInt(b []byte) (int64, error) {
+	if len(b) != 8 {
+		return 0, fmt.Errorf("expected 8 bytes, got %d", len(b))
+	}
+
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// Ping checks the table can be read
+func (b *bigtableDB) Ping(ctx context.Context) error {
+	_, err := b.table.ReadRow(ctx, "ping", bigtable.RowFilter(bigtable.StripValueFilter()))
+	if err != nil {
+		return fmt.Errorf("failed to read table: %w", classify(err))
+	}
+
+	return nil
+}
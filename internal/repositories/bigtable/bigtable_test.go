@@ -0,0 +1,295 @@
+package bigtable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/repotest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// emulator is an in-process Bigtable emulator, the one cbtemulator runs
+type emulator struct {
+	client *bigtable.Client
+	admin  *bigtable.AdminClient
+	tables atomic.Int64
+}
+
+func newEmulator(t *testing.T) *emulator {
+	t.Helper()
+	ctx := context.Background()
+
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("failed to start emulator: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial emulator: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client, err := bigtable.NewClient(ctx, "test-project", "test-instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	admin, err := bigtable.NewAdminClient(ctx, "test-project", "test-instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create admin client: %v", err)
+	}
+
+	return &emulator{client: client, admin: admin}
+}
+
+// newRepo bootstraps a fresh table and returns a repository on it
+func (e *emulator) newRepo(t *testing.T) *bigtableDB {
+	t.Helper()
+
+	table := fmt.Sprintf("scans-%d", e.tables.Add(1))
+	if err := Bootstrap(context.Background(), &BootstrapConfig{Admin: e.admin, Table: table}); err != nil {
+		t.Fatalf("failed to bootstrap table: %v", err)
+	}
+
+	b, err := NewBigtable(&BigtableConfig{Client: e.client, Table: table})
+	if err != nil {
+		t.Fatalf("failed to create Bigtable repository: %v", err)
+	}
+
+	return b
+}
+
+func TestNewBigtable(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewBigtable(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if client is nil", func(t *testing.T) {
+		_, err := NewBigtable(&BigtableConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestBootstrap(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		if err := Bootstrap(context.Background(), nil); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if admin client is nil", func(t *testing.T) {
+		if err := Bootstrap(context.Background(), &BootstrapConfig{}); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should be idempotent and add a missing family", func(t *testing.T) {
+		e := newEmulator(t)
+		ctx := context.Background()
+
+		if err := e.admin.CreateTable(ctx, DefaultTable); err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		for range 2 {
+			if err := Bootstrap(ctx, &BootstrapConfig{Admin: e.admin}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+
+		info, err := e.admin.TableInfo(ctx, DefaultTable)
+		if err != nil {
+			t.Fatalf("failed to describe table: %v", err)
+		}
+
+		if len(info.FamilyInfos) != 1 || info.FamilyInfos[0].Name != family {
+			t.Errorf("expected the %s family, got %+v", family, info.FamilyInfos)
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	e := newEmulator(t)
+
+	repotest.Run(t, func(t *testing.T) scan_manager.Repository {
+		return e.newRepo(t)
+	})
+}
+
+func TestPut_ConcurrentWriters(t *testing.T) {
+	b := newEmulator(t).newRepo(t)
+
+	// Every writer races to insert the key first, then to replace it
+	var wg sync.WaitGroup
+	var inserted atomic.Int64
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(ts int64) {
+			defer wg.Done()
+
+			put, err := b.Put(context.Background(), &scan_manager.ScanResult{
+				IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: ts, Response: fmt.Sprintf("response %d", ts),
+			})
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+				return
+			}
+
+			if put.Outcome == scan_manager.Inserted {
+				inserted.Add(1)
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	if inserted.Load() != 1 {
+		t.Errorf("expected exactly one insert, got %d", inserted.Load())
+	}
+
+	for _, list := range []func() (*scan_manager.ListPage, error){
+		func() (*scan_manager.ListPage, error) {
+			return b.ListByIP(context.Background(), "10.0.0.1", scan_manager.ListOptions{})
+		},
+		func() (*scan_manager.ListPage, error) {
+			return b.ListByService(context.Background(), "SSH", scan_manager.ListOptions{})
+		},
+		func() (*scan_manager.ListPage, error) {
+			return b.ListByPort(context.Background(), 22, scan_manager.ListOptions{})
+		},
+	} {
+		page, err := list()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(page.Results) != 1 || page.Results[0].Timestamp != 20 {
+			t.Errorf("expected the newest scan to be listed, got %+v", page.Results)
+		}
+	}
+}
+
+func TestPut_LateCopies(t *testing.T) {
+	b := newEmulator(t).newRepo(t)
+	ctx := context.Background()
+
+	newer := &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: 200, Response: "newer"}
+	if _, err := b.Put(ctx, newer); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// A writer that stored an older scan before being overtaken writes its
+	// copies last
+	older := &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: 100, Response: "older"}
+	if err := b.putCopies(ctx, older); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	page, err := b.ListByService(ctx, "SSH", scan_manager.ListOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(page.Results) != 1 || page.Results[0].Response != "newer" {
+		t.Errorf("expected the newer copy to be listed, got %+v", page.Results)
+	}
+}
+
+func TestPut_InvalidKeys(t *testing.T) {
+	b := newEmulator(t).newRepo(t)
+
+	for name, scan := range map[string]*scan_manager.ScanResult{
+		"separators in services": {IP: "10.0.0.1", Port: 22, Service: "SSH#2"},
+		"out of range ports":     {IP: "10.0.0.1", Port: 70000, Service: "SSH"},
+	} {
+		t.Run("should reject "+name, func(t *testing.T) {
+			if _, err := b.Put(context.Background(), scan); !errors.Is(err, scan_manager.ErrInvalidScan) {
+				t.Errorf("expected ErrInvalidScan, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPageToken(t *testing.T) {
+	t.Run("should round trip the last row key", func(t *testing.T) {
+		prefix := rowKey("service", "SSH", "")
+		last := serviceKey(scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"})
+
+		after, err := decodePageToken(encodePageToken(last), prefix)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if after != last {
+			t.Errorf("expected %s, got %s", last, after)
+		}
+	})
+
+	t.Run("should reject tokens for another listing", func(t *testing.T) {
+		token := encodePageToken(portKey(scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"}))
+
+		if _, err := decodePageToken(token, rowKey("service", "SSH", "")); !errors.Is(err, scan_manager.ErrInvalidPageToken) {
+			t.Errorf("expected ErrInvalidPageToken, got %v", err)
+		}
+	})
+
+	t.Run("should end the range after every key with the prefix", func(t *testing.T) {
+		prefix := rowKey("ip", "10.0.0.1", "")
+		end := prefixEnd(prefix)
+
+		if !(strings.Compare(prefix+"\xff", end) < 0 && strings.Compare(rowKey("ip", "10.0.0.10", ""), end) >= 0) {
+			t.Errorf("expected %q to end the %q range", end, prefix)
+		}
+	})
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"exhausted quotas", status.Error(codes.ResourceExhausted, "quota"), scan_manager.ErrThrottled},
+		{"oversized mutations", status.Error(codes.InvalidArgument, "too large"), scan_manager.ErrInvalidScan},
+		{"unavailable servers", status.Error(codes.Unavailable, "unavailable"), scan_manager.ErrUnavailable},
+		{"deadlines", status.Error(codes.DeadlineExceeded, "deadline"), scan_manager.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run("should classify "+tt.name, func(t *testing.T) {
+			err := classify(tt.err)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected the original error to be wrapped, got %v", err)
+			}
+		})
+	}
+
+	t.Run("should leave unknown errors unclassified", func(t *testing.T) {
+		err := status.Error(codes.NotFound, "no table")
+		if classify(err) != err {
+			t.Errorf("expected the error unchanged")
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package bigtable
+
+import (
+	"fmt"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// classify maps gRPC status codes to scan_manager error classes. The client never retries conditional mutations.
+func classify(err error) error {
+	switch status.Code(err) {
+	case codes.ResourceExhausted:
+		return fmt.Errorf("%w: %w", scan_manager.ErrThrottled, err)
+	case codes.InvalidArgument:
+		// Rejected mutations, such as cells over the size limit
+		return fmt.Errorf("%w: %w", scan_manager.ErrInvalidScan, err)
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.Internal:
+		return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+	}
+
+	return err
+}
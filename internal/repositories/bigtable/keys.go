@@ -0,0 +1,82 @@
+package bigtable
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// Row keys are '#' separated and begin with the field they are listed by,
+// so each listing is a prefix scan in key order:
+//
+//	ip#<ip>#<port>#<service>       the latest scan, also listed by IP
+//	service#<service>#<port>#<ip>  a copy listed by service
+//	port#<port>#<ip>#<service>     a copy listed by port
+//
+// Ports are zero padded so they sort numerically.
+const separator = "#"
+
+// maxPort is the widest port the padded key field holds
+const maxPort = 65535
+
+func port(p uint32) string {
+	return fmt.Sprintf("%05d", p)
+}
+
+func rowKey(parts ...string) string {
+	return strings.Join(parts, separator)
+}
+
+func latestKey(key scan_manager.ScanKey) string {
+	return rowKey("ip", key.IP, port(key.Port), key.Service)
+}
+
+func serviceKey(key scan_manager.ScanKey) string {
+	return rowKey("service", key.Service, port(key.Port), key.IP)
+}
+
+func portKey(key scan_manager.ScanKey) string {
+	return rowKey("port", port(key.Port), key.IP, key.Service)
+}
+
+// validKey reports whether key fits the row key layout. A separator in the
+// IP or service would let prefix scans match another key's rows.
+func validKey(key scan_manager.ScanKey) error {
+	if strings.Contains(key.IP, separator) || strings.Contains(key.Service, separator) {
+		return fmt.Errorf("%w: %s may not contain %q", scan_manager.ErrInvalidScan, key, separator)
+	}
+
+	if key.Port > maxPort {
+		return fmt.Errorf("%w: port %d out of range", scan_manager.ErrInvalidScan, key.Port)
+	}
+
+	return nil
+}
+
+// prefixEnd is the first row key after every key beginning with prefix,
+// which always ends with the separator
+func prefixEnd(prefix string) string {
+	return prefix[:len(prefix)-1] + string(prefix[len(prefix)-1]+1)
+}
+
+// encodePageToken turns the last row key of a page into an opaque page token
+func encodePageToken(lastKey string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+}
+
+// decodePageToken returns the row key a page token continues after. It must
+// belong to the listing being paged.
+func decodePageToken(token, prefix string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", scan_manager.ErrInvalidPageToken, err)
+	}
+
+	if !strings.HasPrefix(string(data), prefix) {
+		return "", fmt.Errorf("%w: token is for another listing", scan_manager.ErrInvalidPageToken)
+	}
+
+	return string(data), nil
+}
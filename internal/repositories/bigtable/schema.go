@@ -0,0 +1,79 @@
+package bigtable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"cloud.google.com/go/bigtable"
+)
+
+const (
+	// DefaultTable is the table used when a config leaves it empty
+	DefaultTable = "scans"
+
+	// family holds every column. Only the newest cell of each is read, older
+	// ones are left to garbage collection.
+	family = "scan"
+)
+
+type BootstrapConfig struct {
+	Admin *bigtable.AdminClient
+	// Table defaults to DefaultTable
+	Table string
+}
+
+// Bootstrap idempotently creates the table and its column family, keeping
+// one version of each cell
+func Bootstrap(ctx context.Context, cfg *BootstrapConfig) error {
+	if cfg == nil {
+		return errors.New("config is nil")
+	}
+
+	if cfg.Admin == nil {
+		return errors.New("Bigtable admin client is nil")
+	}
+
+	table := cfg.Table
+	if table == "" {
+		table = DefaultTable
+	}
+
+	tables, err := cfg.Admin.Tables(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	if !slices.Contains(tables, table) {
+		err := cfg.Admin.CreateTableFromConf(ctx, &bigtable.TableConf{
+			TableID: table,
+			ColumnFamilies: map[string]bigtable.Family{
+				family: {GCPolicy: bigtable.MaxVersionsPolicy(1)},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create table %s: %w", table, err)
+		}
+
+		return nil
+	}
+
+	// The table may predate the family, or have been created by hand
+	info, err := cfg.Admin.TableInfo(ctx, table)
+	if err != nil {
+		return fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+
+	if !slices.ContainsFunc(info.FamilyInfos, func(f bigtable.FamilyInfo) bool { return f.Name == family }) {
+		if err := cfg.Admin.CreateColumnFamily(ctx, table, family); err != nil {
+			return fmt.Errorf("failed to create column family %s: %w", family, err)
+		}
+	}
+
+	if err := cfg.Admin.SetGCPolicy(ctx, table, family, bigtable.MaxVersionsPolicy(1)); err != nil {
+		return fmt.Errorf("failed to set garbage collection policy on %s: %w", family, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenTimeout      = 10 * time.Second
+)
+
+// ErrOpen is returned by Put while the breaker is open. It is an
+// ErrUnavailable so callers treat it like the outage that tripped it.
+var ErrOpen = fmt.Errorf("%w: circuit breaker is open", scan_manager.ErrUnavailable)
+
+type State int
+
+const (
+	// Closed passes every Put through
+	Closed State = iota
+	// HalfOpen lets a single probe Put through, the rest wait for its result
+	HalfOpen
+	// Open fails every Put with ErrOpen until the open timeout passes
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+type BreakerConfig struct {
+	Repo scan_manager.Repository
+	// FailureThreshold is the consecutive failed Puts that open the breaker. Defaults to DefaultFailureThreshold.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before probing. Defaults to DefaultOpenTimeout.
+	OpenTimeout time.Duration
+	// OnStateChange is optional and called on every transition, outside the breaker's lock
+	OnStateChange func(from, to State)
+}
+
+// breaker is a Repository decorator that stops writes to a failing store.
+// Errors caused by the scan itself and canceled contexts aren't failures of
+// the store and don't count towards opening it.
+type breaker struct {
+	scan_manager.Repository
+	threshold     int
+	openTimeout   time.Duration
+	onStateChange func(from, to State)
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	probing  bool
+	// changed is closed and replaced on every transition to wake waiters
+	changed chan struct{}
+}
+
+func NewBreaker(cfg *BreakerConfig) (*breaker, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Repo == nil {
+		return nil, errors.New("repository is nil")
+	}
+
+	b := &breaker{
+		Repository:    cfg.Repo,
+		threshold:     cfg.FailureThreshold,
+		openTimeout:   cfg.OpenTimeout,
+		onStateChange: cfg.OnStateChange,
+		changed:       make(chan struct{}),
+	}
+
+	if b.threshold <= 0 {
+		b.threshold = DefaultFailureThreshold
+	}
+
+	if b.openTimeout <= 0 {
+		b.openTimeout = DefaultOpenTimeout
+	}
+
+	return b, nil
+}
+
+// State returns the current state
+func (b *breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Wait blocks until the breaker isn't open or ctx ends
+func (b *breaker) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		state, changed := b.state, b.changed
+		b.mu.Unlock()
+
+		if state != Open {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (b *breaker) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	for {
+		b.mu.Lock()
+		switch {
+		case b.state == Open:
+			b.mu.Unlock()
+			return nil, ErrOpen
+		case b.state == HalfOpen && b.probing:
+			changed := b.changed
+			b.mu.Unlock()
+
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		probe := b.state == HalfOpen
+		b.probing = probe
+		b.mu.Unlock()
+
+		put, err := b.Repository.Put(ctx, result)
+		b.record(err, probe)
+		return put, err
+	}
+}
+
+// record updates the breaker with the result of a Put. Only the probe
+// decides what happens to a half-open breaker, and results of Puts that
+// started before the breaker opened are ignored.
+func (b *breaker) record(err error, probe bool) {
+	failed := err != nil && !errors.Is(err, scan_manager.ErrInvalidScan) && !errors.Is(err, context.Canceled)
+
+	b.mu.Lock()
+	from := b.state
+
+	switch {
+	case probe && err != nil && !failed:
+		// Inconclusive, let the next Put probe instead
+		b.probing = false
+		b.broadcast()
+	case probe && failed:
+		b.open()
+	case probe:
+		b.probing = false
+		b.failures = 0
+		b.state = Closed
+		b.broadcast()
+	case b.state != Closed:
+	case failed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	default:
+		b.failures = 0
+	}
+
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// open trips the breaker and schedules the half-open probe. Callers must hold mu.
+func (b *breaker) open() {
+	b.state = Open
+	b.probing = false
+	b.failures = 0
+	b.broadcast()
+
+	time.AfterFunc(b.openTimeout, b.halfOpen)
+}
+
+func (b *breaker) halfOpen() {
+	b.mu.Lock()
+	from := b.state
+	if from == Open {
+		b.state = HalfOpen
+		b.broadcast()
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// broadcast wakes everything waiting on a transition. Callers must hold mu.
+func (b *breaker) broadcast() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *breaker) notify(from, to State) {
+	if from != to && b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
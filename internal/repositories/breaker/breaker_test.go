@@ -0,0 +1,257 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// switchRepo fails every Put with err while it is set
+type switchRepo struct {
+	scan_manager.Repository
+	mu    sync.Mutex
+	err   error
+	calls int
+	// block holds Puts until it is closed, when set
+	block chan struct{}
+}
+
+func (r *switchRepo) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	r.mu.Lock()
+	r.calls++
+	err, block := r.err, r.block
+	r.mu.Unlock()
+
+	if block != nil {
+		<-block
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &scan_manager.PutResult{Outcome: scan_manager.Inserted}, nil
+}
+
+func (r *switchRepo) set(err error) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
+// transitions records state changes
+type transitions struct {
+	mu     sync.Mutex
+	states []State
+}
+
+func (tr *transitions) record(from, to State) {
+	tr.mu.Lock()
+	tr.states = append(tr.states, to)
+	tr.mu.Unlock()
+}
+
+func (tr *transitions) String() string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return fmt.Sprint(tr.states)
+}
+
+func newTestBreaker(t *testing.T, repo scan_manager.Repository, tr *transitions) *breaker {
+	b, err := NewBreaker(&BreakerConfig{
+		Repo:             repo,
+		FailureThreshold: 2,
+		OpenTimeout:      10 * time.Millisecond,
+		OnStateChange:    tr.record,
+	})
+	if err != nil {
+		t.Fatalf("failed to create breaker: %v", err)
+	}
+
+	return b
+}
+
+var scan = &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 100}
+
+func TestNewBreaker(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewBreaker(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if repository is nil", func(t *testing.T) {
+		_, err := NewBreaker(&BreakerConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestBreaker(t *testing.T) {
+	t.Run("should open after consecutive failures", func(t *testing.T) {
+		repo := &switchRepo{err: scan_manager.ErrUnavailable}
+		tr := &transitions{}
+		b := newTestBreaker(t, repo, tr)
+
+		_, _ = b.Put(context.Background(), scan)
+		if b.State() != Closed {
+			t.Fatalf("expected closed after one failure, got %s", b.State())
+		}
+
+		_, _ = b.Put(context.Background(), scan)
+		if b.State() != Open {
+			t.Fatalf("expected open after two failures, got %s", b.State())
+		}
+
+		_, err := b.Put(context.Background(), scan)
+		if !errors.Is(err, ErrOpen) || !errors.Is(err, scan_manager.ErrUnavailable) {
+			t.Errorf("expected ErrOpen, got %v", err)
+		}
+
+		if repo.calls != 2 {
+			t.Errorf("expected the open breaker to skip the repository, got %d calls", repo.calls)
+		}
+	})
+
+	t.Run("should not count invalid scans or cancellations", func(t *testing.T) {
+		repo := &switchRepo{err: scan_manager.ErrInvalidScan}
+		b := newTestBreaker(t, repo, &transitions{})
+
+		for range 3 {
+			_, _ = b.Put(context.Background(), scan)
+		}
+
+		repo.set(context.Canceled)
+		for range 3 {
+			_, _ = b.Put(context.Background(), scan)
+		}
+
+		if b.State() != Closed {
+			t.Errorf("expected closed, got %s", b.State())
+		}
+	})
+
+	t.Run("should reset the count on success", func(t *testing.T) {
+		repo := &switchRepo{err: scan_manager.ErrUnavailable}
+		b := newTestBreaker(t, repo, &transitions{})
+
+		_, _ = b.Put(context.Background(), scan)
+		repo.set(nil)
+		_, _ = b.Put(context.Background(), scan)
+		repo.set(scan_manager.ErrUnavailable)
+		_, _ = b.Put(context.Background(), scan)
+
+		if b.State() != Closed {
+			t.Errorf("expected closed, got %s", b.State())
+		}
+	})
+
+	t.Run("should close after a successful probe", func(t *testing.T) {
+		repo := &switchRepo{err: scan_manager.ErrUnavailable}
+		tr := &transitions{}
+		b := newTestBreaker(t, repo, tr)
+
+		_, _ = b.Put(context.Background(), scan)
+		_, _ = b.Put(context.Background(), scan)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := b.Wait(ctx); err != nil {
+			t.Fatalf("expected the breaker to half-open, got %v", err)
+		}
+
+		repo.set(nil)
+		if _, err := b.Put(context.Background(), scan); err != nil {
+			t.Fatalf("expected the probe to succeed, got %v", err)
+		}
+
+		if b.State() != Closed {
+			t.Errorf("expected closed, got %s", b.State())
+		}
+
+		if got := tr.String(); got != "[open half-open closed]" {
+			t.Errorf("unexpected transitions %s", got)
+		}
+	})
+
+	t.Run("should reopen after a failed probe", func(t *testing.T) {
+		repo := &switchRepo{err: scan_manager.ErrUnavailable}
+		tr := &transitions{}
+		b := newTestBreaker(t, repo, tr)
+
+		_, _ = b.Put(context.Background(), scan)
+		_, _ = b.Put(context.Background(), scan)
+		_ = b.Wait(context.Background())
+		_, _ = b.Put(context.Background(), scan)
+
+		if b.State() != Open {
+			t.Errorf("expected open, got %s", b.State())
+		}
+
+		if got := tr.String(); got != "[open half-open open]" {
+			t.Errorf("unexpected transitions %s", got)
+		}
+	})
+
+	t.Run("should hold other puts while probing", func(t *testing.T) {
+		repo := &switchRepo{err: scan_manager.ErrUnavailable}
+		b := newTestBreaker(t, repo, &transitions{})
+
+		_, _ = b.Put(context.Background(), scan)
+		_, _ = b.Put(context.Background(), scan)
+		_ = b.Wait(context.Background())
+
+		repo.mu.Lock()
+		repo.err = nil
+		repo.block = make(chan struct{})
+		repo.mu.Unlock()
+
+		probeDone := make(chan error, 1)
+		go func() {
+			_, err := b.Put(context.Background(), scan)
+			probeDone <- err
+		}()
+
+		// Wait for the probe to reach the repository
+		for {
+			repo.mu.Lock()
+			calls := repo.calls
+			repo.mu.Unlock()
+			if calls == 3 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		heldDone := make(chan error, 1)
+		go func() {
+			_, err := b.Put(context.Background(), scan)
+			heldDone <- err
+		}()
+
+		select {
+		case err := <-heldDone:
+			t.Fatalf("expected the put to wait for the probe, got %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		repo.mu.Lock()
+		close(repo.block)
+		repo.block = nil
+		repo.mu.Unlock()
+
+		if err := <-probeDone; err != nil {
+			t.Errorf("expected the probe to succeed, got %v", err)
+		}
+
+		if err := <-heldDone; err != nil {
+			t.Errorf("expected the held put to succeed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package cassandra
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/gocql/gocql"
+)
+
+// columns are the scan columns in the order scanResult reads them
+const columns = "ip, port, service, timestamp, response, data_version"
+
+type CassandraConfig struct {
+	// Session must reach a cluster bootstrapped by Bootstrap. It works with
+	// both Cassandra and ScyllaDB.
+	Session *gocql.Session
+	// Keyspace defaults to DefaultKeyspace
+	Keyspace string
+	// SerialConsistency is how Put reads the scan it compares against,
+	// gocql.LocalSerial for clusters spanning datacenters. Defaults to
+	// gocql.Serial.
+	SerialConsistency gocql.SerialConsistency
+}
+
+// cassandra is a Repository for CQL stores. The latest scan per key is
+// compare-and-set with lightweight transactions, then copied to a query
+// table per listing. Listings read the copies, so like DynamoDB's index
+// reads they may briefly lag a Put.
+type cassandra struct {
+	session  *gocql.Session
+	keyspace string
+	serial   gocql.SerialConsistency
+}
+
+func NewCassandra(cfg *CassandraConfig) (*cassandra, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Session == nil {
+		return nil, errors.New("Cassandra session is nil")
+	}
+
+	keyspace, err := keyspaceOrDefault(cfg.Keyspace)
+	if err != nil {
+		return nil, err
+	}
+
+	serial := cfg.SerialConsistency
+	if serial == 0 {
+		serial = gocql.Serial
+	}
+
+	return &cassandra{session: cfg.Session, keyspace: keyspace, serial: serial}, nil
+}
+
+// Put reads the stored scan at SERIAL consistency, then inserts with IF NOT
+// EXISTS or updates with IF on the timestamp it read. A failed condition
+// means another writer got in first, so it reads again and retries. Each
+// retry follows a write with a newer timestamp, so it terminates.
+func (c *cassandra) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	for {
+		existing, err := c.get(ctx, result.Key(), true)
+		if err != nil && !errors.Is(err, scan_manager.ErrNotFound) {
+			return nil, err
+		}
+
+		if existing != nil && existing.Timestamp >= result.Timestamp {
+			outcome := scan_manager.RejectedOutcome(existing, result)
+
+			// Copies written after the lightweight transaction may have
+			// failed, rewriting them at the stored scan's write time is safe
+			if outcome == scan_manager.Duplicate {
+				if err := c.putCopies(ctx, existing); err != nil {
+					return nil, err
+				}
+			}
+
+			return &scan_manager.PutResult{Outcome: outcome, Previous: existing}, nil
+		}
+
+		applied, err := c.compareAndSet(ctx, result, existing)
+		if err != nil {
+			return nil, err
+		}
+
+		if !applied {
+			continue
+		}
+
+		if err := c.putCopies(ctx, result); err != nil {
+			return nil, err
+		}
+
+		if existing == nil {
+			return &scan_manager.PutResult{Outcome: scan_manager.Inserted}, nil
+		}
+
+		return &scan_manager.PutResult{Outcome: scan_manager.Updated, Previous: existing}, nil
+	}
+}
+
+// compareAndSet writes result if the stored scan is still existing
+func (c *cassandra) compareAndSet(ctx context.Context, result *scan_manager.ScanResult, existing *scan_manager.ScanResult) (bool, error) {
+	var query *gocql.Query
+	if existing == nil {
+		query = c.session.Query(
+			`INSERT INTO `+c.table("scans")+` (`+columns+`) VALUES (?, ?, ?, ?, ?, ?) IF NOT EXISTS`,
+			result.IP, result.Port, result.Service, result.Timestamp, result.Response, result.DataVersion,
+		)
+	} else {
+		query = c.session.Query(
+			`UPDATE `+c.table("scans")+` SET timestamp = ?, response = ?, data_version = ?
+			WHERE ip = ? AND port = ? AND service = ? IF timestamp = ?`,
+			result.Timestamp, result.Response, result.DataVersion,
+			result.IP, result.Port, result.Service, existing.Timestamp,
+		)
+	}
+
+	// The row returned when the condition fails is read again by the caller
+	applied, err := query.WithContext(ctx).MapScanCAS(map[string]any{})
+	if err != nil {
+		return false, fmt.Errorf("failed to write scan: %w", classify(err))
+	}
+
+	return applied, nil
+}
+
+// putCopies writes result to the query tables. The scan timestamp, in
+// microseconds, is the write time, so a copy of an older scan landing late
+// loses to the newer one.
+func (c *cassandra) putCopies(ctx context.Context, result *scan_manager.ScanResult) error {
+	batch := c.session.NewBatch(gocql.LoggedBatch).WithContext(ctx).WithTimestamp(result.Timestamp * 1_000_000)
+	for _, table := range []string{"scans_by_ip", "scans_by_service", "scans_by_port"} {
+		batch.Query(
+			`INSERT INTO `+c.table(table)+` (`+columns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+			result.IP, result.Port, result.Service, result.Timestamp, result.Response, result.DataVersion,
+		)
+	}
+
+	if err := c.session.ExecuteBatch(batch); err != nil {
+		return fmt.Errorf("failed to write query tables: %w", classify(err))
+	}
+
+	return nil
+}
+
+// History is not supported, the store only keeps the latest scan
+func (c *cassandra) History(ctx context.Context, key scan_manager.ScanKey, from, to int64) ([]*scan_manager.HistoryEntry, error) {
+	return nil, scan_manager.ErrHistoryDisabled
+}
+
+func (c *cassandra) Get(ctx context.Context, key scan_manager.ScanKey) (*scan_manager.ScanResult, error) {
+	return c.get(ctx, key, false)
+}
+
+// get reads the scan for key at the session's consistency, or when serial is
+// set at the serial consistency, which also completes lightweight
+// transactions still in progress
+func (c *cassandra) get(ctx context.Context, key scan_manager.ScanKey, serial bool) (*scan_manager.ScanResult, error) {
+	query := c.session.Query(
+		`SELECT `+columns+` FROM `+c.table("scans")+` WHERE ip = ? AND port = ? AND service = ?`,
+		key.IP, key.Port, key.Service,
+	).WithContext(ctx)
+
+	if serial {
+		query = query.Consistency(gocql.Consistency(c.serial))
+	}
+
+	result, err := scanResult(query)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return nil, scan_manager.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan: %w", classify(err))
+	}
+
+	return result, nil
+}
+
+func (c *cassandra) ListByIP(ctx context.Context, ip string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return c.list(ctx, "scans_by_ip", "ip", ip, opts)
+}
+
+func (c *cassandra) ListByService(ctx context.Context, service string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return c.list(ctx, "scans_by_service", "service", service, opts)
+}
+
+func (c *cassandra) ListByPort(ctx context.Context, port uint32, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	opts.Port = 0
+	return c.list(ctx, "scans_by_port", "port", port, opts)
+}
+
+// list reads a page from the query table partitioned on column. The page
+// token is the driver's paging state, which may be set on a last page that
+// turns out to be empty.
+func (c *cassandra) list(ctx context.Context, table, column string, value any, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	var state []byte
+	if opts.PageToken != "" {
+		var err error
+		state, err = base64.RawURLEncoding.DecodeString(opts.PageToken)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", scan_manager.ErrInvalidPageToken, err)
+		}
+	}
+
+	stmt := `SELECT ` + columns + ` FROM ` + c.table(table) + ` WHERE ` + column + ` = ?`
+	args := []any{value}
+
+	// port is the first clustering column of the ip and service tables
+	if opts.Port != 0 {
+		stmt += ` AND port = ?`
+		args = append(args, opts.Port)
+	}
+
+	// Setting the paging state, even to nil, stops the driver fetching
+	// further pages itself
+	iter := c.session.Query(stmt, args...).WithContext(ctx).PageSize(opts.PageSize()).PageState(state).Iter()
+
+	page := &scan_manager.ListPage{}
+	scanner := iter.Scanner()
+	for scanner.Next() {
+		result := &scan_manager.ScanResult{}
+		err := scanner.Scan(&result.IP, &result.Port, &result.Service, &result.Timestamp, &result.Response, &result.DataVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read scan: %w", err)
+		}
+		page.Results = append(page.Results, result)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list scans from %s: %w", table, classify(err))
+	}
+
+	if next := iter.PageState(); len(next) > 0 {
+		page.NextPageToken = base64.RawURLEncoding.EncodeToString(next)
+	}
+
+	return page, nil
+}
+
+// scanResult reads the single row selected with columns by query
+func scanResult(query *gocql.Query) (*scan_manager.ScanResult, error) {
+	result := &scan_manager.ScanResult{}
+	err := query.Scan(&result.IP, &result.Port, &result.Service, &result.Timestamp, &result.Response, &result.DataVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// table qualifies a table name with the keyspace, so the session doesn't
+// need to be bound to it
+func (c *cassandra) table(name string) string {
+	return c.keyspace + "." + name
+}
+
+// Ping checks the scans table can be queried
+func (c *cassandra) Ping(ctx context.Context) error {
+	err := c.session.Query(`SELECT ip FROM ` + c.table("scans") + ` LIMIT 1`).WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", c.table("scans"), classify(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/gocql/gocql"
+)
+
+func TestNewCassandra(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewCassandra(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if session is nil", func(t *testing.T) {
+		_, err := NewCassandra(&CassandraConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error for an invalid keyspace", func(t *testing.T) {
+		_, err := NewCassandra(&CassandraConfig{Session: &gocql.Session{}, Keyspace: "scans; DROP KEYSPACE system"})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should default the keyspace", func(t *testing.T) {
+		repo, err := NewCassandra(&CassandraConfig{Session: &gocql.Session{}})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if repo.table("scans") != DefaultKeyspace+".scans" {
+			t.Errorf("expected %s.scans, got %s", DefaultKeyspace, repo.table("scans"))
+		}
+	})
+}
+
+func TestBootstrap(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		if err := Bootstrap(context.Background(), nil); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if session is nil", func(t *testing.T) {
+		if err := Bootstrap(context.Background(), &BootstrapConfig{}); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	for _, datacenter := range []string{"dc1', 'other': 3}; DROP KEYSPACE system; --", "-dc1", "dc 1"} {
+		t.Run("should reject datacenter "+datacenter, func(t *testing.T) {
+			err := Bootstrap(context.Background(), &BootstrapConfig{Session: &gocql.Session{}, Datacenter: datacenter})
+			if err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestDatacenterPattern(t *testing.T) {
+	for _, datacenter := range []string{"datacenter1", "dc1", "us-east-1", "eu_west.2"} {
+		t.Run("should accept "+datacenter, func(t *testing.T) {
+			if !datacenterPattern.MatchString(datacenter) {
+				t.Errorf("expected %q to match", datacenter)
+			}
+		})
+	}
+}
+
+func TestKeyspaceOrDefault(t *testing.T) {
+	for _, keyspace := range []string{"mini_scan", "Scans2"} {
+		t.Run("should accept "+keyspace, func(t *testing.T) {
+			if _, err := keyspaceOrDefault(keyspace); err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+
+	for _, keyspace := range []string{"2scans", "mini-scan", `"quoted"`, "a_name_longer_than_the_forty_eight_characters_allowed"} {
+		t.Run("should reject "+keyspace, func(t *testing.T) {
+			if _, err := keyspaceOrDefault(keyspace); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Run("should reject malformed page tokens", func(t *testing.T) {
+		repo, _ := NewCassandra(&CassandraConfig{Session: &gocql.Session{}})
+
+		_, err := repo.ListByIP(context.Background(), "10.0.0.1", scan_manager.ListOptions{PageToken: "!!"})
+		if !errors.Is(err, scan_manager.ErrInvalidPageToken) {
+			t.Errorf("expected ErrInvalidPageToken, got %v", err)
+		}
+	})
+}
+
+func TestHistory(t *testing.T) {
+	repo, _ := NewCassandra(&CassandraConfig{Session: &gocql.Session{}})
+
+	_, err := repo.History(context.Background(), scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"}, 0, 0)
+	if !errors.Is(err, scan_manager.ErrHistoryDisabled) {
+		t.Errorf("expected ErrHistoryDisabled, got %v", err)
+	}
+}
+
+// requestError is a server error with the given code, the driver's own
+// error types can't be built outside it
+type requestError int
+
+func (e requestError) Code() int       { return int(e) }
+func (e requestError) Message() string { return "request failed" }
+func (e requestError) Error() string   { return e.Message() }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"overloaded nodes", requestError(gocql.ErrCodeOverloaded), scan_manager.ErrThrottled},
+		{"invalid requests", requestError(gocql.ErrCodeInvalid), scan_manager.ErrInvalidScan},
+		{"write timeouts", requestError(gocql.ErrCodeWriteTimeout), scan_manager.ErrUnavailable},
+		{"unknown lightweight transaction results", requestError(gocql.ErrCodeCASWriteUnknown), scan_manager.ErrUnavailable},
+		{"missing replicas", requestError(gocql.ErrCodeUnavailable), scan_manager.ErrUnavailable},
+		{"no connections", gocql.ErrNoConnections, scan_manager.ErrUnavailable},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, scan_manager.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run("should classify "+tt.name, func(t *testing.T) {
+			err := classify(tt.err)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected the original error to be wrapped, got %v", err)
+			}
+		})
+	}
+
+	t.Run("should leave unknown errors unclassified", func(t *testing.T) {
+		err := requestError(gocql.ErrCodeUnauthorized)
+		if classify(err) != error(err) {
+			t.Errorf("expected the error unchanged")
+		}
+	})
+}
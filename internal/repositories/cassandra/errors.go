@@ -0,0 +1,40 @@
+package cassandra
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/gocql/gocql"
+)
+
+// classify maps CQL error codes and gocql connection errors to scan_manager error classes
+func classify(err error) error {
+	var reqErr gocql.RequestError
+	if errors.As(err, &reqErr) {
+		switch reqErr.Code() {
+		case gocql.ErrCodeOverloaded:
+			return fmt.Errorf("%w: %w", scan_manager.ErrThrottled, err)
+		case gocql.ErrCodeInvalid:
+			// Rejected mutations, such as one over the commit log's size limit
+			return fmt.Errorf("%w: %w", scan_manager.ErrInvalidScan, err)
+		case gocql.ErrCodeUnavailable, gocql.ErrCodeBootstrapping, gocql.ErrCodeWriteTimeout, gocql.ErrCodeWriteFailure,
+			gocql.ErrCodeReadTimeout, gocql.ErrCodeReadFailure, gocql.ErrCodeCASWriteUnknown, gocql.ErrCodeServer:
+			return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+		}
+
+		return err
+	}
+
+	if errors.Is(err, gocql.ErrNoConnections) || errors.Is(err, gocql.ErrTimeoutNoResponse) || errors.Is(err, gocql.ErrConnectionClosed) {
+		return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+	}
+
+	return err
+}
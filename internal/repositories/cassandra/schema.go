@@ -0,0 +1,114 @@
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/gocql/gocql"
+)
+
+// DefaultKeyspace is the keyspace used when a config leaves it empty
+const DefaultKeyspace = "mini_scan"
+
+// keyspacePattern matches the unquoted identifiers CQL accepts as keyspace names
+var keyspacePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,47}$`)
+
+// datacenterPattern matches datacenter names such as dc1 or us-east-1. They
+// are interpolated into the replication map as string literals, so quotes
+// must never get through.
+var datacenterPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// tables are created by Bootstrap. scans holds the latest scan per key and
+// is only written with lightweight transactions. The query tables copy it,
+// keyed for each listing, and are written with the scan timestamp as the
+// write time so the newest scan wins however late its copy lands.
+var tables = []string{
+	`CREATE TABLE IF NOT EXISTS %s.scans (
+		ip text, port int, service text, timestamp bigint, response text, data_version int,
+		PRIMARY KEY ((ip, port, service))
+	)`,
+	`CREATE TABLE IF NOT EXISTS %s.scans_by_ip (
+		ip text, port int, service text, timestamp bigint, response text, data_version int,
+		PRIMARY KEY ((ip), port, service)
+	)`,
+	`CREATE TABLE IF NOT EXISTS %s.scans_by_service (
+		ip text, port int, service text, timestamp bigint, response text, data_version int,
+		PRIMARY KEY ((service), port, ip)
+	)`,
+	`CREATE TABLE IF NOT EXISTS %s.scans_by_port (
+		ip text, port int, service text, timestamp bigint, response text, data_version int,
+		PRIMARY KEY ((port), ip, service)
+	)`,
+}
+
+type BootstrapConfig struct {
+	Session *gocql.Session
+	// Keyspace defaults to DefaultKeyspace
+	Keyspace string
+	// ReplicationFactor defaults to 1
+	ReplicationFactor int
+	// Datacenter switches replication from SimpleStrategy to
+	// NetworkTopologyStrategy with ReplicationFactor replicas in it
+	Datacenter string
+}
+
+// Bootstrap idempotently creates the keyspace and tables. An existing
+// keyspace keeps its replication settings.
+func Bootstrap(ctx context.Context, cfg *BootstrapConfig) error {
+	if cfg == nil {
+		return errors.New("config is nil")
+	}
+
+	if cfg.Session == nil {
+		return errors.New("Cassandra session is nil")
+	}
+
+	keyspace, err := keyspaceOrDefault(cfg.Keyspace)
+	if err != nil {
+		return err
+	}
+
+	rf := cfg.ReplicationFactor
+	if rf <= 0 {
+		rf = 1
+	}
+
+	replication := fmt.Sprintf(`{'class': 'SimpleStrategy', 'replication_factor': %d}`, rf)
+	if cfg.Datacenter != "" {
+		if !datacenterPattern.MatchString(cfg.Datacenter) {
+			return fmt.Errorf("invalid datacenter name %q", cfg.Datacenter)
+		}
+
+		replication = fmt.Sprintf(`{'class': 'NetworkTopologyStrategy', '%s': %d}`, cfg.Datacenter, rf)
+	}
+
+	err = cfg.Session.Query(fmt.Sprintf(`CREATE KEYSPACE IF NOT EXISTS %s WITH replication = %s`, keyspace, replication)).
+		WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to create keyspace %s: %w", keyspace, err)
+	}
+
+	for _, table := range tables {
+		if err := cfg.Session.Query(fmt.Sprintf(table, keyspace)).WithContext(ctx).Exec(); err != nil {
+			return fmt.Errorf("failed to create table in %s: %w", keyspace, err)
+		}
+	}
+
+	return nil
+}
+
+// keyspaceOrDefault validates keyspace, which is interpolated into
+// statements, or returns DefaultKeyspace when it is empty
+func keyspaceOrDefault(keyspace string) (string, error) {
+	if keyspace == "" {
+		return DefaultKeyspace, nil
+	}
+
+	if !keyspacePattern.MatchString(keyspace) {
+		return "", fmt.Errorf("invalid keyspace name %q", keyspace)
+	}
+
+	return keyspace, nil
+}
@@ -0,0 +1,245 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+const (
+	// maxBatchStatements is the BatchExecuteStatement and BatchWriteItem limit
+	maxBatchStatements = 25
+	// maxUnprocessedRetries bounds retries of throttled keys in batch reads and writes
+	maxUnprocessedRetries = 5
+)
+
+// itemAttributes is the order resultToItem attributes are bound in PartiQL statements
+var itemAttributes = []string{"pk", "ip", "port", "service", "timestamp", "response", "data_version"}
+
+// PutBatch stores up to 25 scans per round trip with the same newer-wins
+// semantics as Put. The stored items are read with a consistent BatchGetItem,
+// then each newer scan is written by a PartiQL statement conditional on the
+// state that was read: an INSERT for new keys, an UPDATE guarded by the read
+// timestamp otherwise. A statement that fails because another writer got in
+// between falls back to Put, which resolves it against the latest state.
+func (d *dynamoDB) PutBatch(ctx context.Context, results []*scan_manager.ScanResult) ([]*scan_manager.PutResult, error) {
+	puts := make([]*scan_manager.PutResult, 0, len(results))
+
+	for start := 0; start < len(results); start += maxBatchStatements {
+		end := min(start+maxBatchStatements, len(results))
+
+		chunk, err := d.putChunk(ctx, results[start:end])
+		if err != nil {
+			return nil, err
+		}
+		puts = append(puts, chunk...)
+	}
+
+	return puts, nil
+}
+
+func (d *dynamoDB) putChunk(ctx context.Context, results []*scan_manager.ScanResult) ([]*scan_manager.PutResult, error) {
+	stored, err := d.batchGet(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+
+	puts := make([]*scan_manager.PutResult, len(results))
+	previous := make([]*scan_manager.ScanResult, len(results))
+
+	var statements []types.BatchStatementRequest
+	var indexes []int
+
+	for i, result := range results {
+		item, ok := stored[result.Key().String()]
+		if !ok {
+			statements = append(statements, d.insertStatement(result))
+			indexes = append(indexes, i)
+			continue
+		}
+
+		existing, err := itemToResult(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing.Timestamp >= result.Timestamp {
+			if puts[i], err = d.rejected(ctx, result, item); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		previous[i] = existing
+		statements = append(statements, d.updateStatement(result, existing.Timestamp))
+		indexes = append(indexes, i)
+	}
+
+	if len(statements) == 0 {
+		return puts, nil
+	}
+
+	out, err := d.client.BatchExecuteStatement(ctx, &dynamodb.BatchExecuteStatementInput{
+		Statements: statements,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to batch write to DynamoDB: %w", classify(err))
+	}
+
+	var accepted []*scan_manager.ScanResult
+	for j, resp := range out.Responses {
+		i := indexes[j]
+
+		// Lost a race with another writer or was throttled, resolve it on its own
+		if resp.Error != nil {
+			d.logger.DebugContext(ctx, "batch statement failed, retrying scan on its own",
+				slog.String("key", results[i].Key().String()),
+				slog.String("code", string(resp.Error.Code)),
+			)
+			if puts[i], err = d.Put(ctx, results[i]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		accepted = append(accepted, results[i])
+		if previous[i] == nil {
+			puts[i] = &scan_manager.PutResult{Outcome: scan_manager.Inserted}
+		} else {
+			puts[i] = &scan_manager.PutResult{Outcome: scan_manager.Updated, Previous: previous[i]}
+		}
+	}
+
+	if d.historyTable != "" && len(accepted) > 0 {
+		if err := d.batchPutHistory(ctx, accepted); err != nil {
+			return nil, err
+		}
+	}
+
+	return puts, nil
+}
+
+// batchGet reads the stored items for results, keyed by pk
+func (d *dynamoDB) batchGet(ctx context.Context, results []*scan_manager.ScanResult) (map[string]map[string]types.AttributeValue, error) {
+	keys := make([]map[string]types.AttributeValue, len(results))
+	for i, result := range results {
+		keys[i] = map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: result.Key().String()},
+		}
+	}
+
+	request := map[string]types.KeysAndAttributes{
+		d.table: {Keys: keys, ConsistentRead: aws.Bool(true)},
+	}
+	stored := make(map[string]map[string]types.AttributeValue, len(results))
+
+	for attempt := 0; ; attempt++ {
+		out, err := d.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: request})
+		if err != nil {
+			return nil, fmt.Errorf("failed to batch get items from DynamoDB: %w", classify(err))
+		}
+
+		for _, item := range out.Responses[d.table] {
+			pk, err := stringAttr(item, "pk")
+			if err != nil {
+				return nil, err
+			}
+			stored[pk] = item
+		}
+
+		request = out.UnprocessedKeys
+		if len(request) == 0 {
+			return stored, nil
+		}
+
+		if err := unprocessedBackoff(ctx, attempt); err != nil {
+			return nil, fmt.Errorf("failed to batch get items from DynamoDB: %w", err)
+		}
+	}
+}
+
+// batchPutHistory appends accepted observations to the history table
+func (d *dynamoDB) batchPutHistory(ctx context.Context, results []*scan_manager.ScanResult) error {
+	writes := make([]types.WriteRequest, len(results))
+	for i, result := range results {
+		item := resultToItem(result)
+		item["stale"] = &types.AttributeValueMemberBOOL{Value: false}
+		writes[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+	}
+
+	request := map[string][]types.WriteRequest{d.historyTable: writes}
+
+	for attempt := 0; ; attempt++ {
+		out, err := d.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: request})
+		if err != nil {
+			return fmt.Errorf("failed to batch put history items to DynamoDB: %w", classify(err))
+		}
+
+		request = out.UnprocessedItems
+		if len(request) == 0 {
+			return nil
+		}
+
+		if err := unprocessedBackoff(ctx, attempt); err != nil {
+			return fmt.Errorf("failed to batch put history items to DynamoDB: %w", err)
+		}
+	}
+}
+
+// unprocessedBackoff waits before retrying throttled batch items, giving up
+// after maxUnprocessedRetries attempts
+func unprocessedBackoff(ctx context.Context, attempt int) error {
+	if attempt >= maxUnprocessedRetries {
+		return fmt.Errorf("%w: items still unprocessed after %d attempts", scan_manager.ErrThrottled, attempt+1)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(attempt+1) * 50 * time.Millisecond):
+		return nil
+	}
+}
+
+// insertStatement writes result if its key doesn't exist yet
+func (d *dynamoDB) insertStatement(result *scan_manager.ScanResult) types.BatchStatementRequest {
+	item := resultToItem(result)
+
+	fields := make([]string, len(itemAttributes))
+	params := make([]types.AttributeValue, len(itemAttributes))
+	for i, name := range itemAttributes {
+		fields[i] = fmt.Sprintf("'%s': ?", name)
+		params[i] = item[name]
+	}
+
+	return types.BatchStatementRequest{
+		Statement:  aws.String(fmt.Sprintf(`INSERT INTO "%s" VALUE {%s}`, d.table, strings.Join(fields, ", "))),
+		Parameters: params,
+	}
+}
+
+// updateStatement overwrites the stored scan for result's key if its
+// timestamp is still seen
+func (d *dynamoDB) updateStatement(result *scan_manager.ScanResult, seen int64) types.BatchStatementRequest {
+	item := resultToItem(result)
+
+	var sets []string
+	var params []types.AttributeValue
+	for _, name := range itemAttributes[1:] {
+		sets = append(sets, fmt.Sprintf(`SET "%s" = ?`, name))
+		params = append(params, item[name])
+	}
+	params = append(params, item["pk"], &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", seen)})
+
+	return types.BatchStatementRequest{
+		Statement:  aws.String(fmt.Sprintf(`UPDATE "%s" %s WHERE "pk" = ? AND "timestamp" = ?`, d.table, strings.Join(sets, " "))),
+		Parameters: params,
+	}
+}
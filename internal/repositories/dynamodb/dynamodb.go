@@ -4,19 +4,54 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/censys/scan-takehome/internal/logging"
 	"github.com/censys/scan-takehome/internal/managers/scan_manager"
 )
 
+const (
+	// DefaultTable is the latest-state table used when DynamoDBConfig.Table is empty
+	DefaultTable = "scan-results"
+	// DefaultHistoryTable is the conventional name for the history table
+	DefaultHistoryTable = "scan-history"
+)
+
+// Global secondary indexes on the scan table, created by migration 3. They
+// project every attribute so listings never read the base table.
+const (
+	// ipIndex is keyed by ip (S, hash) and port (N, range)
+	ipIndex = "ip-port-index"
+	// serviceIndex is keyed by service (S, hash) and port (N, range)
+	serviceIndex = "service-port-index"
+	// portIndex is keyed by port (N, hash) and ip (S, range)
+	portIndex = "port-ip-index"
+)
+
 type DynamoDBConfig struct {
 	Client *dynamodb.Client
+	// Table holds the latest scan per key, keyed by pk (S, hash), with the
+	// listing indexes created by Migrator. Defaults to DefaultTable.
+	Table string
+	// HistoryTable enables scan history when set. The table is keyed by
+	// pk (S, hash) and timestamp (N, range).
+	HistoryTable string
+	// RecordStale also appends scans rejected as stale to the history table
+	RecordStale bool
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
 }
 
 type dynamoDB struct {
-	client *dynamodb.Client
+	client       *dynamodb.Client
+	table        string
+	historyTable string
+	recordStale  bool
+	logger       *slog.Logger
 }
 
 func NewDynamoDB(cfg *DynamoDBConfig) (*dynamoDB, error) {
@@ -28,30 +63,33 @@ func NewDynamoDB(cfg *DynamoDBConfig) (*dynamoDB, error) {
 		return nil, errors.New("DynamoDB client is nil")
 	}
 
+	if cfg.RecordStale && cfg.HistoryTable == "" {
+		return nil, errors.New("recording stale scans requires a history table")
+	}
+
+	table := cfg.Table
+	if table == "" {
+		table = DefaultTable
+	}
+
 	db := &dynamoDB{
-		client: cfg.Client,
+		client:       cfg.Client,
+		table:        table,
+		historyTable: cfg.HistoryTable,
+		recordStale:  cfg.RecordStale,
+		logger:       logging.OrDefault(cfg.Logger),
 	}
 
 	return db, nil
 }
 
-func (d *dynamoDB) Put(ctx context.Context, result *scan_manager.ScanResult) error {
-	pk := fmt.Sprintf("%s#%d#%s", result.IP, result.Port, result.Service)
-
-	item := map[string]types.AttributeValue{
-		"pk":           &types.AttributeValueMemberS{Value: pk},
-		"ip":           &types.AttributeValueMemberS{Value: result.IP},
-		"port":         &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", result.Port)},
-		"service":      &types.AttributeValueMemberS{Value: result.Service},
-		"timestamp":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", result.Timestamp)},
-		"response":     &types.AttributeValueMemberS{Value: result.Response},
-		"data_version": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", result.DataVersion)},
-	}
+func (d *dynamoDB) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	item := resultToItem(result)
 
 	// Conditional write: only accept if item doesn't exist OR new timestamp > existing timestamp
 	// This handles out-of-order messages and ensures we keep the latest scan
 	input := &dynamodb.PutItemInput{
-		TableName: aws.String("scan-results"),
+		TableName: aws.String(d.table),
 		Item:      item,
 		ConditionExpression: aws.String(
 			"attribute_not_exists(pk) OR #ts < :new_ts",
@@ -62,18 +100,347 @@ func (d *dynamoDB) Put(ctx context.Context, result *scan_manager.ScanResult) err
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":new_ts": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", result.Timestamp)},
 		},
+		// Return the stored item either way so the outcome can be classified
+		ReturnValues:                        types.ReturnValueAllOld,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	}
 
-	_, err := d.client.PutItem(ctx, input)
+	out, err := d.client.PutItem(ctx, input)
 	if err != nil {
 		// Check if it's a conditional check failure (item exists with newer timestamp)
 		var ccf *types.ConditionalCheckFailedException
 		if errors.As(err, &ccf) {
 			// This is expected for out-of-order messages with older timestamps
+			return d.rejected(ctx, result, ccf.Item)
+		}
+		return nil, fmt.Errorf("failed to put item to DynamoDB: %w", classify(err))
+	}
+
+	if d.historyTable != "" {
+		if err := d.putHistory(ctx, result, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(out.Attributes) == 0 {
+		return &scan_manager.PutResult{Outcome: scan_manager.Inserted}, nil
+	}
+
+	previous, err := itemToResult(out.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scan_manager.PutResult{Outcome: scan_manager.Updated, Previous: previous}, nil
+}
+
+// rejected classifies a write that failed the timestamp condition against
+// the stored item and records it in the history table when configured
+func (d *dynamoDB) rejected(ctx context.Context, result *scan_manager.ScanResult, item map[string]types.AttributeValue) (*scan_manager.PutResult, error) {
+	var existing *scan_manager.ScanResult
+	var err error
+
+	// Older DynamoDB Local releases don't return the item on condition
+	// failure, so fall back to reading it
+	if len(item) == 0 {
+		d.logger.DebugContext(ctx, "condition failure returned no item, reading it", slog.String("key", result.Key().String()))
+		existing, err = d.Get(ctx, result.Key())
+	} else {
+		existing, err = itemToResult(item)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := scan_manager.RejectedOutcome(existing, result)
+
+	switch {
+	case outcome == scan_manager.Duplicate && d.historyTable != "":
+		// A redelivery may be retrying a Put whose history write failed
+		// after the latest-state write succeeded, so rewrite the entry
+		d.logger.DebugContext(ctx, "rewriting history entry for duplicate scan", slog.String("key", result.Key().String()))
+		if err := d.putHistory(ctx, result, false); err != nil {
+			return nil, err
+		}
+	case outcome == scan_manager.StaleIgnored && d.recordStale:
+		if err := d.putHistory(ctx, result, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return &scan_manager.PutResult{Outcome: outcome, Previous: existing}, nil
+}
+
+// putHistory appends an observation to the history table. It runs after the
+// latest-state write, so a failure here fails the Put and the redelivered
+// message comes back as a Duplicate, which rewrites the entry.
+func (d *dynamoDB) putHistory(ctx context.Context, result *scan_manager.ScanResult, stale bool) error {
+	item := resultToItem(result)
+	item["stale"] = &types.AttributeValueMemberBOOL{Value: stale}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(d.historyTable),
+		Item:      item,
+	}
+
+	// A stale observation with the same timestamp as an accepted one must
+	// not overwrite the accepted entry
+	if stale {
+		input.ConditionExpression = aws.String("attribute_not_exists(pk)")
+	}
+
+	_, err := d.client.PutItem(ctx, input)
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
 			return nil
 		}
-		return fmt.Errorf("failed to put item to DynamoDB: %w", err)
+		return fmt.Errorf("failed to put history item to DynamoDB: %w", classify(err))
 	}
 
 	return nil
 }
+
+func (d *dynamoDB) History(ctx context.Context, key scan_manager.ScanKey, from, to int64) ([]*scan_manager.HistoryEntry, error) {
+	if d.historyTable == "" {
+		return nil, scan_manager.ErrHistoryDisabled
+	}
+
+	var entries []*scan_manager.HistoryEntry
+	var startKey map[string]types.AttributeValue
+
+	for {
+		out, err := d.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(d.historyTable),
+			KeyConditionExpression: aws.String("pk = :pk AND #ts BETWEEN :from AND :to"),
+			ExpressionAttributeNames: map[string]string{
+				"#ts": "timestamp",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk":   &types.AttributeValueMemberS{Value: key.String()},
+				":from": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", from)},
+				":to":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", to)},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query history from DynamoDB: %w", classify(err))
+		}
+
+		for _, item := range out.Items {
+			result, err := itemToResult(item)
+			if err != nil {
+				return nil, err
+			}
+
+			entry := &scan_manager.HistoryEntry{ScanResult: *result}
+			if stale, ok := item["stale"].(*types.AttributeValueMemberBOOL); ok {
+				entry.Stale = stale.Value
+			}
+			entries = append(entries, entry)
+		}
+
+		startKey = out.LastEvaluatedKey
+		if len(startKey) == 0 {
+			return entries, nil
+		}
+	}
+}
+
+func (d *dynamoDB) Get(ctx context.Context, key scan_manager.ScanKey) (*scan_manager.ScanResult, error) {
+	// Consistent, so a Get sees every Put that returned before it
+	out, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.table),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: key.String()},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item from DynamoDB: %w", classify(err))
+	}
+
+	if out.Item == nil {
+		return nil, scan_manager.ErrNotFound
+	}
+
+	return itemToResult(out.Item)
+}
+
+func (d *dynamoDB) ListByIP(ctx context.Context, ip string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return d.queryByPort(ctx, ipIndex, "ip", &types.AttributeValueMemberS{Value: ip}, opts)
+}
+
+func (d *dynamoDB) ListByService(ctx context.Context, service string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return d.queryByPort(ctx, serviceIndex, "service", &types.AttributeValueMemberS{Value: service}, opts)
+}
+
+func (d *dynamoDB) ListByPort(ctx context.Context, port uint32, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return d.query(ctx, portIndex, opts, "#port = :port",
+		map[string]string{"#port": "port"},
+		map[string]types.AttributeValue{":port": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", port)}},
+	)
+}
+
+// queryByPort queries an index hashed on name and ranged on port, narrowing
+// the key condition to opts.Port when set
+func (d *dynamoDB) queryByPort(ctx context.Context, index, name string, value types.AttributeValue, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	condition := "#hash = :hash"
+	names := map[string]string{"#hash": name}
+	values := map[string]types.AttributeValue{":hash": value}
+
+	if opts.Port != 0 {
+		condition += " AND #port = :port"
+		names["#port"] = "port"
+		values[":port"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", opts.Port)}
+	}
+
+	return d.query(ctx, index, opts, condition, names, values)
+}
+
+// query reads a page from a secondary index. Index reads are eventually
+// consistent, so a scan stored moments ago may not be listed yet. A single
+// Query stops at 1MB, so it keeps reading until the page is full or the
+// index is exhausted.
+func (d *dynamoDB) query(
+	ctx context.Context,
+	index string,
+	opts scan_manager.ListOptions,
+	condition string,
+	names map[string]string,
+	values map[string]types.AttributeValue,
+) (*scan_manager.ListPage, error) {
+	startKey, err := decodePageToken(opts.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := opts.PageSize()
+	page := &scan_manager.ListPage{}
+
+	for {
+		out, err := d.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(d.table),
+			IndexName:                 aws.String(index),
+			KeyConditionExpression:    aws.String(condition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ExclusiveStartKey:         startKey,
+			Limit:                     aws.Int32(int32(limit - len(page.Results))),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", index, classify(err))
+		}
+
+		for _, item := range out.Items {
+			result, err := itemToResult(item)
+			if err != nil {
+				return nil, err
+			}
+			page.Results = append(page.Results, result)
+		}
+
+		startKey = out.LastEvaluatedKey
+		if len(startKey) == 0 {
+			return page, nil
+		}
+
+		if len(page.Results) >= limit {
+			page.NextPageToken, err = encodePageToken(startKey)
+			if err != nil {
+				return nil, err
+			}
+			return page, nil
+		}
+	}
+}
+
+// Ping checks the scan table, and the history table when enabled, are reachable
+func (d *dynamoDB) Ping(ctx context.Context) error {
+	tables := []string{d.table}
+	if d.historyTable != "" {
+		tables = append(tables, d.historyTable)
+	}
+
+	for _, table := range tables {
+		_, err := d.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(table),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe table %s: %w", table, classify(err))
+		}
+	}
+
+	return nil
+}
+
+// resultToItem builds the attributes shared by the latest-state and history tables
+func resultToItem(result *scan_manager.ScanResult) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk":           &types.AttributeValueMemberS{Value: result.Key().String()},
+		"ip":           &types.AttributeValueMemberS{Value: result.IP},
+		"port":         &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", result.Port)},
+		"service":      &types.AttributeValueMemberS{Value: result.Service},
+		"timestamp":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", result.Timestamp)},
+		"response":     &types.AttributeValueMemberS{Value: result.Response},
+		"data_version": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", result.DataVersion)},
+	}
+}
+
+func itemToResult(item map[string]types.AttributeValue) (*scan_manager.ScanResult, error) {
+	result := &scan_manager.ScanResult{}
+
+	var err error
+	if result.IP, err = stringAttr(item, "ip"); err != nil {
+		return nil, err
+	}
+
+	if result.Service, err = stringAttr(item, "service"); err != nil {
+		return nil, err
+	}
+
+	if result.Response, err = stringAttr(item, "response"); err != nil {
+		return nil, err
+	}
+
+	port, err := numberAttr(item, "port")
+	if err != nil {
+		return nil, err
+	}
+	result.Port = uint32(port)
+
+	if result.Timestamp, err = numberAttr(item, "timestamp"); err != nil {
+		return nil, err
+	}
+
+	dataVersion, err := numberAttr(item, "data_version")
+	if err != nil {
+		return nil, err
+	}
+	result.DataVersion = int(dataVersion)
+
+	return result, nil
+}
+
+func stringAttr(item map[string]types.AttributeValue, name string) (string, error) {
+	attr, ok := item[name].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", fmt.Errorf("item attribute %s is missing or not a string", name)
+	}
+
+	return attr.Value, nil
+}
+
+func numberAttr(item map[string]types.AttributeValue, name string) (int64, error) {
+	attr, ok := item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("item attribute %s is missing or not a number", name)
+	}
+
+	n, err := strconv.ParseInt(attr.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("item attribute %s is not an integer: %w", name, err)
+	}
+
+	return n, nil
+}
@@ -1,9 +1,16 @@
 package dynamodb
 
 import (
+	"context"
+	"errors"
+	"net"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
 )
 
 func TestNewDynamoDB(t *testing.T) {
@@ -21,6 +28,38 @@ func TestNewDynamoDB(t *testing.T) {
 		}
 	})
 
+	t.Run("should return error if recording stale scans without a history table", func(t *testing.T) {
+		_, err := NewDynamoDB(&DynamoDBConfig{
+			Client:      &dynamodb.Client{},
+			RecordStale: true,
+		})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should default the table name", func(t *testing.T) {
+		db, err := NewDynamoDB(&DynamoDBConfig{Client: &dynamodb.Client{}})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if db.table != DefaultTable {
+			t.Errorf("expected table %s, got %s", DefaultTable, db.table)
+		}
+	})
+
+	t.Run("should use the configured table name", func(t *testing.T) {
+		db, err := NewDynamoDB(&DynamoDBConfig{Client: &dynamodb.Client{}, Table: "prod-scans"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if db.table != "prod-scans" {
+			t.Errorf("expected table prod-scans, got %s", db.table)
+		}
+	})
+
 	t.Run("should return a new DynamoDB", func(t *testing.T) {
 		_, err := NewDynamoDB(&DynamoDBConfig{
 			Client: &dynamodb.Client{},
@@ -30,3 +69,155 @@ func TestNewDynamoDB(t *testing.T) {
 		}
 	})
 }
+
+func TestPageToken(t *testing.T) {
+	t.Run("should round trip a key", func(t *testing.T) {
+		key := map[string]types.AttributeValue{
+			"pk":   &types.AttributeValueMemberS{Value: "10.0.0.1#22#ssh"},
+			"port": &types.AttributeValueMemberN{Value: "22"},
+		}
+
+		token, err := encodePageToken(key)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		decoded, err := decodePageToken(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pk := decoded["pk"].(*types.AttributeValueMemberS).Value; pk != "10.0.0.1#22#ssh" {
+			t.Errorf("expected pk 10.0.0.1#22#ssh, got %s", pk)
+		}
+
+		if port := decoded["port"].(*types.AttributeValueMemberN).Value; port != "22" {
+			t.Errorf("expected port 22, got %s", port)
+		}
+	})
+
+	t.Run("should decode an empty token to a nil key", func(t *testing.T) {
+		key, err := decodePageToken("")
+		if err != nil || key != nil {
+			t.Errorf("expected nil key and no error, got %v, %v", key, err)
+		}
+	})
+
+	t.Run("should reject malformed tokens", func(t *testing.T) {
+		if _, err := decodePageToken("not a token"); !errors.Is(err, scan_manager.ErrInvalidPageToken) {
+			t.Errorf("expected ErrInvalidPageToken, got %v", err)
+		}
+	})
+}
+
+func TestItemToResult(t *testing.T) {
+	t.Run("should convert a stored item", func(t *testing.T) {
+		result, err := itemToResult(map[string]types.AttributeValue{
+			"pk":           &types.AttributeValueMemberS{Value: "10.0.0.1#22#ssh"},
+			"ip":           &types.AttributeValueMemberS{Value: "10.0.0.1"},
+			"port":         &types.AttributeValueMemberN{Value: "22"},
+			"service":      &types.AttributeValueMemberS{Value: "ssh"},
+			"timestamp":    &types.AttributeValueMemberN{Value: "1234567890"},
+			"response":     &types.AttributeValueMemberS{Value: "SSH-2.0"},
+			"data_version": &types.AttributeValueMemberN{Value: "2"},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expected := scan_manager.ScanResult{
+			IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 1234567890, Response: "SSH-2.0", DataVersion: 2,
+		}
+		if *result != expected {
+			t.Errorf("expected %+v, got %+v", expected, *result)
+		}
+	})
+
+	t.Run("should return error for missing attributes", func(t *testing.T) {
+		_, err := itemToResult(map[string]types.AttributeValue{
+			"ip": &types.AttributeValueMemberS{Value: "10.0.0.1"},
+		})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	db, err := NewDynamoDB(&DynamoDBConfig{Client: &dynamodb.Client{}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	_, err = db.History(context.Background(), scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "ssh"}, 0, 100)
+	if !errors.Is(err, scan_manager.ErrHistoryDisabled) {
+		t.Errorf("expected ErrHistoryDisabled, got %v", err)
+	}
+}
+
+func TestBatchStatements(t *testing.T) {
+	db, err := NewDynamoDB(&DynamoDBConfig{Client: &dynamodb.Client{}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	result := &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 200, Response: "SSH-2.0", DataVersion: 2}
+
+	t.Run("should bind a parameter for every placeholder", func(t *testing.T) {
+		for _, statement := range []types.BatchStatementRequest{
+			db.insertStatement(result),
+			db.updateStatement(result, 100),
+		} {
+			placeholders := strings.Count(*statement.Statement, "?")
+			if placeholders != len(statement.Parameters) {
+				t.Errorf("expected %d parameters for %q, got %d", placeholders, *statement.Statement, len(statement.Parameters))
+			}
+		}
+	})
+
+	t.Run("should guard updates on the timestamp that was read", func(t *testing.T) {
+		statement := db.updateStatement(result, 100)
+
+		if !strings.HasSuffix(*statement.Statement, `WHERE "pk" = ? AND "timestamp" = ?`) {
+			t.Errorf("unexpected statement %q", *statement.Statement)
+		}
+
+		seen, ok := statement.Parameters[len(statement.Parameters)-1].(*types.AttributeValueMemberN)
+		if !ok || seen.Value != "100" {
+			t.Errorf("expected the read timestamp as the last parameter, got %v", statement.Parameters[len(statement.Parameters)-1])
+		}
+	})
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"throttling", &smithy.GenericAPIError{Code: "ProvisionedThroughputExceededException"}, scan_manager.ErrThrottled},
+		{"validation", &smithy.GenericAPIError{Code: "ValidationException", Fault: smithy.FaultClient}, scan_manager.ErrInvalidScan},
+		{"server fault", &smithy.GenericAPIError{Code: "InternalServerError", Fault: smithy.FaultServer}, scan_manager.ErrUnavailable},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, scan_manager.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run("should classify "+tt.name, func(t *testing.T) {
+			err := classify(tt.err)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected the original error to be wrapped, got %v", err)
+			}
+		})
+	}
+
+	t.Run("should leave unknown errors unclassified", func(t *testing.T) {
+		err := errors.New("unknown")
+		if classify(err) != err {
+			t.Errorf("expected the error unchanged")
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/aws/smithy-go"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// classify maps DynamoDB API error codes and server faults to scan_manager error classes
+func classify(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ProvisionedThroughputExceededException", "ThrottlingException", "RequestLimitExceeded":
+			return fmt.Errorf("%w: %w", scan_manager.ErrThrottled, err)
+		case "ValidationException", "ItemCollectionSizeLimitExceededException":
+			return fmt.Errorf("%w: %w", scan_manager.ErrInvalidScan, err)
+		}
+
+		if apiErr.ErrorFault() == smithy.FaultServer {
+			return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+		}
+
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+	}
+
+	return err
+}
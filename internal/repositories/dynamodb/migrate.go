@@ -0,0 +1,385 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// schemaKey is the pk of the metadata item in the scan table that tracks the
+// applied schema version. It can't collide with ip#port#service keys, and it
+// has no ip or service attribute so listings never return it.
+const schemaKey = "_schema"
+
+const (
+	tableActiveTimeout = 5 * time.Minute
+	// Index backfill scales with table size, so allow far longer than for tables
+	indexActiveTimeout = 2 * time.Hour
+	indexPollInterval  = 5 * time.Second
+)
+
+// secondaryIndex describes a global secondary index projecting all attributes
+type secondaryIndex struct {
+	name     string
+	hashKey  string
+	rangeKey string
+}
+
+// scanIndexes back the ListBy* queries on the scan table
+var scanIndexes = []secondaryIndex{
+	{name: ipIndex, hashKey: "ip", rangeKey: "port"},
+	{name: serviceIndex, hashKey: "service", rangeKey: "port"},
+	{name: portIndex, hashKey: "port", rangeKey: "ip"},
+}
+
+// attributeTypes are the types of every attribute used in an index key
+var attributeTypes = map[string]types.ScalarAttributeType{
+	"ip":      types.ScalarAttributeTypeS,
+	"service": types.ScalarAttributeTypeS,
+	"port":    types.ScalarAttributeTypeN,
+}
+
+// Migration is a versioned schema change. Migrations are applied in order
+// and each one must be idempotent, since a failure after apply but before the
+// version is recorded will re-run it.
+type Migration struct {
+	Version     int
+	Description string
+	apply       func(ctx context.Context, m *migrator) error
+}
+
+// migrations is the ordered schema history. Only ever append to it.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create scan table keyed by ip#port#service",
+		apply: func(ctx context.Context, m *migrator) error {
+			return m.createTable(ctx, &dynamodb.CreateTableInput{
+				TableName: aws.String(m.table),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("pk"), KeyType: types.KeyTypeHash},
+				},
+				AttributeDefinitions: []types.AttributeDefinition{
+					{AttributeName: aws.String("pk"), AttributeType: types.ScalarAttributeTypeS},
+				},
+			})
+		},
+	},
+	{
+		Version:     2,
+		Description: "create history table keyed by ip#port#service and timestamp",
+		apply: func(ctx context.Context, m *migrator) error {
+			return m.createTable(ctx, &dynamodb.CreateTableInput{
+				TableName: aws.String(m.historyTable),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("pk"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("timestamp"), KeyType: types.KeyTypeRange},
+				},
+				AttributeDefinitions: []types.AttributeDefinition{
+					{AttributeName: aws.String("pk"), AttributeType: types.ScalarAttributeTypeS},
+					{AttributeName: aws.String("timestamp"), AttributeType: types.ScalarAttributeTypeN},
+				},
+			})
+		},
+	},
+	{
+		Version:     3,
+		Description: "add ip, service and port indexes to the scan table",
+		apply: func(ctx context.Context, m *migrator) error {
+			for _, index := range scanIndexes {
+				if err := m.createIndex(ctx, m.table, index); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+type MigratorConfig struct {
+	Client *dynamodb.Client
+	// Table defaults to DefaultTable
+	Table string
+	// HistoryTable defaults to DefaultHistoryTable
+	HistoryTable string
+	// BillingMode defaults to PAY_PER_REQUEST. PROVISIONED requires capacities.
+	BillingMode   types.BillingMode
+	ReadCapacity  int64
+	WriteCapacity int64
+}
+
+type migrator struct {
+	client        *dynamodb.Client
+	table         string
+	historyTable  string
+	billingMode   types.BillingMode
+	readCapacity  int64
+	writeCapacity int64
+}
+
+func NewMigrator(cfg *MigratorConfig) (*migrator, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Client == nil {
+		return nil, errors.New("DynamoDB client is nil")
+	}
+
+	m := &migrator{
+		client:        cfg.Client,
+		table:         cfg.Table,
+		historyTable:  cfg.HistoryTable,
+		billingMode:   cfg.BillingMode,
+		readCapacity:  cfg.ReadCapacity,
+		writeCapacity: cfg.WriteCapacity,
+	}
+
+	if m.table == "" {
+		m.table = DefaultTable
+	}
+
+	if m.historyTable == "" {
+		m.historyTable = DefaultHistoryTable
+	}
+
+	switch m.billingMode {
+	case "":
+		m.billingMode = types.BillingModePayPerRequest
+	case types.BillingModePayPerRequest:
+	case types.BillingModeProvisioned:
+		if m.readCapacity <= 0 || m.writeCapacity <= 0 {
+			return nil, errors.New("provisioned billing requires read and write capacity")
+		}
+	default:
+		return nil, fmt.Errorf("unknown billing mode: %s", m.billingMode)
+	}
+
+	return m, nil
+}
+
+// Version returns the applied schema version, zero if the scan table
+// doesn't exist yet
+func (m *migrator) Version(ctx context.Context) (int, error) {
+	out, err := m.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(m.table),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: schemaKey},
+		},
+	})
+	if err != nil {
+		var rnf *types.ResourceNotFoundException
+		if errors.As(err, &rnf) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if out.Item == nil {
+		return 0, nil
+	}
+
+	version, err := numberAttr(out.Item, "version")
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version), nil
+}
+
+// Pending returns the migrations newer than the applied schema version
+func (m *migrator) Pending(ctx context.Context) ([]Migration, error) {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Migrate applies pending migrations in order and returns the ones applied
+func (m *migrator) Migrate(ctx context.Context) ([]Migration, error) {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+
+		if err := migration.apply(ctx, m); err != nil {
+			return applied, fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+
+		if err := m.setVersion(ctx, current, migration); err != nil {
+			return applied, err
+		}
+
+		current = migration.Version
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// setVersion records migration as applied. The write is conditional on the
+// previous version so concurrent migrators can't both advance the schema.
+func (m *migrator) setVersion(ctx context.Context, previous int, migration Migration) error {
+	_, err := m.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(m.table),
+		Item: map[string]types.AttributeValue{
+			"pk":          &types.AttributeValueMemberS{Value: schemaKey},
+			"version":     &types.AttributeValueMemberN{Value: strconv.Itoa(migration.Version)},
+			"description": &types.AttributeValueMemberS{Value: migration.Description},
+			"applied_at":  &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(pk) OR version = :previous"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":previous": &types.AttributeValueMemberN{Value: strconv.Itoa(previous)},
+		},
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return fmt.Errorf("schema version changed during migration %d, another migrator may be running", migration.Version)
+		}
+		return fmt.Errorf("failed to record schema version %d: %w", migration.Version, err)
+	}
+
+	return nil
+}
+
+// createTable creates a table with the configured billing mode and waits for
+// it to become active. An existing table is left as is.
+func (m *migrator) createTable(ctx context.Context, input *dynamodb.CreateTableInput) error {
+	input.BillingMode = m.billingMode
+	if m.billingMode == types.BillingModeProvisioned {
+		input.ProvisionedThroughput = m.throughput()
+	}
+
+	_, err := m.client.CreateTable(ctx, input)
+	if err != nil {
+		var riu *types.ResourceInUseException
+		if !errors.As(err, &riu) {
+			return fmt.Errorf("failed to create table %s: %w", aws.ToString(input.TableName), err)
+		}
+	}
+
+	return m.waitActive(ctx, aws.ToString(input.TableName))
+}
+
+func (m *migrator) waitActive(ctx context.Context, table string) error {
+	waiter := dynamodb.NewTableExistsWaiter(m.client)
+	err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)}, tableActiveTimeout)
+	if err != nil {
+		return fmt.Errorf("failed waiting for table %s to become active: %w", table, err)
+	}
+
+	return nil
+}
+
+// createIndex adds a global secondary index to table and waits for its
+// backfill to finish. An existing index is left as is. DynamoDB only allows
+// one index to be created per UpdateTable call.
+func (m *migrator) createIndex(ctx context.Context, table string, index secondaryIndex) error {
+	desc, err := m.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+
+	for _, gsi := range desc.Table.GlobalSecondaryIndexes {
+		if aws.ToString(gsi.IndexName) == index.name {
+			return m.waitIndexActive(ctx, table, index.name)
+		}
+	}
+
+	create := &types.CreateGlobalSecondaryIndexAction{
+		IndexName: aws.String(index.name),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(index.hashKey), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(index.rangeKey), KeyType: types.KeyTypeRange},
+		},
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+	}
+
+	// Provisioned tables need capacity for the index too. Tables created
+	// outside the migrator may be provisioned even when it isn't configured to be.
+	if capacity := desc.Table.ProvisionedThroughput; capacity != nil && aws.ToInt64(capacity.ReadCapacityUnits) > 0 {
+		create.ProvisionedThroughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  capacity.ReadCapacityUnits,
+			WriteCapacityUnits: capacity.WriteCapacityUnits,
+		}
+		if m.billingMode == types.BillingModeProvisioned {
+			create.ProvisionedThroughput = m.throughput()
+		}
+	}
+
+	_, err = m.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName: aws.String(table),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(index.hashKey), AttributeType: attributeTypes[index.hashKey]},
+			{AttributeName: aws.String(index.rangeKey), AttributeType: attributeTypes[index.rangeKey]},
+		},
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+			{Create: create},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create index %s on %s: %w", index.name, table, err)
+	}
+
+	return m.waitIndexActive(ctx, table, index.name)
+}
+
+func (m *migrator) waitIndexActive(ctx context.Context, table, index string) error {
+	ctx, cancel := context.WithTimeout(ctx, indexActiveTimeout)
+	defer cancel()
+
+	for {
+		desc, err := m.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		if err != nil {
+			return fmt.Errorf("failed waiting for index %s to become active: %w", index, err)
+		}
+
+		for _, gsi := range desc.Table.GlobalSecondaryIndexes {
+			if aws.ToString(gsi.IndexName) == index && gsi.IndexStatus == types.IndexStatusActive {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed waiting for index %s to become active: %w", index, ctx.Err())
+		case <-time.After(indexPollInterval):
+		}
+	}
+}
+
+func (m *migrator) throughput() *types.ProvisionedThroughput {
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(m.readCapacity),
+		WriteCapacityUnits: aws.Int64(m.writeCapacity),
+	}
+}
+
+// Migrations returns every known migration in order
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
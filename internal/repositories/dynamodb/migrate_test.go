@@ -0,0 +1,87 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestNewMigrator(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewMigrator(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if client is nil", func(t *testing.T) {
+		_, err := NewMigrator(&MigratorConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error for provisioned billing without capacity", func(t *testing.T) {
+		_, err := NewMigrator(&MigratorConfig{
+			Client:      &dynamodb.Client{},
+			BillingMode: types.BillingModeProvisioned,
+		})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error for an unknown billing mode", func(t *testing.T) {
+		_, err := NewMigrator(&MigratorConfig{
+			Client:      &dynamodb.Client{},
+			BillingMode: "FREE",
+		})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should apply defaults", func(t *testing.T) {
+		m, err := NewMigrator(&MigratorConfig{Client: &dynamodb.Client{}})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if m.table != DefaultTable || m.historyTable != DefaultHistoryTable {
+			t.Errorf("expected default tables, got %s and %s", m.table, m.historyTable)
+		}
+
+		if m.billingMode != types.BillingModePayPerRequest {
+			t.Errorf("expected PAY_PER_REQUEST, got %s", m.billingMode)
+		}
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	for i, migration := range Migrations() {
+		if migration.Version != i+1 {
+			t.Errorf("expected migration %d to have version %d, got %d", i, i+1, migration.Version)
+		}
+
+		if migration.Description == "" {
+			t.Errorf("migration %d has no description", migration.Version)
+		}
+
+		if migration.apply == nil {
+			t.Errorf("migration %d has no apply func", migration.Version)
+		}
+	}
+}
+
+func TestScanIndexes(t *testing.T) {
+	t.Run("should declare a type for every index key", func(t *testing.T) {
+		for _, index := range scanIndexes {
+			for _, key := range []string{index.hashKey, index.rangeKey} {
+				if _, ok := attributeTypes[key]; !ok {
+					t.Errorf("index %s: no attribute type for %s", index.name, key)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package dynamodb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// tokenAttr is the JSON form of a key attribute in a page token. Keys only
+// ever contain string and number attributes.
+type tokenAttr struct {
+	S *string `json:"s,omitempty"`
+	N *string `json:"n,omitempty"`
+}
+
+// encodePageToken turns a LastEvaluatedKey into an opaque page token
+func encodePageToken(key map[string]types.AttributeValue) (string, error) {
+	attrs := make(map[string]tokenAttr, len(key))
+	for name, value := range key {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			attrs[name] = tokenAttr{S: &v.Value}
+		case *types.AttributeValueMemberN:
+			attrs[name] = tokenAttr{N: &v.Value}
+		default:
+			return "", fmt.Errorf("unsupported key attribute type %T for %s", value, name)
+		}
+	}
+
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode page token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodePageToken turns a page token back into an ExclusiveStartKey. An
+// empty token decodes to a nil key, which starts from the beginning.
+func decodePageToken(token string) (map[string]types.AttributeValue, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", scan_manager.ErrInvalidPageToken, err)
+	}
+
+	var attrs map[string]tokenAttr
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return nil, fmt.Errorf("%w: %v", scan_manager.ErrInvalidPageToken, err)
+	}
+
+	key := make(map[string]types.AttributeValue, len(attrs))
+	for name, attr := range attrs {
+		switch {
+		case attr.S != nil:
+			key[name] = &types.AttributeValueMemberS{Value: *attr.S}
+		case attr.N != nil:
+			key[name] = &types.AttributeValueMemberN{Value: *attr.N}
+		default:
+			return nil, fmt.Errorf("%w: attribute %s has no value", scan_manager.ErrInvalidPageToken, name)
+		}
+	}
+
+	return key, nil
+}
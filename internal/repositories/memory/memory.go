@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+type MemoryConfig struct {
+	// History keeps every accepted observation, not just the latest
+	History bool
+	// RecordStale also keeps observations that were rejected as stale. Requires History.
+	RecordStale bool
+}
+
+// memory is an in-process Repository that keeps the latest scan per
+// ip#port#service. It applies the same "only newer timestamp wins" rule as
+// the DynamoDB repository and is safe for concurrent use.
+type memory struct {
+	mu          sync.RWMutex
+	results     map[string]*scan_manager.ScanResult
+	history     map[string]map[int64]scan_manager.HistoryEntry
+	keepHistory bool
+	recordStale bool
+}
+
+func NewMemory(cfg *MemoryConfig) (*memory, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.RecordStale && !cfg.History {
+		return nil, errors.New("recording stale scans requires history")
+	}
+
+	m := &memory{
+		results:     make(map[string]*scan_manager.ScanResult),
+		history:     make(map[string]map[int64]scan_manager.HistoryEntry),
+		keepHistory: cfg.History,
+		recordStale: cfg.RecordStale,
+	}
+
+	return m, nil
+}
+
+func (m *memory) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	pk := result.Key().String()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Mirror the DynamoDB condition: only accept if the key doesn't exist OR
+	// the new timestamp is strictly greater than the stored one
+	existing, ok := m.results[pk]
+	if ok && existing.Timestamp >= result.Timestamp {
+		outcome := scan_manager.RejectedOutcome(existing, result)
+		switch {
+		case outcome == scan_manager.Duplicate && m.keepHistory:
+			// Rewrite the entry like DynamoDB does for a redelivery
+			m.putHistory(pk, result, false)
+		case outcome == scan_manager.StaleIgnored && m.recordStale:
+			m.putHistory(pk, result, true)
+		}
+
+		previous := *existing
+		return &scan_manager.PutResult{Outcome: outcome, Previous: &previous}, nil
+	}
+
+	// Store a copy so callers can't mutate the stored state
+	stored := *result
+	m.results[pk] = &stored
+
+	if m.keepHistory {
+		m.putHistory(pk, result, false)
+	}
+
+	if !ok {
+		return &scan_manager.PutResult{Outcome: scan_manager.Inserted}, nil
+	}
+
+	// The replaced scan is never mutated after being swapped out, so it's
+	// safe to hand back directly
+	return &scan_manager.PutResult{Outcome: scan_manager.Updated, Previous: existing}, nil
+}
+
+// putHistory records an observation, keyed by timestamp like the other
+// backends. A stale observation with the same timestamp as an accepted one
+// must not overwrite the accepted entry. The caller must hold the write lock.
+func (m *memory) putHistory(pk string, result *scan_manager.ScanResult, stale bool) {
+	entries, ok := m.history[pk]
+	if !ok {
+		entries = make(map[int64]scan_manager.HistoryEntry)
+		m.history[pk] = entries
+	}
+
+	if _, ok := entries[result.Timestamp]; ok && stale {
+		return
+	}
+
+	entries[result.Timestamp] = scan_manager.HistoryEntry{ScanResult: *result, Stale: stale}
+}
+
+func (m *memory) History(ctx context.Context, key scan_manager.ScanKey, from, to int64) ([]*scan_manager.HistoryEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if !m.keepHistory {
+		return nil, scan_manager.ErrHistoryDisabled
+	}
+
+	m.mu.RLock()
+	var entries []*scan_manager.HistoryEntry
+	for _, entry := range m.history[key.String()] {
+		if entry.Timestamp >= from && entry.Timestamp <= to {
+			entries = append(entries, &entry)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp < entries[j].Timestamp
+	})
+
+	return entries, nil
+}
+
+// PutBatch applies each result in turn, the store has no round trips to save
+func (m *memory) PutBatch(ctx context.Context, results []*scan_manager.ScanResult) ([]*scan_manager.PutResult, error) {
+	puts := make([]*scan_manager.PutResult, len(results))
+	for i, result := range results {
+		put, err := m.Put(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		puts[i] = put
+	}
+
+	return puts, nil
+}
+
+func (m *memory) Get(ctx context.Context, key scan_manager.ScanKey) (*scan_manager.ScanResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.results[key.String()]
+	if !ok {
+		return nil, scan_manager.ErrNotFound
+	}
+
+	result := *stored
+	return &result, nil
+}
+
+func (m *memory) ListByIP(ctx context.Context, ip string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return m.list(ctx, opts, func(r *scan_manager.ScanResult) bool {
+		return r.IP == ip
+	})
+}
+
+func (m *memory) ListByService(ctx context.Context, service string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return m.list(ctx, opts, func(r *scan_manager.ScanResult) bool {
+		return r.Service == service
+	})
+}
+
+func (m *memory) ListByPort(ctx context.Context, port uint32, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	opts.Port = 0
+	return m.list(ctx, opts, func(r *scan_manager.ScanResult) bool {
+		return r.Port == port
+	})
+}
+
+// list returns matching results ordered by key. The page token is the
+// encoded key of the last result on the previous page.
+func (m *memory) list(ctx context.Context, opts scan_manager.ListOptions, match func(*scan_manager.ScanResult) bool) (*scan_manager.ListPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var after string
+	if opts.PageToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(opts.PageToken)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", scan_manager.ErrInvalidPageToken, err)
+		}
+		after = string(decoded)
+	}
+
+	m.mu.RLock()
+	keys := make([]string, 0)
+	for pk, r := range m.results {
+		if pk > after && match(r) && (opts.Port == 0 || r.Port == opts.Port) {
+			keys = append(keys, pk)
+		}
+	}
+	sort.Strings(keys)
+
+	limit := opts.PageSize()
+	page := &scan_manager.ListPage{}
+	for i, pk := range keys {
+		if i == limit {
+			page.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(keys[i-1]))
+			break
+		}
+		result := *m.results[pk]
+		page.Results = append(page.Results, &result)
+	}
+	m.mu.RUnlock()
+
+	return page, nil
+}
+
+// Ping always succeeds, the store lives in process
+func (m *memory) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
@@ -0,0 +1,308 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/repotest"
+)
+
+func newTestMemory(t *testing.T, cfg *MemoryConfig) *memory {
+	m, err := NewMemory(cfg)
+	if err != nil {
+		t.Fatalf("failed to create memory repository: %v", err)
+	}
+	return m
+}
+
+func TestNewMemory(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewMemory(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if recording stale scans without history", func(t *testing.T) {
+		_, err := NewMemory(&MemoryConfig{RecordStale: true})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) scan_manager.Repository {
+		return newTestMemory(t, &MemoryConfig{History: true, RecordStale: true})
+	})
+}
+
+func TestPut(t *testing.T) {
+	t.Run("should store a new scan", func(t *testing.T) {
+		m := newTestMemory(t, &MemoryConfig{})
+
+		_, err := m.Put(context.Background(), &scan_manager.ScanResult{
+			IP: "192.168.1.1", Port: 80, Service: "http", Timestamp: 100, Response: "first",
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		stored := m.results["192.168.1.1#80#http"]
+		if stored == nil || stored.Response != "first" {
+			t.Errorf("expected 'first' to be stored, got %+v", stored)
+		}
+	})
+
+	t.Run("should replace with a newer timestamp", func(t *testing.T) {
+		m := newTestMemory(t, &MemoryConfig{})
+
+		put, _ := m.Put(context.Background(), &scan_manager.ScanResult{
+			IP: "192.168.1.1", Port: 80, Service: "http", Timestamp: 100, Response: "older",
+		})
+		if put.Outcome != scan_manager.Inserted || put.Previous != nil {
+			t.Errorf("expected inserted with no previous scan, got %+v", put)
+		}
+
+		put, _ = m.Put(context.Background(), &scan_manager.ScanResult{
+			IP: "192.168.1.1", Port: 80, Service: "http", Timestamp: 200, Response: "newer",
+		})
+		if put.Outcome != scan_manager.Updated || put.Previous == nil || put.Previous.Response != "older" {
+			t.Errorf("expected updated with the replaced scan, got %+v", put)
+		}
+
+		if got := m.results["192.168.1.1#80#http"].Response; got != "newer" {
+			t.Errorf("expected 'newer', got '%s'", got)
+		}
+	})
+
+	t.Run("should ignore older and equal timestamps", func(t *testing.T) {
+		m := newTestMemory(t, &MemoryConfig{})
+
+		_, _ = m.Put(context.Background(), &scan_manager.ScanResult{
+			IP: "192.168.1.1", Port: 80, Service: "http", Timestamp: 200, Response: "newer",
+		})
+
+		for _, ts := range []int64{100, 200} {
+			put, err := m.Put(context.Background(), &scan_manager.ScanResult{
+				IP: "192.168.1.1", Port: 80, Service: "http", Timestamp: ts, Response: "stale",
+			})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if put.Outcome != scan_manager.StaleIgnored || put.Previous.Response != "newer" {
+				t.Errorf("expected stale_ignored with the stored scan, got %+v", put)
+			}
+		}
+
+		if got := m.results["192.168.1.1#80#http"].Response; got != "newer" {
+			t.Errorf("expected 'newer', got '%s' - stale scan overwrote newer", got)
+		}
+	})
+
+	t.Run("should report exact redeliveries as duplicates", func(t *testing.T) {
+		m := newTestMemory(t, &MemoryConfig{})
+		scan := &scan_manager.ScanResult{IP: "192.168.1.1", Port: 80, Service: "http", Timestamp: 200, Response: "same"}
+
+		_, _ = m.Put(context.Background(), scan)
+		put, _ := m.Put(context.Background(), scan)
+
+		if put.Outcome != scan_manager.Duplicate {
+			t.Errorf("expected duplicate, got %s", put.Outcome)
+		}
+	})
+
+	t.Run("should keep the newest under concurrent writers", func(t *testing.T) {
+		m := newTestMemory(t, &MemoryConfig{})
+
+		var wg sync.WaitGroup
+		for i := 1; i <= 100; i++ {
+			wg.Add(1)
+			go func(ts int64) {
+				defer wg.Done()
+				_, _ = m.Put(context.Background(), &scan_manager.ScanResult{
+					IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: ts, Response: fmt.Sprintf("response %d", ts),
+				})
+			}(int64(i))
+		}
+		wg.Wait()
+
+		if got := m.results["10.0.0.1#22#ssh"].Timestamp; got != 100 {
+			t.Errorf("expected timestamp 100, got %d", got)
+		}
+	})
+}
+
+func TestGet(t *testing.T) {
+	m := newTestMemory(t, &MemoryConfig{})
+	key := scan_manager.ScanKey{IP: "192.168.1.1", Port: 80, Service: "http"}
+
+	t.Run("should return ErrNotFound for missing keys", func(t *testing.T) {
+		_, err := m.Get(context.Background(), key)
+		if !errors.Is(err, scan_manager.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should return the stored scan", func(t *testing.T) {
+		_, _ = m.Put(context.Background(), &scan_manager.ScanResult{
+			IP: key.IP, Port: key.Port, Service: key.Service, Timestamp: 100, Response: "stored",
+		})
+
+		result, err := m.Get(context.Background(), key)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if result.Response != "stored" {
+			t.Errorf("expected 'stored', got '%s'", result.Response)
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	m := newTestMemory(t, &MemoryConfig{})
+	for port := uint32(1); port <= 5; port++ {
+		_, _ = m.Put(context.Background(), &scan_manager.ScanResult{
+			IP: "10.0.0.1", Port: port, Service: "http", Timestamp: 100,
+		})
+	}
+	_, _ = m.Put(context.Background(), &scan_manager.ScanResult{
+		IP: "10.0.0.2", Port: 22, Service: "ssh", Timestamp: 100,
+	})
+
+	t.Run("should page through results by ip", func(t *testing.T) {
+		var seen []*scan_manager.ScanResult
+		opts := scan_manager.ListOptions{Limit: 2}
+		pages := 0
+
+		for {
+			page, err := m.ListByIP(context.Background(), "10.0.0.1", opts)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			pages++
+			seen = append(seen, page.Results...)
+
+			if page.NextPageToken == "" {
+				break
+			}
+			opts.PageToken = page.NextPageToken
+		}
+
+		if len(seen) != 5 {
+			t.Errorf("expected 5 results, got %d", len(seen))
+		}
+
+		if pages != 3 {
+			t.Errorf("expected 3 pages, got %d", pages)
+		}
+	})
+
+	t.Run("should list by service", func(t *testing.T) {
+		page, err := m.ListByService(context.Background(), "ssh", scan_manager.ListOptions{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(page.Results) != 1 || page.Results[0].IP != "10.0.0.2" {
+			t.Errorf("expected only 10.0.0.2, got %+v", page.Results)
+		}
+
+		if page.NextPageToken != "" {
+			t.Errorf("expected no next page token, got %s", page.NextPageToken)
+		}
+	})
+
+	t.Run("should list by port", func(t *testing.T) {
+		page, err := m.ListByPort(context.Background(), 22, scan_manager.ListOptions{Port: 80})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(page.Results) != 1 || page.Results[0].Service != "ssh" {
+			t.Errorf("expected only the ssh scan, got %+v", page.Results)
+		}
+	})
+
+	t.Run("should reject malformed page tokens", func(t *testing.T) {
+		_, err := m.ListByIP(context.Background(), "10.0.0.1", scan_manager.ListOptions{PageToken: "!!"})
+		if !errors.Is(err, scan_manager.ErrInvalidPageToken) {
+			t.Errorf("expected ErrInvalidPageToken, got %v", err)
+		}
+	})
+
+	t.Run("should filter by port", func(t *testing.T) {
+		page, err := m.ListByIP(context.Background(), "10.0.0.1", scan_manager.ListOptions{Port: 3})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(page.Results) != 1 || page.Results[0].Port != 3 {
+			t.Errorf("expected only port 3, got %+v", page.Results)
+		}
+	})
+}
+
+func TestHistory(t *testing.T) {
+	key := scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "ssh"}
+	put := func(m *memory, ts int64) {
+		_, _ = m.Put(context.Background(), &scan_manager.ScanResult{
+			IP: key.IP, Port: key.Port, Service: key.Service, Timestamp: ts, Response: fmt.Sprintf("response %d", ts),
+		})
+	}
+
+	t.Run("should return ErrHistoryDisabled without history", func(t *testing.T) {
+		m := newTestMemory(t, &MemoryConfig{})
+
+		_, err := m.History(context.Background(), key, 0, 1000)
+		if !errors.Is(err, scan_manager.ErrHistoryDisabled) {
+			t.Errorf("expected ErrHistoryDisabled, got %v", err)
+		}
+	})
+
+	t.Run("should keep accepted observations in scan order", func(t *testing.T) {
+		m := newTestMemory(t, &MemoryConfig{History: true})
+		put(m, 100)
+		put(m, 300)
+		put(m, 200) // stale, dropped
+
+		entries, err := m.History(context.Background(), key, 0, 1000)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(entries) != 2 || entries[0].Timestamp != 100 || entries[1].Timestamp != 300 {
+			t.Errorf("expected timestamps [100 300], got %+v", entries)
+		}
+	})
+
+	t.Run("should record stale observations when enabled", func(t *testing.T) {
+		m := newTestMemory(t, &MemoryConfig{History: true, RecordStale: true})
+		put(m, 100)
+		put(m, 300)
+		put(m, 200)
+
+		entries, err := m.History(context.Background(), key, 150, 1000)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(entries) != 2 {
+			t.Fatalf("expected 2 entries, got %+v", entries)
+		}
+
+		if entries[0].Timestamp != 200 || !entries[0].Stale {
+			t.Errorf("expected stale entry at 200, got %+v", entries[0])
+		}
+
+		if entries[1].Timestamp != 300 || entries[1].Stale {
+			t.Errorf("expected accepted entry at 300, got %+v", entries[1])
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// undefinedTable is the SQLSTATE for a missing table, such as before migrating
+const undefinedTable = "42P01"
+
+// classify maps SQLSTATE codes and connection failures to scan_manager error classes
+func classify(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// SQLSTATE classes are the first two characters of the code
+		switch class := pgErr.Code[:2]; {
+		case pgErr.Code == "53300" || pgErr.Code == "57014":
+			// Too many connections, or a statement timeout under load
+			return fmt.Errorf("%w: %w", scan_manager.ErrThrottled, err)
+		case class == "22" || class == "54":
+			// Data exceptions, e.g. a NUL byte in a response, and program
+			// limits, e.g. an index entry too large for its page
+			return fmt.Errorf("%w: %w", scan_manager.ErrInvalidScan, err)
+		case class == "08" || class == "40" || class == "53" || class == "57":
+			// Connection failures, serialization failures and deadlocks,
+			// insufficient resources and shutdowns
+			return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+		}
+
+		return err
+	}
+
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+	}
+
+	return err
+}
@@ -0,0 +1,221 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationFiles are named NNNN_description.sql and applied in version order
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey serializes migrators across processes with an advisory lock
+const migrationLockKey = 0x5ca4
+
+// Migration is a versioned schema change. Migrations are applied in order,
+// each in a transaction with its version record.
+type Migration struct {
+	Version     int
+	Description string
+	sql         string
+}
+
+// migrations is the ordered schema history, parsed from migrationFiles
+var migrations = mustLoadMigrations(migrationFiles)
+
+func mustLoadMigrations(files fs.FS) []Migration {
+	loaded, err := loadMigrations(files)
+	if err != nil {
+		panic(err)
+	}
+	return loaded
+}
+
+func loadMigrations(files fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(files, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded []Migration
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+
+		prefix, description, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s is not named NNNN_description.sql", name)
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s has an invalid version", name)
+		}
+
+		sql, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+
+		loaded = append(loaded, Migration{
+			Version:     version,
+			Description: strings.ReplaceAll(description, "_", " "),
+			sql:         string(sql),
+		})
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].Version < loaded[j].Version
+	})
+
+	for i := 1; i < len(loaded); i++ {
+		if loaded[i].Version == loaded[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", loaded[i].Version)
+		}
+	}
+
+	return loaded, nil
+}
+
+type MigratorConfig struct {
+	Pool *pgxpool.Pool
+}
+
+type migrator struct {
+	pool *pgxpool.Pool
+}
+
+func NewMigrator(cfg *MigratorConfig) (*migrator, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Pool == nil {
+		return nil, errors.New("Postgres pool is nil")
+	}
+
+	return &migrator{pool: cfg.Pool}, nil
+}
+
+// Version returns the applied schema version, zero if no migration has run
+func (m *migrator) Version(ctx context.Context) (int, error) {
+	return version(ctx, m.pool)
+}
+
+// Pending returns the migrations newer than the applied schema version
+func (m *migrator) Pending(ctx context.Context) ([]Migration, error) {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Migrate applies pending migrations in order and returns the ones applied.
+// Concurrent migrators wait for each other, so each migration runs once.
+func (m *migrator) Migrate(ctx context.Context) ([]Migration, error) {
+	err := m.locked(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version     INTEGER     PRIMARY KEY,
+				description TEXT        NOT NULL,
+				applied_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+			)`)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied []Migration
+	for _, migration := range migrations {
+		ran := false
+		err := m.locked(ctx, func(tx pgx.Tx) error {
+			// Read under the lock, another migrator may have just applied it
+			current, err := version(ctx, tx)
+			if err != nil {
+				return err
+			}
+
+			if migration.Version <= current {
+				return nil
+			}
+
+			if _, err := tx.Exec(ctx, migration.sql); err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(ctx,
+				`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`,
+				migration.Version, migration.Description,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to record schema version %d: %w", migration.Version, err)
+			}
+
+			ran = true
+			return nil
+		})
+		if err != nil {
+			return applied, fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// locked runs fn in a transaction holding the migration lock
+func (m *migrator) locked(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockKey); err != nil {
+			return fmt.Errorf("failed to lock schema: %w", err)
+		}
+
+		return fn(tx)
+	})
+}
+
+// version reads the applied schema version through q
+func version(ctx context.Context, q interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}) (int, error) {
+	var current int
+	err := q.QueryRow(ctx, `SELECT coalesce(max(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == undefinedTable {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return current, nil
+}
+
+// Migrations returns every known migration in order
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
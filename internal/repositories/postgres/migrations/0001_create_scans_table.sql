@@ -0,0 +1,15 @@
+-- The latest scan per service on a host. The primary key doubles as the ip
+-- index, and every index is ordered so that listings page in primary key
+-- order without sorting.
+CREATE TABLE IF NOT EXISTS scans (
+    ip           TEXT    NOT NULL,
+    port         INTEGER NOT NULL,
+    service      TEXT    NOT NULL,
+    timestamp    BIGINT  NOT NULL,
+    response     TEXT    NOT NULL,
+    data_version INTEGER NOT NULL,
+    PRIMARY KEY (ip, port, service)
+);
+
+CREATE INDEX IF NOT EXISTS scans_service_ip_port ON scans (service, ip, port);
+CREATE INDEX IF NOT EXISTS scans_port_ip_service ON scans (port, ip, service);
@@ -0,0 +1,11 @@
+-- Every accepted observation, and optionally every stale one, per service
+CREATE TABLE IF NOT EXISTS scan_history (
+    ip           TEXT    NOT NULL,
+    port         INTEGER NOT NULL,
+    service      TEXT    NOT NULL,
+    timestamp    BIGINT  NOT NULL,
+    response     TEXT    NOT NULL,
+    data_version INTEGER NOT NULL,
+    stale        BOOLEAN NOT NULL,
+    PRIMARY KEY (ip, port, service, timestamp)
+);
@@ -0,0 +1,422 @@
+package postgres
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// columns are the scan columns in the order scanResult reads them
+const columns = "ip, port, service, timestamp, response, data_version"
+
+// upsert only replaces the stored row when the new timestamp is strictly
+// greater. It reports whether it inserted rather than updated, and returns
+// no row when the stored scan won.
+const upsert = `
+	INSERT INTO scans (` + columns + `) VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (ip, port, service) DO UPDATE SET
+		timestamp = excluded.timestamp,
+		response = excluded.response,
+		data_version = excluded.data_version
+	WHERE excluded.timestamp > scans.timestamp
+	RETURNING xmax = 0`
+
+// errRaced reports that another writer inserted the key first, the
+// transaction is rolled back and retried against the stored row
+var errRaced = errors.New("concurrent insert")
+
+// maxRaceAttempts bounds the transactions run for one write. The retry after
+// a race finds the row to lock, so racing again means rows are being deleted
+// under it.
+const maxRaceAttempts = 3
+
+type PostgresConfig struct {
+	// Pool must reach a database migrated by Migrator
+	Pool *pgxpool.Pool
+	// History keeps every accepted observation, not just the latest
+	History bool
+	// RecordStale also keeps observations that were rejected as stale. Requires History.
+	RecordStale bool
+}
+
+type postgres struct {
+	pool        *pgxpool.Pool
+	keepHistory bool
+	recordStale bool
+}
+
+func NewPostgres(cfg *PostgresConfig) (*postgres, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Pool == nil {
+		return nil, errors.New("Postgres pool is nil")
+	}
+
+	if cfg.RecordStale && !cfg.History {
+		return nil, errors.New("recording stale scans requires history")
+	}
+
+	p := &postgres{
+		pool:        cfg.Pool,
+		keepHistory: cfg.History,
+		recordStale: cfg.RecordStale,
+	}
+
+	return p, nil
+}
+
+func (p *postgres) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	var put *scan_manager.PutResult
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		put, err = p.put(ctx, tx, result)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return put, nil
+}
+
+// PutBatch stores results in a single transaction, so the batch costs one
+// commit instead of one per scan. Rows are locked in key order so that
+// concurrent batches can't deadlock.
+func (p *postgres) PutBatch(ctx context.Context, results []*scan_manager.ScanResult) ([]*scan_manager.PutResult, error) {
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return lessKey(results[order[i]].Key(), results[order[j]].Key())
+	})
+
+	puts := make([]*scan_manager.PutResult, len(results))
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		for _, i := range order {
+			put, err := p.put(ctx, tx, results[i])
+			if err != nil {
+				return err
+			}
+			puts[i] = put
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return puts, nil
+}
+
+// lessKey orders keys like the primary key
+func lessKey(a, b scan_manager.ScanKey) bool {
+	if a.IP != b.IP {
+		return a.IP < b.IP
+	}
+	if a.Port != b.Port {
+		return a.Port < b.Port
+	}
+	return a.Service < b.Service
+}
+
+// put locks the stored scan to classify the write, then upserts. A key
+// with no row can't be locked, so a concurrent first insert is detected by
+// the upsert and retried by inTx.
+func (p *postgres) put(ctx context.Context, tx pgx.Tx, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	existing, err := p.get(ctx, tx, result.Key(), "FOR UPDATE")
+	if err != nil && !errors.Is(err, scan_manager.ErrNotFound) {
+		return nil, err
+	}
+
+	if existing != nil && existing.Timestamp >= result.Timestamp {
+		outcome := scan_manager.RejectedOutcome(existing, result)
+		if outcome == scan_manager.StaleIgnored && p.recordStale {
+			if err := p.putHistory(ctx, tx, result, true); err != nil {
+				return nil, err
+			}
+		}
+
+		return &scan_manager.PutResult{Outcome: outcome, Previous: existing}, nil
+	}
+
+	var inserted bool
+	err = tx.QueryRow(ctx, upsert,
+		result.IP, result.Port, result.Service, result.Timestamp, result.Response, result.DataVersion,
+	).Scan(&inserted)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows) || (err == nil && existing == nil && !inserted):
+		// Only possible without a locked row, another writer inserted first
+		return nil, errRaced
+	case err != nil:
+		return nil, fmt.Errorf("failed to upsert scan: %w", classify(err))
+	}
+
+	if p.keepHistory {
+		if err := p.putHistory(ctx, tx, result, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if existing == nil {
+		return &scan_manager.PutResult{Outcome: scan_manager.Inserted}, nil
+	}
+
+	return &scan_manager.PutResult{Outcome: scan_manager.Updated, Previous: existing}, nil
+}
+
+// putHistory records an observation. A stale observation with the same
+// timestamp as an accepted one must not overwrite the accepted entry.
+func (p *postgres) putHistory(ctx context.Context, tx pgx.Tx, result *scan_manager.ScanResult, stale bool) error {
+	conflict := `DO UPDATE SET response = excluded.response, data_version = excluded.data_version, stale = false`
+	if stale {
+		conflict = `DO NOTHING`
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO scan_history (`+columns+`, stale) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (ip, port, service, timestamp) `+conflict,
+		result.IP, result.Port, result.Service, result.Timestamp, result.Response, result.DataVersion, stale,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert history: %w", classify(err))
+	}
+
+	return nil
+}
+
+// inTx runs fn in a transaction, committing if it succeeds. A transaction
+// that raced a first insert is retried, and the retry finds the row to lock.
+func (p *postgres) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return retryRaced(func() error {
+		return p.tryTx(ctx, fn)
+	})
+}
+
+// retryRaced runs try until it doesn't race, up to maxRaceAttempts times
+func retryRaced(try func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := try()
+		if !errors.Is(err, errRaced) {
+			return err
+		}
+
+		if attempt == maxRaceAttempts {
+			return fmt.Errorf("%w: %w after %d attempts", scan_manager.ErrUnavailable, err, attempt)
+		}
+	}
+}
+
+func (p *postgres) tryTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", classify(err))
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", classify(err))
+	}
+
+	return nil
+}
+
+func (p *postgres) History(ctx context.Context, key scan_manager.ScanKey, from, to int64) ([]*scan_manager.HistoryEntry, error) {
+	if !p.keepHistory {
+		return nil, scan_manager.ErrHistoryDisabled
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+columns+`, stale FROM scan_history
+		WHERE ip = $1 AND port = $2 AND service = $3 AND timestamp BETWEEN $4 AND $5
+		ORDER BY timestamp`,
+		key.IP, key.Port, key.Service, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var entries []*scan_manager.HistoryEntry
+	for rows.Next() {
+		entry := &scan_manager.HistoryEntry{}
+		err := rows.Scan(
+			&entry.IP, &entry.Port, &entry.Service, &entry.Timestamp, &entry.Response, &entry.DataVersion, &entry.Stale,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", classify(err))
+	}
+
+	return entries, nil
+}
+
+func (p *postgres) Get(ctx context.Context, key scan_manager.ScanKey) (*scan_manager.ScanResult, error) {
+	return p.get(ctx, p.pool, key, "")
+}
+
+// querier is implemented by both *pgxpool.Pool and pgx.Tx
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// get reads the stored scan for key, with lock appended to the query
+func (p *postgres) get(ctx context.Context, q querier, key scan_manager.ScanKey, lock string) (*scan_manager.ScanResult, error) {
+	row := q.QueryRow(ctx,
+		`SELECT `+columns+` FROM scans WHERE ip = $1 AND port = $2 AND service = $3 `+lock,
+		key.IP, key.Port, key.Service,
+	)
+
+	result, err := scanResult(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, scan_manager.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan: %w", classify(err))
+	}
+
+	return result, nil
+}
+
+func (p *postgres) ListByIP(ctx context.Context, ip string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return p.list(ctx, opts, "ip", ip)
+}
+
+func (p *postgres) ListByService(ctx context.Context, service string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return p.list(ctx, opts, "service", service)
+}
+
+func (p *postgres) ListByPort(ctx context.Context, port uint32, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	opts.Port = 0
+	return p.list(ctx, opts, "port", port)
+}
+
+// pageToken is the key of the last result on the previous page
+type pageToken struct {
+	IP      string `json:"ip"`
+	Port    uint32 `json:"port"`
+	Service string `json:"service"`
+}
+
+// list returns the scans whose column equals value in primary key order,
+// reading one extra row to tell whether there is another page
+func (p *postgres) list(ctx context.Context, opts scan_manager.ListOptions, column string, value any) (*scan_manager.ListPage, error) {
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{column + " = " + arg(value)}
+
+	if opts.Port != 0 {
+		conditions = append(conditions, "port = "+arg(opts.Port))
+	}
+
+	if opts.PageToken != "" {
+		after, err := decodePageToken(opts.PageToken)
+		if err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, fmt.Sprintf("(ip, port, service) > (%s, %s, %s)",
+			arg(after.IP), arg(after.Port), arg(after.Service)))
+	}
+
+	limit := opts.PageSize()
+
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+columns+` FROM scans WHERE `+strings.Join(conditions, " AND ")+
+			` ORDER BY ip, port, service LIMIT `+arg(limit+1),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scans: %w", classify(err))
+	}
+	defer rows.Close()
+
+	page := &scan_manager.ListPage{}
+	for rows.Next() {
+		result, err := scanResult(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read scan: %w", err)
+		}
+		page.Results = append(page.Results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list scans: %w", classify(err))
+	}
+
+	if len(page.Results) > limit {
+		page.Results = page.Results[:limit]
+		page.NextPageToken, err = encodePageToken(page.Results[limit-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+func encodePageToken(last *scan_manager.ScanResult) (string, error) {
+	data, err := json.Marshal(pageToken{IP: last.IP, Port: last.Port, Service: last.Service})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode page token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageToken(token string) (*pageToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", scan_manager.ErrInvalidPageToken, err)
+	}
+
+	var after pageToken
+	if err := json.Unmarshal(data, &after); err != nil {
+		return nil, fmt.Errorf("%w: %v", scan_manager.ErrInvalidPageToken, err)
+	}
+
+	return &after, nil
+}
+
+// scanResult reads a row selected with columns
+func scanResult(row pgx.Row) (*scan_manager.ScanResult, error) {
+	result := &scan_manager.ScanResult{}
+	err := row.Scan(&result.IP, &result.Port, &result.Service, &result.Timestamp, &result.Response, &result.DataVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Ping checks the database is reachable and migrated
+func (p *postgres) Ping(ctx context.Context) error {
+	if _, err := p.pool.Exec(ctx, `SELECT 1 FROM scans LIMIT 1`); err != nil {
+		return fmt.Errorf("failed to query scans table: %w", classify(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"testing/fstest"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestNewPostgres(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewPostgres(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if pool is nil", func(t *testing.T) {
+		_, err := NewPostgres(&PostgresConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if recording stale scans without history", func(t *testing.T) {
+		_, err := NewPostgres(&PostgresConfig{Pool: &pgxpool.Pool{}, RecordStale: true})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return a new Postgres", func(t *testing.T) {
+		_, err := NewPostgres(&PostgresConfig{Pool: &pgxpool.Pool{}})
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}
+
+func TestRetryRaced(t *testing.T) {
+	t.Run("should retry until the write doesn't race", func(t *testing.T) {
+		attempts := 0
+		err := retryRaced(func() error {
+			attempts++
+			if attempts < maxRaceAttempts {
+				return errRaced
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if attempts != maxRaceAttempts {
+			t.Errorf("expected %d attempts, got %d", maxRaceAttempts, attempts)
+		}
+	})
+
+	t.Run("should give up as unavailable", func(t *testing.T) {
+		attempts := 0
+		err := retryRaced(func() error {
+			attempts++
+			return errRaced
+		})
+		if !errors.Is(err, scan_manager.ErrUnavailable) {
+			t.Errorf("expected ErrUnavailable, got %v", err)
+		}
+
+		if attempts != maxRaceAttempts {
+			t.Errorf("expected %d attempts, got %d", maxRaceAttempts, attempts)
+		}
+	})
+
+	t.Run("should not retry other errors", func(t *testing.T) {
+		attempts := 0
+		failure := errors.New("constraint violated")
+		err := retryRaced(func() error {
+			attempts++
+			return failure
+		})
+		if !errors.Is(err, failure) || attempts != 1 {
+			t.Errorf("expected the error after 1 attempt, got %v after %d", err, attempts)
+		}
+	})
+}
+
+func TestNewMigrator(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewMigrator(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if pool is nil", func(t *testing.T) {
+		_, err := NewMigrator(&MigratorConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("should load the embedded migrations in order", func(t *testing.T) {
+		loaded := Migrations()
+		if len(loaded) == 0 {
+			t.Fatalf("expected embedded migrations")
+		}
+
+		for i, migration := range loaded {
+			if migration.Version != i+1 {
+				t.Errorf("expected migration %d to have version %d, got %d", i, i+1, migration.Version)
+			}
+
+			if migration.Description == "" || migration.sql == "" {
+				t.Errorf("expected migration %d to have a description and SQL", migration.Version)
+			}
+		}
+
+		if loaded[0].Description != "create scans table" {
+			t.Errorf("expected 'create scans table', got '%s'", loaded[0].Description)
+		}
+	})
+
+	t.Run("should sort by version, not name", func(t *testing.T) {
+		loaded, err := loadMigrations(fstest.MapFS{
+			"migrations/10_later.sql":  {Data: []byte("SELECT 10")},
+			"migrations/9_earlier.sql": {Data: []byte("SELECT 9")},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(loaded) != 2 || loaded[0].Version != 9 || loaded[1].Version != 10 {
+			t.Errorf("expected versions [9 10], got %+v", loaded)
+		}
+	})
+
+	for name, files := range map[string]fstest.MapFS{
+		"unnumbered names":   {"migrations/create.sql": {}},
+		"invalid versions":   {"migrations/v1_create.sql": {}},
+		"duplicate versions": {"migrations/1_create.sql": {}, "migrations/0001_again.sql": {}},
+	} {
+		t.Run("should reject "+name, func(t *testing.T) {
+			if _, err := loadMigrations(files); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestPageToken(t *testing.T) {
+	t.Run("should round trip the last key", func(t *testing.T) {
+		token, err := encodePageToken(&scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "SSH"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		after, err := decodePageToken(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if *after != (pageToken{IP: "10.0.0.1", Port: 22, Service: "SSH"}) {
+			t.Errorf("expected the last key, got %+v", after)
+		}
+	})
+
+	t.Run("should reject malformed tokens", func(t *testing.T) {
+		if _, err := decodePageToken("not a token"); !errors.Is(err, scan_manager.ErrInvalidPageToken) {
+			t.Errorf("expected ErrInvalidPageToken, got %v", err)
+		}
+	})
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"too many connections", &pgconn.PgError{Code: "53300"}, scan_manager.ErrThrottled},
+		{"invalid text", &pgconn.PgError{Code: "22021"}, scan_manager.ErrInvalidScan},
+		{"oversized index entries", &pgconn.PgError{Code: "54000"}, scan_manager.ErrInvalidScan},
+		{"deadlocks", &pgconn.PgError{Code: "40P01"}, scan_manager.ErrUnavailable},
+		{"shutdowns", &pgconn.PgError{Code: "57P01"}, scan_manager.ErrUnavailable},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, scan_manager.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run("should classify "+tt.name, func(t *testing.T) {
+			err := classify(tt.err)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected the original error to be wrapped, got %v", err)
+			}
+		})
+	}
+
+	t.Run("should leave unknown errors unclassified", func(t *testing.T) {
+		err := &pgconn.PgError{Code: "23505"}
+		if classify(err) != error(err) {
+			t.Errorf("expected the error unchanged")
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// history reads the observations for key, skipping the test when the
+// repository doesn't keep history
+func history(t *testing.T, repo scan_manager.Repository, key scan_manager.ScanKey, from, to int64) []*scan_manager.HistoryEntry {
+	t.Helper()
+
+	entries, err := repo.History(context.Background(), key, from, to)
+	if errors.Is(err, scan_manager.ErrHistoryDisabled) {
+		t.Skip("repository doesn't keep history")
+	}
+	if err != nil {
+		t.Fatalf("failed to read history for %s: %v", key, err)
+	}
+
+	return entries
+}
+
+func testHistory(t *testing.T, newRepo NewRepository) {
+	t.Run("should keep one entry per timestamp", func(t *testing.T) {
+		repo := newRepo(t)
+		key := scan("10.0.0.1", 22, "SSH", 0, "").Key()
+		history(t, repo, key, 0, 1000)
+
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "first"))
+		// Redelivered, then a different response at the same timestamp
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "first"))
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "other"))
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 300, "third"))
+		// Stale, each delivered twice
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "first"))
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "first"))
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 200, "late"))
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 200, "late"))
+
+		entries := history(t, repo, key, 0, 1000)
+
+		byTimestamp := make(map[int64]*scan_manager.HistoryEntry)
+		for i, entry := range entries {
+			if i > 0 && entry.Timestamp <= entries[i-1].Timestamp {
+				t.Fatalf("expected entries in ascending timestamp order without repeats, got %d after %d",
+					entry.Timestamp, entries[i-1].Timestamp)
+			}
+			byTimestamp[entry.Timestamp] = entry
+		}
+
+		for _, expected := range []*scan_manager.ScanResult{
+			scan("10.0.0.1", 22, "SSH", 100, "first"),
+			scan("10.0.0.1", 22, "SSH", 300, "third"),
+		} {
+			entry, ok := byTimestamp[expected.Timestamp]
+			if !ok {
+				t.Errorf("expected an entry at %d", expected.Timestamp)
+				continue
+			}
+
+			if entry.Stale || entry.Response != expected.Response {
+				t.Errorf("expected accepted %q at %d, got %+v", expected.Response, expected.Timestamp, entry)
+			}
+		}
+
+		// Only present when the repository records stale scans
+		if entry, ok := byTimestamp[200]; ok && (!entry.Stale || entry.Response != "late") {
+			t.Errorf("expected stale %q at 200, got %+v", "late", entry)
+		}
+	})
+
+	t.Run("should only return entries in the range", func(t *testing.T) {
+		repo := newRepo(t)
+		key := scan("10.0.0.1", 22, "SSH", 0, "").Key()
+		history(t, repo, key, 0, 1000)
+
+		for _, ts := range []int64{100, 200, 300} {
+			put(t, repo, scan("10.0.0.1", 22, "SSH", ts, "response"))
+		}
+		put(t, repo, scan("10.0.0.2", 22, "SSH", 200, "other key"))
+
+		entries := history(t, repo, key, 150, 300)
+		if len(entries) != 2 || entries[0].Timestamp != 200 || entries[1].Timestamp != 300 {
+			t.Errorf("expected entries at 200 and 300, got %+v", entries)
+		}
+	})
+}
@@ -0,0 +1,329 @@
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// get reads a scan, failing the test on error
+func get(t *testing.T, repo scan_manager.Repository, key scan_manager.ScanKey) *scan_manager.ScanResult {
+	t.Helper()
+
+	result, err := repo.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", key, err)
+	}
+
+	return result
+}
+
+// listed returns the scans each listing that should include key holds for
+// it, so stale copies left behind by a backend show up
+func listed(t *testing.T, repo scan_manager.Repository, key scan_manager.ScanKey) map[string][]*scan_manager.ScanResult {
+	t.Helper()
+
+	ctx := context.Background()
+	listings := map[string]func(scan_manager.ListOptions) (*scan_manager.ListPage, error){
+		"ip": func(opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+			return repo.ListByIP(ctx, key.IP, opts)
+		},
+		"service": func(opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+			return repo.ListByService(ctx, key.Service, opts)
+		},
+		"port": func(opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+			return repo.ListByPort(ctx, key.Port, opts)
+		},
+	}
+
+	found := map[string][]*scan_manager.ScanResult{}
+	for name, list := range listings {
+		opts := scan_manager.ListOptions{}
+		for range 100 {
+			page, err := list(opts)
+			if err != nil {
+				t.Fatalf("failed to list by %s: %v", name, err)
+			}
+
+			for _, result := range page.Results {
+				if result.Key() == key {
+					found[name] = append(found[name], result)
+				}
+			}
+
+			if page.NextPageToken == "" {
+				break
+			}
+			opts.PageToken = page.NextPageToken
+		}
+	}
+
+	return found
+}
+
+// expectListed checks every listing holds exactly the expected scan for its key
+func expectListed(t *testing.T, repo scan_manager.Repository, expected *scan_manager.ScanResult) {
+	t.Helper()
+
+	found := listed(t, repo, expected.Key())
+	for _, name := range []string{"ip", "service", "port"} {
+		results := found[name]
+		if len(results) != 1 || *results[0] != *expected {
+			t.Errorf("expected the listing by %s to hold only %+v, got %+v", name, *expected, results)
+		}
+	}
+}
+
+func testOutOfOrder(t *testing.T, newRepo NewRepository) {
+	t.Run("should keep the newest scan whatever order scans arrive in", func(t *testing.T) {
+		repo := newRepo(t)
+
+		steps := []struct {
+			timestamp int64
+			outcome   scan_manager.PutOutcome
+			previous  int64
+		}{
+			{500, scan_manager.Inserted, 0},
+			{300, scan_manager.StaleIgnored, 500},
+			{900, scan_manager.Updated, 500},
+			{100, scan_manager.StaleIgnored, 900},
+			{700, scan_manager.StaleIgnored, 900},
+			{900, scan_manager.Duplicate, 900},
+		}
+
+		for _, step := range steps {
+			got := put(t, repo, scan("10.0.0.1", 443, "HTTPS", step.timestamp, fmt.Sprintf("response %d", step.timestamp)))
+			if got.Outcome != step.outcome {
+				t.Errorf("expected %s at %d, got %s", step.outcome, step.timestamp, got.Outcome)
+			}
+
+			var previous int64
+			if got.Previous != nil {
+				previous = got.Previous.Timestamp
+			}
+			if previous != step.previous {
+				t.Errorf("expected the previous scan at %d to be from %d, got %d", step.timestamp, step.previous, previous)
+			}
+		}
+
+		expected := scan("10.0.0.1", 443, "HTTPS", 900, "response 900")
+		if got := get(t, repo, expected.Key()); *got != *expected {
+			t.Errorf("expected %+v, got %+v", *expected, *got)
+		}
+
+		expectListed(t, repo, expected)
+	})
+}
+
+func testEqualTimestamps(t *testing.T, newRepo NewRepository) {
+	t.Run("should keep the first of two scans with the same timestamp", func(t *testing.T) {
+		repo := newRepo(t)
+		first := put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "first"))
+		if first.Outcome != scan_manager.Inserted {
+			t.Fatalf("expected inserted, got %s", first.Outcome)
+		}
+
+		got := put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "second"))
+		if got.Outcome != scan_manager.StaleIgnored || got.Previous == nil || got.Previous.Response != "first" {
+			t.Errorf("expected stale_ignored with the first scan, got %+v", got)
+		}
+
+		if stored := get(t, repo, scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"}); stored.Response != "first" {
+			t.Errorf("expected the first scan to be kept, got %+v", stored)
+		}
+	})
+
+	t.Run("should treat a different data version with the same response as a duplicate", func(t *testing.T) {
+		repo := newRepo(t)
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "same"))
+
+		redelivered := scan("10.0.0.1", 22, "SSH", 100, "same")
+		redelivered.DataVersion = 1
+
+		got := put(t, repo, redelivered)
+		if got.Outcome != scan_manager.Duplicate {
+			t.Errorf("expected duplicate, got %s", got.Outcome)
+		}
+
+		stored := get(t, repo, redelivered.Key())
+		if stored.DataVersion != 2 {
+			t.Errorf("expected the stored data version to be kept, got %d", stored.DataVersion)
+		}
+
+		expectListed(t, repo, stored)
+	})
+}
+
+func testConcurrentWriters(t *testing.T, newRepo NewRepository) {
+	const writers = 10
+
+	t.Run("should let the newest of concurrent scans win", func(t *testing.T) {
+		repo := newRepo(t)
+
+		results := make([]*scan_manager.PutResult, writers)
+		var wg sync.WaitGroup
+		for i := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				got, err := repo.Put(context.Background(), scan("10.0.0.1", 22, "SSH", int64(i+1), fmt.Sprintf("response %d", i+1)))
+				if err != nil {
+					t.Errorf("failed to put scan %d: %v", i+1, err)
+					return
+				}
+				results[i] = got
+			}()
+		}
+		wg.Wait()
+
+		inserted := 0
+		for i, got := range results {
+			if got == nil {
+				continue
+			}
+
+			ts := int64(i + 1)
+			switch got.Outcome {
+			case scan_manager.Inserted:
+				inserted++
+			case scan_manager.Updated:
+				if got.Previous == nil || got.Previous.Timestamp >= ts {
+					t.Errorf("expected scan %d to replace an older scan, got %+v", ts, got.Previous)
+				}
+			case scan_manager.StaleIgnored:
+				if got.Previous == nil || got.Previous.Timestamp <= ts {
+					t.Errorf("expected scan %d to lose to a newer scan, got %+v", ts, got.Previous)
+				}
+			default:
+				t.Errorf("expected no duplicates among distinct scans, got %s for %d", got.Outcome, ts)
+			}
+		}
+
+		if inserted != 1 {
+			t.Errorf("expected exactly one insert, got %d", inserted)
+		}
+
+		expected := scan("10.0.0.1", 22, "SSH", writers, fmt.Sprintf("response %d", writers))
+		if got := get(t, repo, expected.Key()); *got != *expected {
+			t.Errorf("expected the newest scan to win, got %+v", *got)
+		}
+
+		expectListed(t, repo, expected)
+	})
+
+	t.Run("should insert concurrent redeliveries once", func(t *testing.T) {
+		repo := newRepo(t)
+
+		outcomes := make(chan scan_manager.PutOutcome, writers)
+		var wg sync.WaitGroup
+		for range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				got, err := repo.Put(context.Background(), scan("10.0.0.1", 22, "SSH", 100, "same"))
+				if err != nil {
+					t.Errorf("failed to put scan: %v", err)
+					return
+				}
+				outcomes <- got.Outcome
+			}()
+		}
+		wg.Wait()
+		close(outcomes)
+
+		counts := map[scan_manager.PutOutcome]int{}
+		for outcome := range outcomes {
+			counts[outcome]++
+		}
+
+		if counts[scan_manager.Inserted] != 1 || counts[scan_manager.Duplicate] != writers-1 {
+			t.Errorf("expected one insert and %d duplicates, got %v", writers-1, counts)
+		}
+	})
+}
+
+func testDistinctKeys(t *testing.T, newRepo NewRepository) {
+	t.Run("should store each port and service of a host separately", func(t *testing.T) {
+		repo := newRepo(t)
+
+		// Older timestamps than the other keys' are still the first for their own
+		scans := []*scan_manager.ScanResult{
+			scan("10.0.0.1", 80, "HTTP", 300, "http on 80"),
+			scan("10.0.0.1", 443, "HTTP", 200, "http on 443"),
+			scan("10.0.0.1", 80, "SSH", 100, "ssh on 80"),
+			scan("10.0.0.2", 80, "HTTP", 50, "another host"),
+		}
+		for _, s := range scans {
+			if got := put(t, repo, s); got.Outcome != scan_manager.Inserted {
+				t.Errorf("expected %s to be inserted, got %s", s.Key(), got.Outcome)
+			}
+		}
+
+		// A newer scan for one key leaves the others alone
+		if got := put(t, repo, scan("10.0.0.1", 80, "HTTP", 400, "http on 80 again")); got.Outcome != scan_manager.Updated {
+			t.Errorf("expected updated, got %s", got.Outcome)
+		}
+		scans[0].Timestamp, scans[0].Response = 400, "http on 80 again"
+
+		for _, expected := range scans {
+			if got := get(t, repo, expected.Key()); *got != *expected {
+				t.Errorf("expected %+v, got %+v", *expected, *got)
+			}
+		}
+
+		keys := listAll(t, 0, func(opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+			return repo.ListByIP(context.Background(), "10.0.0.1", opts)
+		})
+
+		if len(keys) != 3 {
+			t.Errorf("expected the 3 keys of 10.0.0.1, got %v", keys)
+		}
+	})
+}
+
+func testLargeResponses(t *testing.T, newRepo NewRepository) {
+	t.Run("should store a large response intact", func(t *testing.T) {
+		repo := newRepo(t)
+
+		// 256 KiB of banner with multi-byte characters, within every
+		// backend's item size limit
+		var response strings.Builder
+		for i := 0; response.Len() < 256<<10; i++ {
+			fmt.Fprintf(&response, "HTTP/1.1 200 OK\r\nX-Line: %d ✓\r\n", i)
+		}
+
+		large := scan("10.0.0.1", 80, "HTTP", 100, response.String())
+		put(t, repo, large)
+
+		if got := get(t, repo, large.Key()); *got != *large {
+			t.Errorf("expected the %d byte response back intact, got %d bytes", len(large.Response), len(got.Response))
+		}
+
+		if got := put(t, repo, large); got.Outcome != scan_manager.Duplicate {
+			t.Errorf("expected a redelivery of the large scan to be a duplicate, got %s", got.Outcome)
+		}
+
+		expectListed(t, repo, large)
+	})
+}
+
+func testReadAfterWrite(t *testing.T, newRepo NewRepository) {
+	t.Run("should read every accepted scan straight after writing it", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for ts := int64(1); ts <= 20; ts++ {
+			written := scan("10.0.0.1", uint32(ts%3+1), "HTTP", ts, fmt.Sprintf("response %d", ts))
+			put(t, repo, written)
+
+			if got := get(t, repo, written.Key()); *got != *written {
+				t.Fatalf("expected %+v straight after writing it, got %+v", *written, *got)
+			}
+		}
+	})
+}
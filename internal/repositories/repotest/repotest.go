@@ -0,0 +1,250 @@
+// Package repotest is a conformance suite for scan_manager.Repository
+// implementations, so every backend is held to the same semantics. A backend
+// runs it from its own tests with a func returning an empty repository:
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) scan_manager.Repository {
+//			return newTestRepo(t)
+//		})
+//	}
+//
+// It covers newer-timestamp-wins under out-of-order, equal-timestamp and
+// concurrent writes, key isolation, large responses, read-after-write, and
+// paged listings, and one history entry per timestamp for backends that keep
+// history. Listings are read straight after writes, so backends whose
+// listings lag must run it against an emulator that doesn't.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// NewRepository returns an empty repository for a single test. Anything it
+// needs closing should be registered with t.Cleanup.
+type NewRepository func(t *testing.T) scan_manager.Repository
+
+// Run runs the conformance suite against repositories built by newRepo
+func Run(t *testing.T, newRepo NewRepository) {
+	t.Run("Put", func(t *testing.T) { testPut(t, newRepo) })
+	t.Run("OutOfOrder", func(t *testing.T) { testOutOfOrder(t, newRepo) })
+	t.Run("EqualTimestamps", func(t *testing.T) { testEqualTimestamps(t, newRepo) })
+	t.Run("ConcurrentWriters", func(t *testing.T) { testConcurrentWriters(t, newRepo) })
+	t.Run("DistinctKeys", func(t *testing.T) { testDistinctKeys(t, newRepo) })
+	t.Run("LargeResponses", func(t *testing.T) { testLargeResponses(t, newRepo) })
+	t.Run("ReadAfterWrite", func(t *testing.T) { testReadAfterWrite(t, newRepo) })
+	t.Run("Get", func(t *testing.T) { testGet(t, newRepo) })
+	t.Run("List", func(t *testing.T) { testList(t, newRepo) })
+	t.Run("History", func(t *testing.T) { testHistory(t, newRepo) })
+	t.Run("Ping", func(t *testing.T) {
+		if err := newRepo(t).Ping(context.Background()); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}
+
+// put stores a scan, failing the test on error
+func put(t *testing.T, repo scan_manager.Repository, result *scan_manager.ScanResult) *scan_manager.PutResult {
+	t.Helper()
+
+	outcome, err := repo.Put(context.Background(), result)
+	if err != nil {
+		t.Fatalf("failed to put %s: %v", result.Key(), err)
+	}
+
+	return outcome
+}
+
+func scan(ip string, port uint32, service string, timestamp int64, response string) *scan_manager.ScanResult {
+	return &scan_manager.ScanResult{
+		IP: ip, Port: port, Service: service, Timestamp: timestamp, Response: response, DataVersion: 2,
+	}
+}
+
+func testPut(t *testing.T, newRepo NewRepository) {
+	t.Run("should insert the first scan for a key", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got := put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "first"))
+		if got.Outcome != scan_manager.Inserted || got.Previous != nil {
+			t.Errorf("expected inserted with no previous scan, got %+v", got)
+		}
+	})
+
+	t.Run("should replace with a newer timestamp", func(t *testing.T) {
+		repo := newRepo(t)
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "older"))
+
+		got := put(t, repo, scan("10.0.0.1", 22, "SSH", 200, "newer"))
+		if got.Outcome != scan_manager.Updated || got.Previous == nil || got.Previous.Response != "older" {
+			t.Errorf("expected updated with the replaced scan, got %+v", got)
+		}
+	})
+
+	t.Run("should ignore older and equal timestamps", func(t *testing.T) {
+		repo := newRepo(t)
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 200, "newer"))
+
+		for _, ts := range []int64{100, 200} {
+			got := put(t, repo, scan("10.0.0.1", 22, "SSH", ts, "stale"))
+			if got.Outcome != scan_manager.StaleIgnored || got.Previous == nil || got.Previous.Response != "newer" {
+				t.Errorf("expected stale_ignored at %d with the stored scan, got %+v", ts, got)
+			}
+		}
+	})
+
+	t.Run("should report exact redeliveries as duplicates", func(t *testing.T) {
+		repo := newRepo(t)
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 200, "same"))
+
+		got := put(t, repo, scan("10.0.0.1", 22, "SSH", 200, "same"))
+		if got.Outcome != scan_manager.Duplicate || got.Previous == nil || got.Previous.Timestamp != 200 {
+			t.Errorf("expected duplicate with the stored scan, got %+v", got)
+		}
+	})
+}
+
+func testGet(t *testing.T, newRepo NewRepository) {
+	t.Run("should return ErrNotFound for missing keys", func(t *testing.T) {
+		_, err := newRepo(t).Get(context.Background(), scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"})
+		if !errors.Is(err, scan_manager.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should return every field of the latest scan", func(t *testing.T) {
+		repo := newRepo(t)
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 300, "latest"))
+		put(t, repo, scan("10.0.0.1", 22, "SSH", 100, "stale"))
+
+		expected := scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: 300, Response: "latest", DataVersion: 2}
+
+		got, err := repo.Get(context.Background(), expected.Key())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if *got != expected {
+			t.Errorf("expected %+v, got %+v", expected, *got)
+		}
+	})
+}
+
+func testList(t *testing.T, newRepo NewRepository) {
+	repo := newRepo(t)
+	for port := uint32(1); port <= 5; port++ {
+		put(t, repo, scan("10.0.0.1", port, "HTTP", 100, fmt.Sprintf("port %d", port)))
+	}
+	put(t, repo, scan("10.0.0.2", 22, "SSH", 100, "ssh"))
+	put(t, repo, scan("10.0.0.3", 22, "SSH", 100, "ssh"))
+	put(t, repo, scan("10.0.0.3", 3, "HTTP", 100, "http"))
+
+	t.Run("should page through results by ip", func(t *testing.T) {
+		keys := listAll(t, 2, func(opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+			return repo.ListByIP(context.Background(), "10.0.0.1", opts)
+		})
+
+		if len(keys) != 5 {
+			t.Errorf("expected 5 distinct scans, got %v", keys)
+		}
+	})
+
+	t.Run("should list by service", func(t *testing.T) {
+		keys := listAll(t, 0, func(opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+			return repo.ListByService(context.Background(), "SSH", opts)
+		})
+
+		if len(keys) != 2 || !keys["10.0.0.2#22#SSH"] || !keys["10.0.0.3#22#SSH"] {
+			t.Errorf("expected both SSH scans, got %v", keys)
+		}
+	})
+
+	t.Run("should list by port and ignore the port filter", func(t *testing.T) {
+		keys := listAll(t, 0, func(opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+			opts.Port = 80
+			return repo.ListByPort(context.Background(), 3, opts)
+		})
+
+		if len(keys) != 2 || !keys["10.0.0.1#3#HTTP"] || !keys["10.0.0.3#3#HTTP"] {
+			t.Errorf("expected both scans on port 3, got %v", keys)
+		}
+	})
+
+	t.Run("should filter by port", func(t *testing.T) {
+		keys := listAll(t, 0, func(opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+			opts.Port = 3
+			return repo.ListByIP(context.Background(), "10.0.0.1", opts)
+		})
+
+		if len(keys) != 1 || !keys["10.0.0.1#3#HTTP"] {
+			t.Errorf("expected only port 3, got %v", keys)
+		}
+
+		keys = listAll(t, 0, func(opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+			opts.Port = 3
+			return repo.ListByService(context.Background(), "HTTP", opts)
+		})
+
+		if len(keys) != 2 || !keys["10.0.0.1#3#HTTP"] || !keys["10.0.0.3#3#HTTP"] {
+			t.Errorf("expected both HTTP scans on port 3, got %v", keys)
+		}
+	})
+
+	t.Run("should return an empty page for unknown values", func(t *testing.T) {
+		page, err := repo.ListByIP(context.Background(), "192.0.2.1", scan_manager.ListOptions{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(page.Results) != 0 || page.NextPageToken != "" {
+			t.Errorf("expected an empty last page, got %+v", page)
+		}
+	})
+
+	t.Run("should reject malformed page tokens", func(t *testing.T) {
+		_, err := repo.ListByIP(context.Background(), "10.0.0.1", scan_manager.ListOptions{PageToken: "!!"})
+		if !errors.Is(err, scan_manager.ErrInvalidPageToken) {
+			t.Errorf("expected ErrInvalidPageToken, got %v", err)
+		}
+	})
+}
+
+// listAll follows page tokens to the end of a listing and returns the keys
+// seen. Pages may be short, or even empty, before the last one.
+func listAll(t *testing.T, limit int, list func(scan_manager.ListOptions) (*scan_manager.ListPage, error)) map[string]bool {
+	t.Helper()
+
+	keys := map[string]bool{}
+	opts := scan_manager.ListOptions{Limit: limit}
+
+	for range 100 {
+		page, err := list(opts)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if limit > 0 && len(page.Results) > limit {
+			t.Errorf("expected at most %d results per page, got %d", limit, len(page.Results))
+		}
+
+		for _, result := range page.Results {
+			key := result.Key().String()
+			if keys[key] {
+				t.Errorf("expected %s to be listed once", key)
+			}
+			keys[key] = true
+		}
+
+		if page.NextPageToken == "" {
+			return keys
+		}
+		opts.PageToken = page.NextPageToken
+	}
+
+	t.Fatalf("listing didn't end after 100 pages")
+	return nil
+}
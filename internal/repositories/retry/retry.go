@@ -0,0 +1,172 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+const (
+	DefaultMaxAttempts   = 5
+	DefaultBaseDelay     = 50 * time.Millisecond
+	DefaultThrottleDelay = 500 * time.Millisecond
+	DefaultMaxDelay      = 5 * time.Second
+)
+
+// Class is how a repository error is retried
+type Class int
+
+const (
+	// Transient errors are retried after the base delay. Errors the
+	// repository didn't classify are treated as transient, the same as a
+	// Nack would.
+	Transient Class = iota
+	// Throttled errors are retried after the longer throttle delay
+	Throttled
+	// Permanent errors fail the same way on every attempt and aren't retried
+	Permanent
+)
+
+func (c Class) String() string {
+	switch c {
+	case Transient:
+		return "transient"
+	case Throttled:
+		return "throttled"
+	case Permanent:
+		return "permanent"
+	default:
+		return "unknown"
+	}
+}
+
+// Classify maps a repository error onto a Class using the scan_manager error classes
+func Classify(err error) Class {
+	switch {
+	case errors.Is(err, scan_manager.ErrInvalidScan):
+		return Permanent
+	case errors.Is(err, scan_manager.ErrThrottled):
+		return Throttled
+	default:
+		return Transient
+	}
+}
+
+type RetryConfig struct {
+	Repo scan_manager.Repository
+	// MaxAttempts includes the first attempt. Defaults to DefaultMaxAttempts.
+	MaxAttempts int
+	// BaseDelay is the first backoff for transient errors, doubling per attempt. Defaults to DefaultBaseDelay.
+	BaseDelay time.Duration
+	// ThrottleDelay is the first backoff for throttled errors. Defaults to DefaultThrottleDelay.
+	ThrottleDelay time.Duration
+	// MaxDelay caps a single backoff. Defaults to DefaultMaxDelay.
+	MaxDelay time.Duration
+	// OnRetry is optional and called before each retry
+	OnRetry func(class Class)
+}
+
+// retrier is a Repository decorator that retries failed Puts with jittered
+// exponential backoff. Retries stop early rather than sleep past the
+// context's deadline, so a message is only nacked once there's no time left
+// to store it.
+type retrier struct {
+	scan_manager.Repository
+	maxAttempts   int
+	baseDelay     time.Duration
+	throttleDelay time.Duration
+	maxDelay      time.Duration
+	onRetry       func(class Class)
+}
+
+func NewRetrier(cfg *RetryConfig) (*retrier, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Repo == nil {
+		return nil, errors.New("repository is nil")
+	}
+
+	r := &retrier{
+		Repository:    cfg.Repo,
+		maxAttempts:   cfg.MaxAttempts,
+		baseDelay:     cfg.BaseDelay,
+		throttleDelay: cfg.ThrottleDelay,
+		maxDelay:      cfg.MaxDelay,
+		onRetry:       cfg.OnRetry,
+	}
+
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = DefaultMaxAttempts
+	}
+
+	if r.baseDelay <= 0 {
+		r.baseDelay = DefaultBaseDelay
+	}
+
+	if r.throttleDelay <= 0 {
+		r.throttleDelay = DefaultThrottleDelay
+	}
+
+	if r.maxDelay <= 0 {
+		r.maxDelay = DefaultMaxDelay
+	}
+
+	return r, nil
+}
+
+func (r *retrier) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	for attempt := 1; ; attempt++ {
+		put, err := r.Repository.Put(ctx, result)
+		if err == nil {
+			return put, nil
+		}
+
+		class := Classify(err)
+		if class == Permanent || ctx.Err() != nil {
+			return nil, err
+		}
+
+		if attempt >= r.maxAttempts {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		delay := r.backoff(class, attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return nil, fmt.Errorf("giving up after %d attempts, deadline too close to retry: %w", attempt, err)
+		}
+
+		if r.onRetry != nil {
+			r.onRetry(class)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns a delay between half and all of the exponential backoff
+// for attempt, so concurrent retries spread out without ever retrying
+// immediately
+func (r *retrier) backoff(class Class, attempt int) time.Duration {
+	base := r.baseDelay
+	if class == Throttled {
+		base = r.throttleDelay
+	}
+
+	ceiling := r.maxDelay
+	if shift := attempt - 1; shift < 32 && base<<shift < r.maxDelay {
+		ceiling = base << shift
+	}
+
+	half := ceiling / 2
+	return half + rand.N(ceiling-half+1)
+}
@@ -0,0 +1,179 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+)
+
+// failingRepo fails the first len(errs) Puts with errs in order
+type failingRepo struct {
+	scan_manager.Repository
+	errs  []error
+	calls int
+}
+
+func (r *failingRepo) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	r.calls++
+	if r.calls <= len(r.errs) {
+		return nil, r.errs[r.calls-1]
+	}
+	return &scan_manager.PutResult{Outcome: scan_manager.Inserted}, nil
+}
+
+func newTestRetrier(t *testing.T, repo scan_manager.Repository, retries *[]Class) *retrier {
+	r, err := NewRetrier(&RetryConfig{
+		Repo:          repo,
+		MaxAttempts:   3,
+		BaseDelay:     time.Millisecond,
+		ThrottleDelay: 2 * time.Millisecond,
+		MaxDelay:      10 * time.Millisecond,
+		OnRetry: func(class Class) {
+			*retries = append(*retries, class)
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create retrier: %v", err)
+	}
+
+	return r
+}
+
+var scan = &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "ssh", Timestamp: 100}
+
+func TestNewRetrier(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewRetrier(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if repository is nil", func(t *testing.T) {
+		_, err := NewRetrier(&RetryConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected Class
+	}{
+		{fmt.Errorf("put: %w", scan_manager.ErrThrottled), Throttled},
+		{fmt.Errorf("put: %w", scan_manager.ErrUnavailable), Transient},
+		{fmt.Errorf("put: %w", scan_manager.ErrInvalidScan), Permanent},
+		{errors.New("unknown"), Transient},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.expected {
+			t.Errorf("expected %s for %v, got %s", tt.expected, tt.err, got)
+		}
+	}
+}
+
+func TestPut(t *testing.T) {
+	t.Run("should retry until the put succeeds", func(t *testing.T) {
+		var retries []Class
+		repo := &failingRepo{errs: []error{scan_manager.ErrThrottled, scan_manager.ErrUnavailable}}
+		r := newTestRetrier(t, repo, &retries)
+
+		put, err := r.Put(context.Background(), scan)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if put.Outcome != scan_manager.Inserted {
+			t.Errorf("expected inserted, got %s", put.Outcome)
+		}
+
+		if len(retries) != 2 || retries[0] != Throttled || retries[1] != Transient {
+			t.Errorf("expected a throttled then a transient retry, got %v", retries)
+		}
+	})
+
+	t.Run("should give up after max attempts", func(t *testing.T) {
+		var retries []Class
+		repo := &failingRepo{errs: []error{scan_manager.ErrUnavailable, scan_manager.ErrUnavailable, scan_manager.ErrUnavailable}}
+		r := newTestRetrier(t, repo, &retries)
+
+		_, err := r.Put(context.Background(), scan)
+		if !errors.Is(err, scan_manager.ErrUnavailable) {
+			t.Errorf("expected ErrUnavailable, got %v", err)
+		}
+
+		if repo.calls != 3 {
+			t.Errorf("expected 3 attempts, got %d", repo.calls)
+		}
+	})
+
+	t.Run("should not retry permanent errors", func(t *testing.T) {
+		var retries []Class
+		repo := &failingRepo{errs: []error{scan_manager.ErrInvalidScan}}
+		r := newTestRetrier(t, repo, &retries)
+
+		_, err := r.Put(context.Background(), scan)
+		if !errors.Is(err, scan_manager.ErrInvalidScan) {
+			t.Errorf("expected ErrInvalidScan, got %v", err)
+		}
+
+		if repo.calls != 1 {
+			t.Errorf("expected 1 attempt, got %d", repo.calls)
+		}
+	})
+
+	t.Run("should not sleep past the deadline", func(t *testing.T) {
+		var retries []Class
+		repo := &failingRepo{errs: []error{scan_manager.ErrThrottled}}
+		r, _ := NewRetrier(&RetryConfig{Repo: repo, ThrottleDelay: time.Hour, MaxDelay: time.Hour})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := r.Put(ctx, scan)
+		if !errors.Is(err, scan_manager.ErrThrottled) {
+			t.Errorf("expected ErrThrottled, got %v", err)
+		}
+
+		if repo.calls != 1 || len(retries) != 0 {
+			t.Errorf("expected no retries, got %d calls", repo.calls)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	r, _ := NewRetrier(&RetryConfig{
+		Repo:          &failingRepo{},
+		BaseDelay:     10 * time.Millisecond,
+		ThrottleDelay: 100 * time.Millisecond,
+		MaxDelay:      time.Second,
+	})
+
+	tests := []struct {
+		class   Class
+		attempt int
+		ceiling time.Duration
+	}{
+		{Transient, 1, 10 * time.Millisecond},
+		{Transient, 3, 40 * time.Millisecond},
+		{Throttled, 1, 100 * time.Millisecond},
+		{Throttled, 5, time.Second},
+		{Throttled, 100, time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 100 {
+			delay := r.backoff(tt.class, tt.attempt)
+			if delay < tt.ceiling/2 || delay > tt.ceiling {
+				t.Fatalf("expected %s attempt %d to back off between %v and %v, got %v", tt.class, tt.attempt, tt.ceiling/2, tt.ceiling, delay)
+			}
+		}
+	}
+}
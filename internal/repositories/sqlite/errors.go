@@ -0,0 +1,31 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// classify maps SQLite result codes to scan_manager error classes
+func classify(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	// Extended result codes carry the primary code in the low byte
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		// Another writer held the lock past the busy timeout
+		return fmt.Errorf("%w: %w", scan_manager.ErrThrottled, err)
+	case sqlite3.SQLITE_TOOBIG:
+		return fmt.Errorf("%w: %w", scan_manager.ErrInvalidScan, err)
+	case sqlite3.SQLITE_IOERR:
+		return fmt.Errorf("%w: %w", scan_manager.ErrUnavailable, err)
+	}
+
+	return err
+}
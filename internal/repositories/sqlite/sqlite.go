@@ -0,0 +1,423 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	_ "modernc.org/sqlite"
+)
+
+// InMemory is the SQLiteConfig.Path of a database that lives only as long as
+// the repository
+const InMemory = ":memory:"
+
+// busyTimeoutMillis is how long a write waits for another connection's
+// write, possibly from another process, before failing with SQLITE_BUSY
+const busyTimeoutMillis = 5000
+
+// schema is applied on every open, so each statement must be idempotent. The
+// primary key doubles as the ip index, and every index is ordered so that
+// listings can page in primary key order without sorting.
+const schema = `
+CREATE TABLE IF NOT EXISTS scans (
+	ip           TEXT    NOT NULL,
+	port         INTEGER NOT NULL,
+	service      TEXT    NOT NULL,
+	timestamp    INTEGER NOT NULL,
+	response     TEXT    NOT NULL,
+	data_version INTEGER NOT NULL,
+	PRIMARY KEY (ip, port, service)
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS scans_service_ip_port ON scans (service, ip, port);
+CREATE INDEX IF NOT EXISTS scans_port_ip_service ON scans (port, ip, service);
+
+CREATE TABLE IF NOT EXISTS scan_history (
+	ip           TEXT    NOT NULL,
+	port         INTEGER NOT NULL,
+	service      TEXT    NOT NULL,
+	timestamp    INTEGER NOT NULL,
+	response     TEXT    NOT NULL,
+	data_version INTEGER NOT NULL,
+	stale        INTEGER NOT NULL,
+	PRIMARY KEY (ip, port, service, timestamp)
+) WITHOUT ROWID;
+`
+
+// columns are the scan columns in the order scanResult reads them
+const columns = "ip, port, service, timestamp, response, data_version"
+
+type SQLiteConfig struct {
+	// Path of the database file, created if missing, or InMemory
+	Path string
+	// History keeps every accepted observation, not just the latest
+	History bool
+	// RecordStale also keeps observations that were rejected as stale. Requires History.
+	RecordStale bool
+}
+
+// sqliteDB is a Repository in an embedded SQLite database. Puts run in
+// immediate transactions, so concurrent writers, including other processes
+// sharing the file, are serialized and newer-timestamp-wins holds.
+type sqliteDB struct {
+	db          *sql.DB
+	keepHistory bool
+	recordStale bool
+}
+
+func NewSQLite(cfg *SQLiteConfig) (*sqliteDB, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Path == "" {
+		return nil, errors.New("path is empty")
+	}
+
+	if cfg.RecordStale && !cfg.History {
+		return nil, errors.New("recording stale scans requires history")
+	}
+
+	params := url.Values{}
+	params.Add("_txlock", "immediate")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeoutMillis))
+	if cfg.Path != InMemory {
+		// Readers don't block the writer, or each other
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+
+	db, err := sql.Open("sqlite", "file:"+cfg.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	// Every connection to an in-memory database opens a new, empty one
+	if cfg.Path == InMemory {
+		db.SetMaxOpenConns(1)
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create SQLite schema: %w", classify(err))
+	}
+
+	s := &sqliteDB{
+		db:          db,
+		keepHistory: cfg.History,
+		recordStale: cfg.RecordStale,
+	}
+
+	return s, nil
+}
+
+func (s *sqliteDB) Put(ctx context.Context, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	var put *scan_manager.PutResult
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		put, err = s.put(ctx, tx, result)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return put, nil
+}
+
+// PutBatch stores results in a single transaction, so the batch costs one
+// sync to disk instead of one per scan
+func (s *sqliteDB) PutBatch(ctx context.Context, results []*scan_manager.ScanResult) ([]*scan_manager.PutResult, error) {
+	puts := make([]*scan_manager.PutResult, len(results))
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for i, result := range results {
+			put, err := s.put(ctx, tx, result)
+			if err != nil {
+				return err
+			}
+			puts[i] = put
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return puts, nil
+}
+
+// put reads the stored scan to classify the write, then upserts. The
+// transaction holds the write lock throughout, so the stored scan can't
+// change in between.
+func (s *sqliteDB) put(ctx context.Context, tx *sql.Tx, result *scan_manager.ScanResult) (*scan_manager.PutResult, error) {
+	existing, err := s.get(ctx, tx, result.Key())
+	if err != nil && !errors.Is(err, scan_manager.ErrNotFound) {
+		return nil, err
+	}
+
+	if existing != nil && existing.Timestamp >= result.Timestamp {
+		outcome := scan_manager.RejectedOutcome(existing, result)
+		if outcome == scan_manager.StaleIgnored && s.recordStale {
+			if err := s.putHistory(ctx, tx, result, true); err != nil {
+				return nil, err
+			}
+		}
+
+		return &scan_manager.PutResult{Outcome: outcome, Previous: existing}, nil
+	}
+
+	// Only replace the stored row when the new timestamp is strictly greater
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO scans (`+columns+`) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (ip, port, service) DO UPDATE SET
+			timestamp = excluded.timestamp,
+			response = excluded.response,
+			data_version = excluded.data_version
+		WHERE excluded.timestamp > scans.timestamp`,
+		result.IP, result.Port, result.Service, result.Timestamp, result.Response, result.DataVersion,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert scan: %w", classify(err))
+	}
+
+	if s.keepHistory {
+		if err := s.putHistory(ctx, tx, result, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if existing == nil {
+		return &scan_manager.PutResult{Outcome: scan_manager.Inserted}, nil
+	}
+
+	return &scan_manager.PutResult{Outcome: scan_manager.Updated, Previous: existing}, nil
+}
+
+// putHistory records an observation. A stale observation with the same
+// timestamp as an accepted one must not overwrite the accepted entry.
+func (s *sqliteDB) putHistory(ctx context.Context, tx *sql.Tx, result *scan_manager.ScanResult, stale bool) error {
+	conflict := "REPLACE"
+	if stale {
+		conflict = "IGNORE"
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`INSERT OR `+conflict+` INTO scan_history (`+columns+`, stale) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		result.IP, result.Port, result.Service, result.Timestamp, result.Response, result.DataVersion, stale,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert history: %w", classify(err))
+	}
+
+	return nil
+}
+
+// inTx runs fn in a transaction, committing if it succeeds
+func (s *sqliteDB) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", classify(err))
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", classify(err))
+	}
+
+	return nil
+}
+
+func (s *sqliteDB) History(ctx context.Context, key scan_manager.ScanKey, from, to int64) ([]*scan_manager.HistoryEntry, error) {
+	if !s.keepHistory {
+		return nil, scan_manager.ErrHistoryDisabled
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+columns+`, stale FROM scan_history
+		WHERE ip = ? AND port = ? AND service = ? AND timestamp BETWEEN ? AND ?
+		ORDER BY timestamp`,
+		key.IP, key.Port, key.Service, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var entries []*scan_manager.HistoryEntry
+	for rows.Next() {
+		entry := &scan_manager.HistoryEntry{}
+		err := rows.Scan(
+			&entry.IP, &entry.Port, &entry.Service, &entry.Timestamp, &entry.Response, &entry.DataVersion, &entry.Stale,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", classify(err))
+	}
+
+	return entries, nil
+}
+
+func (s *sqliteDB) Get(ctx context.Context, key scan_manager.ScanKey) (*scan_manager.ScanResult, error) {
+	return s.get(ctx, s.db, key)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *sqliteDB) get(ctx context.Context, q querier, key scan_manager.ScanKey) (*scan_manager.ScanResult, error) {
+	row := q.QueryRowContext(ctx,
+		`SELECT `+columns+` FROM scans WHERE ip = ? AND port = ? AND service = ?`,
+		key.IP, key.Port, key.Service,
+	)
+
+	result, err := scanResult(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, scan_manager.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan: %w", classify(err))
+	}
+
+	return result, nil
+}
+
+func (s *sqliteDB) ListByIP(ctx context.Context, ip string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return s.list(ctx, opts, "ip = ?", ip)
+}
+
+func (s *sqliteDB) ListByService(ctx context.Context, service string, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	return s.list(ctx, opts, "service = ?", service)
+}
+
+func (s *sqliteDB) ListByPort(ctx context.Context, port uint32, opts scan_manager.ListOptions) (*scan_manager.ListPage, error) {
+	opts.Port = 0
+	return s.list(ctx, opts, "port = ?", port)
+}
+
+// pageToken is the key of the last result on the previous page
+type pageToken struct {
+	IP      string `json:"ip"`
+	Port    uint32 `json:"port"`
+	Service string `json:"service"`
+}
+
+// list returns the scans matching condition in primary key order, reading
+// one extra row to tell whether there is another page
+func (s *sqliteDB) list(ctx context.Context, opts scan_manager.ListOptions, condition string, args ...any) (*scan_manager.ListPage, error) {
+	conditions := []string{condition}
+
+	if opts.Port != 0 {
+		conditions = append(conditions, "port = ?")
+		args = append(args, opts.Port)
+	}
+
+	if opts.PageToken != "" {
+		after, err := decodePageToken(opts.PageToken)
+		if err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, "(ip, port, service) > (?, ?, ?)")
+		args = append(args, after.IP, after.Port, after.Service)
+	}
+
+	limit := opts.PageSize()
+	args = append(args, limit+1)
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+columns+` FROM scans WHERE `+strings.Join(conditions, " AND ")+` ORDER BY ip, port, service LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scans: %w", classify(err))
+	}
+	defer rows.Close()
+
+	page := &scan_manager.ListPage{}
+	for rows.Next() {
+		result, err := scanResult(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read scan: %w", err)
+		}
+		page.Results = append(page.Results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list scans: %w", classify(err))
+	}
+
+	if len(page.Results) > limit {
+		page.Results = page.Results[:limit]
+		page.NextPageToken, err = encodePageToken(page.Results[limit-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+func encodePageToken(last *scan_manager.ScanResult) (string, error) {
+	data, err := json.Marshal(pageToken{IP: last.IP, Port: last.Port, Service: last.Service})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode page token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageToken(token string) (*pageToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", scan_manager.ErrInvalidPageToken, err)
+	}
+
+	var after pageToken
+	if err := json.Unmarshal(data, &after); err != nil {
+		return nil, fmt.Errorf("%w: %v", scan_manager.ErrInvalidPageToken, err)
+	}
+
+	return &after, nil
+}
+
+// scanResult reads a row selected with columns
+func scanResult(row interface{ Scan(dest ...any) error }) (*scan_manager.ScanResult, error) {
+	result := &scan_manager.ScanResult{}
+	err := row.Scan(&result.IP, &result.Port, &result.Service, &result.Timestamp, &result.Response, &result.DataVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Ping checks the database can be queried
+func (s *sqliteDB) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping SQLite: %w", classify(err))
+	}
+
+	return nil
+}
+
+// Close closes the database. For a file, it also checkpoints the WAL.
+func (s *sqliteDB) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,196 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/repotest"
+)
+
+func newTestSQLite(t *testing.T, cfg *SQLiteConfig) *sqliteDB {
+	t.Helper()
+
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(t.TempDir(), "scans.db")
+	}
+
+	s, err := NewSQLite(cfg)
+	if err != nil {
+		t.Fatalf("failed to create SQLite repository: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestNewSQLite(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewSQLite(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if path is empty", func(t *testing.T) {
+		_, err := NewSQLite(&SQLiteConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if recording stale scans without history", func(t *testing.T) {
+		_, err := NewSQLite(&SQLiteConfig{Path: InMemory, RecordStale: true})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should keep scans across reopening the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "scans.db")
+		scan := &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: 100, Response: "kept"}
+
+		s := newTestSQLite(t, &SQLiteConfig{Path: path})
+		if _, err := s.Put(context.Background(), scan); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		s.Close()
+
+		reopened := newTestSQLite(t, &SQLiteConfig{Path: path})
+		got, err := reopened.Get(context.Background(), scan.Key())
+		if err != nil || got.Response != "kept" {
+			t.Errorf("expected the stored scan, got %+v, %v", got, err)
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		repotest.Run(t, func(t *testing.T) scan_manager.Repository {
+			return newTestSQLite(t, &SQLiteConfig{History: true, RecordStale: true})
+		})
+	})
+
+	t.Run("in memory", func(t *testing.T) {
+		repotest.Run(t, func(t *testing.T) scan_manager.Repository {
+			return newTestSQLite(t, &SQLiteConfig{Path: InMemory, History: true, RecordStale: true})
+		})
+	})
+}
+
+func TestPut_ConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scans.db")
+
+	// Separate repositories share the file like separate processes would
+	writers := []*sqliteDB{
+		newTestSQLite(t, &SQLiteConfig{Path: path}),
+		newTestSQLite(t, &SQLiteConfig{Path: path}),
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(ts int64) {
+			defer wg.Done()
+			_, err := writers[ts%2].Put(context.Background(), &scan_manager.ScanResult{
+				IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: ts, Response: fmt.Sprintf("response %d", ts),
+			})
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	got, err := writers[0].Get(context.Background(), scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got.Timestamp != 100 || got.Response != "response 100" {
+		t.Errorf("expected the newest scan, got %+v", got)
+	}
+}
+
+func TestPutBatch(t *testing.T) {
+	s := newTestSQLite(t, &SQLiteConfig{})
+	_, _ = s.Put(context.Background(), &scan_manager.ScanResult{IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: 200, Response: "stored"})
+
+	puts, err := s.PutBatch(context.Background(), []*scan_manager.ScanResult{
+		{IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: 100, Response: "stale"},
+		{IP: "10.0.0.1", Port: 80, Service: "HTTP", Timestamp: 100, Response: "new"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(puts) != 2 || puts[0].Outcome != scan_manager.StaleIgnored || puts[1].Outcome != scan_manager.Inserted {
+		t.Errorf("expected [stale_ignored inserted], got %+v", puts)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	key := scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"}
+	put := func(s *sqliteDB, ts int64) {
+		_, err := s.Put(context.Background(), &scan_manager.ScanResult{
+			IP: key.IP, Port: key.Port, Service: key.Service, Timestamp: ts, Response: fmt.Sprintf("response %d", ts),
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	t.Run("should return ErrHistoryDisabled without history", func(t *testing.T) {
+		s := newTestSQLite(t, &SQLiteConfig{})
+
+		_, err := s.History(context.Background(), key, 0, 1000)
+		if !errors.Is(err, scan_manager.ErrHistoryDisabled) {
+			t.Errorf("expected ErrHistoryDisabled, got %v", err)
+		}
+	})
+
+	t.Run("should keep accepted observations in scan order", func(t *testing.T) {
+		s := newTestSQLite(t, &SQLiteConfig{History: true})
+		put(s, 100)
+		put(s, 300)
+		put(s, 200) // stale, dropped
+
+		entries, err := s.History(context.Background(), key, 0, 1000)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(entries) != 2 || entries[0].Timestamp != 100 || entries[1].Timestamp != 300 {
+			t.Errorf("expected timestamps [100 300], got %+v", entries)
+		}
+	})
+
+	t.Run("should record stale observations when enabled", func(t *testing.T) {
+		s := newTestSQLite(t, &SQLiteConfig{History: true, RecordStale: true})
+		put(s, 100)
+		put(s, 300)
+		put(s, 200)
+		put(s, 300) // duplicate, must not mark the accepted entry stale
+
+		entries, err := s.History(context.Background(), key, 150, 1000)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(entries) != 2 {
+			t.Fatalf("expected 2 entries, got %+v", entries)
+		}
+
+		if entries[0].Timestamp != 200 || !entries[0].Stale {
+			t.Errorf("expected stale entry at 200, got %+v", entries[0])
+		}
+
+		if entries[1].Timestamp != 300 || entries[1].Stale {
+			t.Errorf("expected accepted entry at 300, got %+v", entries[1])
+		}
+	})
+}
@@ -4,47 +4,56 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 
 	"github.com/censys/scan-takehome/internal/managers/scan_manager"
 	"github.com/censys/scan-takehome/pkg/scanning"
 )
 
+// ErrInvalidMessage marks a message that can never be processed, no matter
+// how many times it is redelivered. Every error from ParseScanMessage wraps it.
+var ErrInvalidMessage = errors.New("invalid scan message")
+
 // ParseScanMessage deserializes and normalizes scan data from raw bytes
 // Handles both V1 (base64 encoded) and V2 (plain string) data formats
 func ParseScanMessage(data []byte) (*scan_manager.ScanResult, error) {
 	var scan scanning.Scan
 	if err := json.Unmarshal(data, &scan); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal scan data: %w", err)
+		return nil, fmt.Errorf("%w: failed to unmarshal scan data: %w", ErrInvalidMessage, err)
+	}
+
+	if err := validate(&scan); err != nil {
+		return nil, err
 	}
 
 	if scan.Data == nil {
-		return nil, errors.New("scan data is nil")
+		return nil, fmt.Errorf("%w: scan data is nil", ErrInvalidMessage)
 	}
 
 	var response string
 
 	dataBytes, err := json.Marshal(scan.Data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal data: %w", err)
+		return nil, fmt.Errorf("%w: failed to marshal data: %w", ErrInvalidMessage, err)
 	}
 
 	switch scan.DataVersion {
 	case scanning.V1:
 		var v1 scanning.V1Data
 		if err := json.Unmarshal(dataBytes, &v1); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal V1 data: %w", err)
+			return nil, fmt.Errorf("%w: failed to unmarshal V1 data: %w", ErrInvalidMessage, err)
 		}
 		response = string(v1.ResponseBytesUtf8)
 
 	case scanning.V2:
 		var v2 scanning.V2Data
 		if err := json.Unmarshal(dataBytes, &v2); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal V2 data: %w", err)
+			return nil, fmt.Errorf("%w: failed to unmarshal V2 data: %w", ErrInvalidMessage, err)
 		}
 		response = v2.ResponseStr
 
 	default:
-		return nil, fmt.Errorf("unknown data version: %d", scan.DataVersion)
+		return nil, fmt.Errorf("%w: unknown data version: %d", ErrInvalidMessage, scan.DataVersion)
 	}
 
 	result := &scan_manager.ScanResult{
@@ -58,3 +67,24 @@ func ParseScanMessage(data []byte) (*scan_manager.ScanResult, error) {
 
 	return result, nil
 }
+
+// validate rejects scans whose key fields can't be stored
+func validate(scan *scanning.Scan) error {
+	if net.ParseIP(scan.Ip) == nil {
+		return fmt.Errorf("%w: invalid ip %q", ErrInvalidMessage, scan.Ip)
+	}
+
+	if scan.Port > 65535 {
+		return fmt.Errorf("%w: invalid port %d", ErrInvalidMessage, scan.Port)
+	}
+
+	if scan.Service == "" {
+		return fmt.Errorf("%w: service is empty", ErrInvalidMessage)
+	}
+
+	if scan.Timestamp <= 0 {
+		return fmt.Errorf("%w: invalid timestamp %d", ErrInvalidMessage, scan.Timestamp)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package serializer
 
 import (
 	"encoding/base64"
+	"errors"
 	"testing"
 
 	"github.com/censys/scan-takehome/pkg/scanning"
@@ -106,3 +107,28 @@ func TestParseScanMessage_InvalidJSON(t *testing.T) {
 		t.Fatal("Expected error for invalid JSON, got nil")
 	}
 }
+
+func TestParseScanMessage_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"invalid json", `{"invalid json`},
+		{"nil data", `{"ip": "10.0.0.1", "port": 80, "service": "http", "timestamp": 1, "data_version": 2, "data": null}`},
+		{"unknown data version", `{"ip": "10.0.0.1", "port": 80, "service": "http", "timestamp": 1, "data_version": 7, "data": {}}`},
+		{"invalid ip", `{"ip": "not-an-ip", "port": 80, "service": "http", "timestamp": 1, "data_version": 2, "data": {"response_str": "x"}}`},
+		{"invalid port", `{"ip": "10.0.0.1", "port": 70000, "service": "http", "timestamp": 1, "data_version": 2, "data": {"response_str": "x"}}`},
+		{"empty service", `{"ip": "10.0.0.1", "port": 80, "service": "", "timestamp": 1, "data_version": 2, "data": {"response_str": "x"}}`},
+		{"missing timestamp", `{"ip": "10.0.0.1", "port": 80, "service": "http", "data_version": 2, "data": {"response_str": "x"}}`},
+		{"wrong V1 data type", `{"ip": "10.0.0.1", "port": 80, "service": "http", "timestamp": 1, "data_version": 1, "data": {"response_bytes_utf8": 5}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseScanMessage([]byte(tt.data))
+			if !errors.Is(err, ErrInvalidMessage) {
+				t.Errorf("Expected ErrInvalidMessage, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// Stdin is the FileSourceConfig.Path that reads standard input
+	Stdin = "-"
+
+	DefaultMaxDeliveries = 5
+)
+
+type FileSourceConfig struct {
+	// Path of the JSONL file, or Stdin
+	Path string
+	// Concurrency is how many messages are handled at once. Defaults to 1.
+	Concurrency int
+	// MaxDeliveries is how many times a message is delivered before a Nack
+	// drops it. Defaults to DefaultMaxDeliveries.
+	MaxDeliveries int
+}
+
+// fileSource replays messages from a JSONL file. A line is either a captured
+// message, such as a dead letter, whose "data" is the base64 encoded payload,
+// or a raw payload as the scanner publishes it. Nacked messages are
+// redelivered ahead of the rest of the file.
+type fileSource struct {
+	name          string
+	file          io.Closer
+	reader        *bufio.Reader
+	concurrency   int
+	maxDeliveries int
+
+	start     sync.Once
+	lines     chan *delivery
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu          sync.Mutex
+	redeliver   []*delivery
+	outstanding int
+	dropped     int
+	exhausted   bool
+	readErr     error
+	// changed is closed and replaced whenever a message is settled or the
+	// file is exhausted, to wake idle workers
+	changed chan struct{}
+}
+
+// delivery is a message read from the file and how often it has been delivered
+type delivery struct {
+	id          string
+	data        []byte
+	attributes  map[string]string
+	publishTime time.Time
+	attempts    int
+}
+
+// envelope is a captured message. It matches deadletter.Letter, so
+// dead-letter files can be replayed.
+type envelope struct {
+	MessageID   string            `json:"message_id"`
+	Data        json.RawMessage   `json:"data"`
+	Attributes  map[string]string `json:"attributes"`
+	PublishTime time.Time         `json:"publish_time"`
+}
+
+func NewFileSource(cfg *FileSourceConfig) (*fileSource, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Path == "" {
+		return nil, errors.New("path is empty")
+	}
+
+	f := &fileSource{
+		concurrency:   cfg.Concurrency,
+		maxDeliveries: cfg.MaxDeliveries,
+		lines:         make(chan *delivery),
+		closed:        make(chan struct{}),
+		changed:       make(chan struct{}),
+	}
+
+	if cfg.Path == Stdin {
+		f.name = "stdin"
+		f.file = io.NopCloser(os.Stdin)
+		f.reader = bufio.NewReader(os.Stdin)
+	} else {
+		file, err := os.Open(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open source file: %w", err)
+		}
+
+		f.name = filepath.Base(cfg.Path)
+		f.file = file
+		f.reader = bufio.NewReader(file)
+	}
+
+	if f.concurrency <= 0 {
+		f.concurrency = 1
+	}
+
+	if f.maxDeliveries <= 0 {
+		f.maxDeliveries = DefaultMaxDeliveries
+	}
+
+	return f, nil
+}
+
+// Receive returns nil once every line has been acked or dropped, or when ctx
+// ends. It reports read errors and dropped messages.
+func (f *fileSource) Receive(ctx context.Context, handler func(context.Context, *Message)) error {
+	f.start.Do(func() { go f.read() })
+
+	var wg sync.WaitGroup
+	for range f.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				d, ok := f.next(ctx)
+				if !ok {
+					return
+				}
+
+				handler(ctx, f.message(d))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.readErr != nil {
+		return fmt.Errorf("failed to read %s: %w", f.name, f.readErr)
+	}
+
+	if f.dropped > 0 {
+		return fmt.Errorf("dropped %d messages nacked %d times", f.dropped, f.maxDeliveries)
+	}
+
+	return nil
+}
+
+// Ping always succeeds, the file was opened by NewFileSource
+func (f *fileSource) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (f *fileSource) Close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		close(f.closed)
+		err = f.file.Close()
+	})
+
+	return err
+}
+
+// next returns the next delivery, preferring redeliveries. It reports false
+// once ctx ends, or the file is exhausted and every message is settled.
+func (f *fileSource) next(ctx context.Context) (*delivery, bool) {
+	for {
+		if ctx.Err() != nil {
+			return nil, false
+		}
+
+		f.mu.Lock()
+		if len(f.redeliver) > 0 {
+			d := f.redeliver[0]
+			f.redeliver = f.redeliver[1:]
+			f.outstanding++
+			f.mu.Unlock()
+			return d, true
+		}
+
+		done := f.exhausted && f.outstanding == 0
+		changed := f.changed
+		f.mu.Unlock()
+
+		if done {
+			return nil, false
+		}
+
+		select {
+		case d := <-f.lines:
+			f.mu.Lock()
+			f.outstanding++
+			f.mu.Unlock()
+			return d, true
+		case <-changed:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+func (f *fileSource) message(d *delivery) *Message {
+	d.attempts++
+
+	return &Message{
+		ID:          d.id,
+		Data:        d.data,
+		Attributes:  d.attributes,
+		PublishTime: d.publishTime,
+		settle:      func(ack bool) { f.settle(d, ack) },
+	}
+}
+
+func (f *fileSource) settle(d *delivery, ack bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.outstanding--
+	if !ack {
+		if d.attempts < f.maxDeliveries {
+			f.redeliver = append(f.redeliver, d)
+		} else {
+			f.dropped++
+		}
+	}
+
+	f.broadcast()
+}
+
+// read hands each line to a worker as it asks for one, so a Receive stopped
+// by its context leaves the rest of the file for the next
+func (f *fileSource) read() {
+	for n := 1; ; n++ {
+		line, err := f.reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			select {
+			case f.lines <- f.parse(line, n):
+			case <-f.closed:
+				return
+			}
+		}
+
+		if err != nil {
+			f.mu.Lock()
+			if !errors.Is(err, io.EOF) {
+				f.readErr = err
+			}
+			f.exhausted = true
+			f.broadcast()
+			f.mu.Unlock()
+			return
+		}
+	}
+}
+
+// parse reads line n as an envelope when its data is a base64 string, and as
+// a raw payload otherwise, including when it isn't valid JSON
+func (f *fileSource) parse(line []byte, n int) *delivery {
+	var env envelope
+	if err := json.Unmarshal(line, &env); err == nil && len(env.Data) > 0 && env.Data[0] == '"' {
+		var data []byte
+		if err := json.Unmarshal(env.Data, &data); err == nil {
+			id := env.MessageID
+			if id == "" {
+				id = fmt.Sprintf("%s:%d", f.name, n)
+			}
+
+			return &delivery{id: id, data: data, attributes: env.Attributes, publishTime: env.PublishTime}
+		}
+	}
+
+	return &delivery{id: fmt.Sprintf("%s:%d", f.name, n), data: line}
+}
+
+// broadcast wakes idle workers. Callers must hold mu.
+func (f *fileSource) broadcast() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
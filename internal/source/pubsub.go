@@ -0,0 +1,69 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+)
+
+type PubSubSourceConfig struct {
+	// Subscription's ReceiveSettings control concurrency and flow control
+	Subscription *pubsub.Subscription
+}
+
+// pubSubSource receives from a Pub/Sub subscription, which redelivers nacked
+// messages and messages left unsettled when Receive stops
+type pubSubSource struct {
+	sub *pubsub.Subscription
+}
+
+func NewPubSubSource(cfg *PubSubSourceConfig) (*pubSubSource, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.Subscription == nil {
+		return nil, errors.New("subscription is nil")
+	}
+
+	return &pubSubSource{sub: cfg.Subscription}, nil
+}
+
+func (p *pubSubSource) Receive(ctx context.Context, handler func(context.Context, *Message)) error {
+	return p.sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		handler(ctx, &Message{
+			ID:          msg.ID,
+			Data:        msg.Data,
+			Attributes:  msg.Attributes,
+			PublishTime: msg.PublishTime,
+			settle: func(ack bool) {
+				if ack {
+					msg.Ack()
+				} else {
+					msg.Nack()
+				}
+			},
+		})
+	})
+}
+
+// Ping checks the subscription exists
+func (p *pubSubSource) Ping(ctx context.Context) error {
+	exists, err := p.sub.Exists(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("subscription %s does not exist", p.sub.ID())
+	}
+
+	return nil
+}
+
+// Close is a no-op, the client owning the subscription is closed by its creator
+func (p *pubSubSource) Close() error {
+	return nil
+}
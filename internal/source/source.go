@@ -0,0 +1,40 @@
+package source
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Source delivers messages to the consumer pipeline
+type Source interface {
+	// Receive calls handler concurrently for each message until ctx ends,
+	// the source is exhausted or it fails. The handler must Ack or Nack every
+	// message. Receive can be called again after ctx ends to resume.
+	Receive(ctx context.Context, handler func(context.Context, *Message)) error
+	// Ping reports whether the source is reachable
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Message is a single delivery from a Source
+type Message struct {
+	ID         string
+	Data       []byte
+	Attributes map[string]string
+	// PublishTime is zero when the source doesn't know it
+	PublishTime time.Time
+
+	once   sync.Once
+	settle func(ack bool)
+}
+
+// Ack marks the message as processed. Only the first Ack or Nack counts.
+func (m *Message) Ack() {
+	m.once.Do(func() { m.settle(true) })
+}
+
+// Nack asks for the message to be redelivered. Only the first Ack or Nack counts.
+func (m *Message) Nack() {
+	m.once.Do(func() { m.settle(false) })
+}
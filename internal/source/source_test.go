@@ -0,0 +1,282 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func writeFile(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("failed to write capture: %v", err)
+	}
+
+	return path
+}
+
+// received collects handled messages
+type received struct {
+	mu   sync.Mutex
+	msgs []*Message
+}
+
+func (r *received) add(msg *Message) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.msgs = append(r.msgs, msg)
+	return len(r.msgs)
+}
+
+func (r *received) ids() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, len(r.msgs))
+	for i, msg := range r.msgs {
+		ids[i] = msg.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestNewFileSource(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewFileSource(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if path is empty", func(t *testing.T) {
+		_, err := NewFileSource(&FileSourceConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if the file doesn't exist", func(t *testing.T) {
+		_, err := NewFileSource(&FileSourceConfig{Path: filepath.Join(t.TempDir(), "missing.jsonl")})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestFileSource_Receive(t *testing.T) {
+	t.Run("should deliver raw payloads and captured messages", func(t *testing.T) {
+		path := writeFile(t,
+			`{"ip":"1.1.1.1","port":22,"service":"SSH","timestamp":100,"data_version":2,"data":{"response_str":"ok"}}`,
+			``,
+			`{"message_id":"msg-7","data":"eyJpbnZhbGlkIGpzb24=","attributes":{"fault":"truncated"},"publish_time":"2025-01-01T00:00:00Z","error":"invalid"}`,
+			`{"ip":"1.1.1.2"`,
+		)
+
+		src, err := NewFileSource(&FileSourceConfig{Path: path, Concurrency: 3})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		defer src.Close()
+
+		byID := map[string]*Message{}
+		var mu sync.Mutex
+		err = src.Receive(context.Background(), func(ctx context.Context, msg *Message) {
+			mu.Lock()
+			byID[msg.ID] = msg
+			mu.Unlock()
+			msg.Ack()
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(byID) != 3 {
+			t.Fatalf("expected 3 messages, got %v", byID)
+		}
+
+		if raw := byID["capture.jsonl:1"]; raw == nil || !strings.HasPrefix(string(raw.Data), `{"ip":"1.1.1.1"`) || !raw.PublishTime.IsZero() {
+			t.Errorf("expected the raw payload on line 1, got %+v", raw)
+		}
+
+		captured := byID["msg-7"]
+		if captured == nil || string(captured.Data) != `{"invalid json` || captured.Attributes["fault"] != "truncated" {
+			t.Errorf("expected the captured message to be decoded, got %+v", captured)
+		}
+
+		if captured != nil && !captured.PublishTime.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("expected the captured publish time, got %v", captured.PublishTime)
+		}
+
+		if truncated := byID["capture.jsonl:4"]; truncated == nil || string(truncated.Data) != `{"ip":"1.1.1.2"` {
+			t.Errorf("expected invalid JSON to be delivered as is, got %+v", truncated)
+		}
+	})
+
+	t.Run("should redeliver nacked messages", func(t *testing.T) {
+		src, _ := NewFileSource(&FileSourceConfig{Path: writeFile(t, `a`, `b`)})
+		defer src.Close()
+
+		var r received
+		err := src.Receive(context.Background(), func(ctx context.Context, msg *Message) {
+			if r.add(msg) == 1 {
+				msg.Nack()
+				return
+			}
+			msg.Ack()
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if got := r.ids(); len(got) != 3 {
+			t.Errorf("expected the nacked message to be redelivered once, got %v", got)
+		}
+	})
+
+	t.Run("should drop messages nacked too often", func(t *testing.T) {
+		src, _ := NewFileSource(&FileSourceConfig{Path: writeFile(t, `a`, `b`), MaxDeliveries: 3})
+		defer src.Close()
+
+		var r received
+		err := src.Receive(context.Background(), func(ctx context.Context, msg *Message) {
+			r.add(msg)
+			if string(msg.Data) == "a" {
+				msg.Nack()
+				return
+			}
+			msg.Ack()
+		})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+
+		if got := r.ids(); len(got) != 4 {
+			t.Errorf("expected 3 deliveries of a and 1 of b, got %v", got)
+		}
+	})
+
+	t.Run("should resume after the context ends", func(t *testing.T) {
+		src, _ := NewFileSource(&FileSourceConfig{Path: writeFile(t, `a`, `b`, `c`)})
+		defer src.Close()
+
+		var r received
+		ctx, cancel := context.WithCancel(context.Background())
+		err := src.Receive(ctx, func(ctx context.Context, msg *Message) {
+			r.add(msg)
+			// Stopped mid-message, like a tripped circuit breaker
+			cancel()
+			msg.Nack()
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		err = src.Receive(context.Background(), func(ctx context.Context, msg *Message) {
+			r.add(msg)
+			msg.Ack()
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expected := []string{"capture.jsonl:1", "capture.jsonl:1", "capture.jsonl:2", "capture.jsonl:3"}
+		if got := r.ids(); strings.Join(got, ",") != strings.Join(expected, ",") {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	})
+}
+
+func TestNewPubSubSource(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewPubSubSource(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if subscription is nil", func(t *testing.T) {
+		_, err := NewPubSubSource(&PubSubSourceConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestPubSubSource_Receive(t *testing.T) {
+	ctx := context.Background()
+
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial pstest: %v", err)
+	}
+	defer conn.Close()
+
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	topic, err := client.CreateTopic(ctx, "scan-topic")
+	if err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+	defer topic.Stop()
+
+	sub, err := client.CreateSubscription(ctx, "scan-sub", pubsub.SubscriptionConfig{Topic: topic})
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	src, err := NewPubSubSource(&PubSubSourceConfig{Subscription: sub})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := src.Ping(ctx); err != nil {
+		t.Errorf("expected the subscription to exist, got %v", err)
+	}
+
+	missing, _ := NewPubSubSource(&PubSubSourceConfig{Subscription: client.Subscription("missing")})
+	if err := missing.Ping(ctx); err == nil {
+		t.Errorf("expected error for a missing subscription, got nil")
+	}
+
+	if _, err := topic.Publish(ctx, &pubsub.Message{Data: []byte("scan"), Attributes: map[string]string{"fault": "duplicate"}}).Get(ctx); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	receiveCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var got *Message
+	err = src.Receive(receiveCtx, func(ctx context.Context, msg *Message) {
+		got = msg
+		msg.Ack()
+		cancel()
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got == nil || string(got.Data) != "scan" || got.Attributes["fault"] != "duplicate" || got.PublishTime.IsZero() {
+		t.Errorf("expected the published message, got %+v", got)
+	}
+}
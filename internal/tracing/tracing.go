@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+const (
+	// None leaves the global no-op tracer in place. Trace context is still
+	// propagated, so logs carry the IDs of traces started upstream.
+	None = "none"
+	// Stdout writes finished spans as JSON, for local use
+	Stdout = "stdout"
+	// OTLP exports over gRPC, configured with the standard OTEL_EXPORTER_OTLP_* environment variables
+	OTLP = "otlp"
+)
+
+// Propagator carries W3C trace context and baggage between processes
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+type ProviderConfig struct {
+	ServiceName string
+	// Exporter is None, Stdout or OTLP. Defaults to None.
+	Exporter string
+	// Output is where the Stdout exporter writes, defaults to os.Stdout
+	Output io.Writer
+	// SampleRatio is the fraction of new traces recorded, traces started
+	// upstream follow the upstream decision. Defaults to recording all.
+	SampleRatio float64
+}
+
+// Setup installs a tracer provider and Propagator globally. The returned
+// func flushes buffered spans and must be called before the process exits.
+func Setup(ctx context.Context, cfg *ProviderConfig) (func(context.Context) error, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.ServiceName == "" {
+		return nil, errors.New("service name is empty")
+	}
+
+	otel.SetTextMapPropagator(Propagator)
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch cfg.Exporter {
+	case "", None:
+		return func(context.Context) error { return nil }, nil
+	case Stdout:
+		output := cfg.Output
+		if output == nil {
+			output = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(output))
+	case OTLP:
+		exporter, err = otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %s", cfg.Exporter)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Inject writes the trace context of ctx into attrs, e.g. Pub/Sub message
+// attributes. attrs must not be nil.
+func Inject(ctx context.Context, attrs map[string]string) {
+	Propagator.Inject(ctx, propagation.MapCarrier(attrs))
+}
+
+// Extract returns ctx with the trace context carried in attrs, if any
+func Extract(ctx context.Context, attrs map[string]string) context.Context {
+	return Propagator.Extract(ctx, propagation.MapCarrier(attrs))
+}
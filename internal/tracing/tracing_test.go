@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := Setup(context.Background(), nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error if service name is empty", func(t *testing.T) {
+		_, err := Setup(context.Background(), &ProviderConfig{})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should return error for an unknown exporter", func(t *testing.T) {
+		_, err := Setup(context.Background(), &ProviderConfig{ServiceName: "test", Exporter: "zipkin"})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("should export spans to stdout", func(t *testing.T) {
+		var buf bytes.Buffer
+		shutdown, err := Setup(context.Background(), &ProviderConfig{
+			ServiceName: "test",
+			Exporter:    Stdout,
+			Output:      &buf,
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		_, span := otel.Tracer("test").Start(context.Background(), "process scan message")
+		span.End()
+
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		for _, want := range []string{`"Name":"process scan message"`, `"Value":"test"`} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("expected %s in %s", want, buf.String())
+			}
+		}
+	})
+}
+
+func TestPropagation(t *testing.T) {
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	attrs := map[string]string{}
+	Inject(trace.ContextWithSpanContext(context.Background(), parent), attrs)
+
+	if attrs["traceparent"] == "" {
+		t.Fatalf("expected a traceparent attribute, got %v", attrs)
+	}
+
+	got := trace.SpanContextFromContext(Extract(context.Background(), attrs))
+	if got.TraceID() != parent.TraceID() || got.SpanID() != parent.SpanID() || !got.IsRemote() {
+		t.Errorf("expected the remote parent %v, got %v", parent, got)
+	}
+
+	t.Run("should ignore messages without trace context", func(t *testing.T) {
+		if span := trace.SpanContextFromContext(Extract(context.Background(), nil)); span.IsValid() {
+			t.Errorf("expected no span context, got %v", span)
+		}
+	})
+}
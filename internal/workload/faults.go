@@ -0,0 +1,200 @@
+package workload
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/censys/scan-takehome/pkg/scanning"
+)
+
+// FaultAttribute is the message attribute naming the fault injected into a
+// message. Valid scans don't carry it.
+const FaultAttribute = "fault"
+
+const (
+	// FaultBackdated repeats the key of a recent scan with an older timestamp, it is stale on arrival
+	FaultBackdated = "backdated"
+	// FaultDuplicate resends a recent message byte for byte, like a redelivery
+	FaultDuplicate = "duplicate"
+	// FaultConflict repeats the key and timestamp of a recent scan with a different response
+	FaultConflict = "conflict"
+	// FaultUnknownVersion sends a data_version the consumer doesn't know
+	FaultUnknownVersion = "unknown_version"
+	// FaultNullData sends "data": null
+	FaultNullData = "null_data"
+	// FaultTruncated cuts the JSON short
+	FaultTruncated = "truncated"
+	// FaultOversize sends a valid scan with a response larger than a DynamoDB item
+	FaultOversize = "oversize"
+)
+
+const (
+	// DefaultOversizeBytes is over DynamoDB's 400 KB item limit and under Pub/Sub's 10 MB message limit
+	DefaultOversizeBytes = 512 << 10
+
+	// maxBackdate bounds how far before the scan it repeats a backdated scan is
+	maxBackdate = 3600
+
+	// recentSize is how many valid messages are kept to derive faults from
+	recentSize = 100
+)
+
+// FaultConfig sets the percent of messages carrying each fault. The
+// percents must total at most 100, the remaining messages are valid scans.
+type FaultConfig struct {
+	Backdated      float64
+	Duplicate      float64
+	Conflict       float64
+	UnknownVersion float64
+	NullData       float64
+	Truncated      float64
+	Oversize       float64
+	// OversizeBytes is the response size of oversize scans. Defaults to DefaultOversizeBytes.
+	OversizeBytes int
+}
+
+// weights returns each fault with its percent, in a fixed order so a seed
+// always picks the same faults
+func (c *FaultConfig) weights() []faultWeight {
+	return []faultWeight{
+		{FaultBackdated, c.Backdated},
+		{FaultDuplicate, c.Duplicate},
+		{FaultConflict, c.Conflict},
+		{FaultUnknownVersion, c.UnknownVersion},
+		{FaultNullData, c.NullData},
+		{FaultTruncated, c.Truncated},
+		{FaultOversize, c.Oversize},
+	}
+}
+
+type faultWeight struct {
+	fault   string
+	percent float64
+}
+
+func (c *FaultConfig) validate() error {
+	var total float64
+	for _, w := range c.weights() {
+		if w.percent < 0 || w.percent > 100 {
+			return fmt.Errorf("%s fault percent must be between 0 and 100, got %v", w.fault, w.percent)
+		}
+		total += w.percent
+	}
+
+	if total > 100 {
+		return fmt.Errorf("fault percents total %v, over 100", total)
+	}
+
+	return nil
+}
+
+// Message is an encoded scan ready to publish
+type Message struct {
+	Data []byte
+	// Fault is the injected fault, empty for a valid scan
+	Fault string
+}
+
+// recentMessage is a valid message faults can be derived from
+type recentMessage struct {
+	scan *scanning.Scan
+	data []byte
+}
+
+// NextMessage returns the next encoded message observed at timestamp, with a
+// fault injected at the configured rates. Faults derived from earlier scans
+// are skipped until a valid one has been sent. It is not safe for concurrent use.
+func (g *generator) NextMessage(timestamp int64) (*Message, error) {
+	fault := g.pickFault()
+
+	var prev *recentMessage
+	if fault == FaultBackdated || fault == FaultDuplicate || fault == FaultConflict {
+		if len(g.recent) == 0 {
+			fault = ""
+		} else {
+			prev = &g.recent[g.rng.Intn(len(g.recent))]
+		}
+	}
+
+	if fault == FaultBackdated && prev.scan.Timestamp <= 1 {
+		fault = ""
+	}
+
+	if fault == FaultDuplicate {
+		return &Message{Data: prev.data, Fault: fault}, nil
+	}
+
+	scan := g.Next(timestamp)
+
+	switch fault {
+	case FaultBackdated:
+		scan.Ip, scan.Port, scan.Service = prev.scan.Ip, prev.scan.Port, prev.scan.Service
+		scan.Timestamp = max(prev.scan.Timestamp-1-g.rng.Int63n(maxBackdate), 1)
+	case FaultConflict:
+		scan.Ip, scan.Port, scan.Service = prev.scan.Ip, prev.scan.Port, prev.scan.Service
+		scan.Timestamp = prev.scan.Timestamp
+		setResponse(scan, fmt.Sprintf("conflicting response: %d", g.rng.Intn(100)))
+	case FaultUnknownVersion:
+		scan.DataVersion = scanning.V2 + 1 + g.rng.Intn(10)
+	case FaultNullData:
+		scan.Data = nil
+	case FaultOversize:
+		setResponse(scan, strings.Repeat("x", g.oversizeBytes))
+	}
+
+	data, err := json.Marshal(scan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode scan: %w", err)
+	}
+
+	switch fault {
+	case FaultTruncated:
+		data = data[:1+g.rng.Intn(len(data)-1)]
+	case "":
+		if g.faulty {
+			g.remember(scan, data)
+		}
+	}
+
+	return &Message{Data: data, Fault: fault}, nil
+}
+
+// pickFault rolls the fault for the next message. The roll is skipped when
+// no faults are configured, so fault-free runs match Next for a seed.
+func (g *generator) pickFault() string {
+	if !g.faulty {
+		return ""
+	}
+
+	roll := g.rng.Float64() * 100
+	for _, w := range g.faults.weights() {
+		if roll < w.percent {
+			return w.fault
+		}
+		roll -= w.percent
+	}
+
+	return ""
+}
+
+// remember keeps the last recentSize valid messages
+func (g *generator) remember(scan *scanning.Scan, data []byte) {
+	msg := recentMessage{scan: scan, data: data}
+	if len(g.recent) < recentSize {
+		g.recent = append(g.recent, msg)
+		return
+	}
+
+	g.recent[g.recentNext] = msg
+	g.recentNext = (g.recentNext + 1) % recentSize
+}
+
+// setResponse replaces the response, keeping the scan's data version
+func setResponse(scan *scanning.Scan, response string) {
+	if scan.DataVersion == scanning.V2 {
+		scan.Data = &scanning.V2Data{ResponseStr: response}
+	} else {
+		scan.Data = &scanning.V1Data{ResponseBytesUtf8: []byte(response)}
+	}
+}
@@ -0,0 +1,263 @@
+package workload
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/censys/scan-takehome/pkg/scanning"
+)
+
+const (
+	DefaultIPs     = "1.1.1.0/24"
+	DefaultPorts   = "1-65535"
+	DefaultV2Ratio = 0.5
+)
+
+// DefaultServices are the services scanned when GeneratorConfig.Services is empty
+var DefaultServices = []string{"HTTP", "SSH", "DNS"}
+
+type GeneratorConfig struct {
+	// IPs is an IPv4 CIDR such as 1.1.1.0/24 or an inclusive range such as
+	// 10.0.0.1-10.0.0.50. Defaults to DefaultIPs.
+	IPs string
+	// Ports is a comma separated list of ports and inclusive port ranges
+	// such as 22,80,8000-8100. Defaults to DefaultPorts.
+	Ports string
+	// Services defaults to DefaultServices
+	Services []string
+	// V2Ratio is the fraction of scans encoded as V2, the rest are V1
+	V2Ratio float64
+	// Seed makes the generated scans reproducible. Zero picks a random seed.
+	Seed int64
+	// Faults injects invalid and out-of-order messages into NextMessage
+	Faults FaultConfig
+}
+
+// generator produces random scans from a seeded source, so the same config
+// and seed always produce the same sequence
+type generator struct {
+	rng      *rand.Rand
+	seed     int64
+	ips      ipRange
+	ports    []portRange
+	numPorts int
+	services []string
+	v2Ratio  float64
+
+	faults        FaultConfig
+	faulty        bool
+	oversizeBytes int
+	recent        []recentMessage
+	recentNext    int
+}
+
+type ipRange struct {
+	first uint32
+	size  uint64
+}
+
+type portRange struct {
+	first uint32
+	size  int
+}
+
+func NewGenerator(cfg *GeneratorConfig) (*generator, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	if cfg.V2Ratio < 0 || cfg.V2Ratio > 1 {
+		return nil, fmt.Errorf("v2 ratio must be between 0 and 1, got %v", cfg.V2Ratio)
+	}
+
+	if err := cfg.Faults.validate(); err != nil {
+		return nil, err
+	}
+
+	ipSpec := cfg.IPs
+	if ipSpec == "" {
+		ipSpec = DefaultIPs
+	}
+
+	ips, err := parseIPs(ipSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	portSpec := cfg.Ports
+	if portSpec == "" {
+		portSpec = DefaultPorts
+	}
+
+	ports, err := parsePorts(portSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	services := cfg.Services
+	if len(services) == 0 {
+		services = DefaultServices
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	g := &generator{
+		rng:      rand.New(rand.NewSource(seed)),
+		seed:     seed,
+		ips:      ips,
+		ports:    ports,
+		services: services,
+		v2Ratio:  cfg.V2Ratio,
+
+		faults:        cfg.Faults,
+		oversizeBytes: cfg.Faults.OversizeBytes,
+	}
+
+	for _, w := range cfg.Faults.weights() {
+		g.faulty = g.faulty || w.percent > 0
+	}
+
+	if g.oversizeBytes <= 0 {
+		g.oversizeBytes = DefaultOversizeBytes
+	}
+
+	for _, r := range ports {
+		g.numPorts += r.size
+	}
+
+	return g, nil
+}
+
+// Seed returns the seed in use, log it to reproduce a run with a random seed
+func (g *generator) Seed() int64 {
+	return g.seed
+}
+
+// Next returns a random scan observed at timestamp. It is not safe for
+// concurrent use.
+func (g *generator) Next(timestamp int64) *scanning.Scan {
+	var ip [4]byte
+	binary.BigEndian.PutUint32(ip[:], g.ips.first+uint32(g.rng.Int63n(int64(g.ips.size))))
+
+	scan := &scanning.Scan{
+		Ip:        netip.AddrFrom4(ip).String(),
+		Port:      g.port(g.rng.Intn(g.numPorts)),
+		Service:   g.services[g.rng.Intn(len(g.services))],
+		Timestamp: timestamp,
+	}
+
+	serviceResp := fmt.Sprintf("service response: %d", g.rng.Intn(100))
+
+	if g.rng.Float64() < g.v2Ratio {
+		scan.DataVersion = scanning.V2
+		scan.Data = &scanning.V2Data{ResponseStr: serviceResp}
+	} else {
+		scan.DataVersion = scanning.V1
+		scan.Data = &scanning.V1Data{ResponseBytesUtf8: []byte(serviceResp)}
+	}
+
+	return scan
+}
+
+// port returns the nth port across all the port ranges
+func (g *generator) port(n int) uint32 {
+	for _, r := range g.ports {
+		if n < r.size {
+			return r.first + uint32(n)
+		}
+		n -= r.size
+	}
+
+	panic("port index out of range")
+}
+
+func parseIPs(spec string) (ipRange, error) {
+	if prefix, err := netip.ParsePrefix(spec); err == nil {
+		if !prefix.Addr().Is4() {
+			return ipRange{}, fmt.Errorf("only IPv4 CIDRs are supported: %s", spec)
+		}
+
+		first := prefix.Masked().Addr().As4()
+		return ipRange{
+			first: binary.BigEndian.Uint32(first[:]),
+			size:  1 << (32 - prefix.Bits()),
+		}, nil
+	}
+
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		to = from
+	}
+
+	first, err := parseIPv4(from)
+	if err != nil {
+		return ipRange{}, err
+	}
+
+	last, err := parseIPv4(to)
+	if err != nil {
+		return ipRange{}, err
+	}
+
+	if last < first {
+		return ipRange{}, fmt.Errorf("invalid IP range: %s", spec)
+	}
+
+	return ipRange{first: first, size: uint64(last-first) + 1}, nil
+}
+
+func parseIPv4(value string) (uint32, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(value))
+	if err != nil || !addr.Is4() {
+		return 0, fmt.Errorf("invalid IPv4 address: %s", value)
+	}
+
+	ip := addr.As4()
+	return binary.BigEndian.Uint32(ip[:]), nil
+}
+
+func parsePorts(spec string) ([]portRange, error) {
+	var ranges []portRange
+	for _, part := range strings.Split(spec, ",") {
+		from, to, ok := strings.Cut(part, "-")
+		if !ok {
+			to = from
+		}
+
+		first, err := parsePort(from)
+		if err != nil {
+			return nil, err
+		}
+
+		last, err := parsePort(to)
+		if err != nil {
+			return nil, err
+		}
+
+		if last < first {
+			return nil, fmt.Errorf("invalid port range: %s", part)
+		}
+
+		ranges = append(ranges, portRange{first: first, size: int(last-first) + 1})
+	}
+
+	return ranges, nil
+}
+
+func parsePort(value string) (uint32, error) {
+	port, err := strconv.ParseUint(strings.TrimSpace(value), 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("invalid port: %s", value)
+	}
+
+	return uint32(port), nil
+}
@@ -0,0 +1,233 @@
+package workload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/censys/scan-takehome/internal/serializer"
+	"github.com/censys/scan-takehome/pkg/scanning"
+)
+
+func TestNewGenerator(t *testing.T) {
+	t.Run("should return error if config is nil", func(t *testing.T) {
+		_, err := NewGenerator(nil)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	tests := []struct {
+		name string
+		cfg  *GeneratorConfig
+	}{
+		{"should return error for an IPv6 CIDR", &GeneratorConfig{IPs: "2001:db8::/64"}},
+		{"should return error for a reversed IP range", &GeneratorConfig{IPs: "10.0.0.9-10.0.0.1"}},
+		{"should return error for an invalid IP", &GeneratorConfig{IPs: "10.0.0"}},
+		{"should return error for port zero", &GeneratorConfig{Ports: "0-10"}},
+		{"should return error for a port out of range", &GeneratorConfig{Ports: "80,70000"}},
+		{"should return error for a reversed port range", &GeneratorConfig{Ports: "90-80"}},
+		{"should return error for a v2 ratio above one", &GeneratorConfig{V2Ratio: 1.5}},
+		{"should return error for a negative fault percent", &GeneratorConfig{Faults: FaultConfig{Duplicate: -1}}},
+		{"should return error for fault percents over 100", &GeneratorConfig{Faults: FaultConfig{Duplicate: 60, Truncated: 50}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGenerator(tt.cfg)
+			if err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	t.Run("should repeat the sequence for a seed", func(t *testing.T) {
+		cfg := &GeneratorConfig{Seed: 42, V2Ratio: 0.5}
+		a, _ := NewGenerator(cfg)
+		b, _ := NewGenerator(cfg)
+
+		for i := range 100 {
+			if x, y := a.Next(int64(i)), b.Next(int64(i)); !reflect.DeepEqual(x, y) {
+				t.Fatalf("expected scan %d to match, got %+v and %+v", i, x, y)
+			}
+		}
+	})
+
+	t.Run("should stay within the configured ranges", func(t *testing.T) {
+		g, err := NewGenerator(&GeneratorConfig{
+			IPs:      "10.0.0.254-10.0.1.1",
+			Ports:    "22, 8000-8002",
+			Services: []string{"SSH"},
+			Seed:     1,
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		ips := map[string]bool{}
+		ports := map[uint32]bool{}
+		for range 1000 {
+			scan := g.Next(100)
+			ips[scan.Ip] = true
+			ports[scan.Port] = true
+
+			if scan.Service != "SSH" || scan.Timestamp != 100 {
+				t.Fatalf("unexpected scan %+v", scan)
+			}
+		}
+
+		if len(ips) != 4 || !ips["10.0.0.254"] || !ips["10.0.1.1"] {
+			t.Errorf("expected the 4 IPs across the octet boundary, got %v", ips)
+		}
+
+		if len(ports) != 4 || !ports[22] || !ports[8000] || !ports[8002] {
+			t.Errorf("expected ports 22 and 8000-8002, got %v", ports)
+		}
+	})
+
+	t.Run("should pick IPs from a CIDR", func(t *testing.T) {
+		g, _ := NewGenerator(&GeneratorConfig{IPs: "192.168.7.9/30", Seed: 1})
+		prefix := netip.MustParsePrefix("192.168.7.8/30")
+
+		for range 100 {
+			if ip := netip.MustParseAddr(g.Next(0).Ip); !prefix.Contains(ip) {
+				t.Fatalf("expected an IP in %s, got %s", prefix, ip)
+			}
+		}
+	})
+
+	t.Run("should follow the v2 ratio", func(t *testing.T) {
+		for _, ratio := range []float64{0, 1} {
+			g, _ := NewGenerator(&GeneratorConfig{V2Ratio: ratio, Seed: 1})
+			expected := scanning.V1
+			if ratio == 1 {
+				expected = scanning.V2
+			}
+
+			for range 100 {
+				if scan := g.Next(0); scan.DataVersion != expected {
+					t.Fatalf("expected data version %d with ratio %v, got %d", expected, ratio, scan.DataVersion)
+				}
+			}
+		}
+	})
+}
+
+func TestNextMessage(t *testing.T) {
+	t.Run("should match Next without faults", func(t *testing.T) {
+		a, _ := NewGenerator(&GeneratorConfig{Seed: 42, V2Ratio: 0.5})
+		b, _ := NewGenerator(&GeneratorConfig{Seed: 42, V2Ratio: 0.5})
+
+		for i := range 100 {
+			msg, err := a.NextMessage(int64(i + 1))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			expected, _ := json.Marshal(b.Next(int64(i + 1)))
+			if msg.Fault != "" || !bytes.Equal(msg.Data, expected) {
+				t.Fatalf("expected message %d to be %s, got %s (%s)", i, expected, msg.Data, msg.Fault)
+			}
+		}
+	})
+
+	invalid := []struct {
+		fault string
+		cfg   FaultConfig
+	}{
+		{FaultUnknownVersion, FaultConfig{UnknownVersion: 100}},
+		{FaultNullData, FaultConfig{NullData: 100}},
+		{FaultTruncated, FaultConfig{Truncated: 100}},
+	}
+
+	for _, tt := range invalid {
+		t.Run("should make "+tt.fault+" messages invalid", func(t *testing.T) {
+			g, _ := NewGenerator(&GeneratorConfig{Seed: 1, Faults: tt.cfg})
+
+			for range 50 {
+				msg, _ := g.NextMessage(100)
+				if msg.Fault != tt.fault {
+					t.Fatalf("expected fault %s, got %q", tt.fault, msg.Fault)
+				}
+
+				if _, err := serializer.ParseScanMessage(msg.Data); !errors.Is(err, serializer.ErrInvalidMessage) {
+					t.Fatalf("expected ErrInvalidMessage for %s, got %v", msg.Data, err)
+				}
+			}
+		})
+	}
+
+	t.Run("should derive out of order faults from earlier scans", func(t *testing.T) {
+		g, _ := NewGenerator(&GeneratorConfig{
+			Seed:   1,
+			Faults: FaultConfig{Backdated: 20, Duplicate: 20, Conflict: 20},
+		})
+
+		latest := map[scanning.Scan]int64{}
+		sent := map[string]bool{}
+		counts := map[string]int{}
+
+		for i := range 1000 {
+			msg, err := g.NextMessage(int64(1000 + i))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			counts[msg.Fault]++
+
+			if msg.Fault == FaultDuplicate {
+				if !sent[string(msg.Data)] {
+					t.Fatalf("expected a duplicate of an earlier message, got %s", msg.Data)
+				}
+				continue
+			}
+
+			result, err := serializer.ParseScanMessage(msg.Data)
+			if err != nil {
+				t.Fatalf("expected a valid scan, got %v", err)
+			}
+
+			key := scanning.Scan{Ip: result.IP, Port: result.Port, Service: result.Service}
+			newest, seen := latest[key]
+
+			switch msg.Fault {
+			case FaultBackdated:
+				if !seen || result.Timestamp >= newest {
+					t.Fatalf("expected a backdated scan older than %d, got %+v", newest, result)
+				}
+			case FaultConflict:
+				if !seen || result.Timestamp > newest || !strings.HasPrefix(result.Response, "conflicting") {
+					t.Fatalf("expected a conflicting scan at an earlier timestamp, got %+v", result)
+				}
+			case "":
+				latest[key] = result.Timestamp
+				sent[string(msg.Data)] = true
+			}
+		}
+
+		for _, fault := range []string{"", FaultBackdated, FaultDuplicate, FaultConflict} {
+			if counts[fault] == 0 {
+				t.Errorf("expected some %q messages, got %v", fault, counts)
+			}
+		}
+	})
+
+	t.Run("should send oversize responses", func(t *testing.T) {
+		g, _ := NewGenerator(&GeneratorConfig{Seed: 1, Faults: FaultConfig{Oversize: 100, OversizeBytes: 1000}})
+
+		msg, _ := g.NextMessage(100)
+		result, err := serializer.ParseScanMessage(msg.Data)
+		if err != nil {
+			t.Fatalf("expected a valid scan, got %v", err)
+		}
+
+		if msg.Fault != FaultOversize || len(result.Response) != 1000 {
+			t.Errorf("expected a 1000 byte response, got %d (%s)", len(result.Response), msg.Fault)
+		}
+	})
+}
@@ -5,6 +5,9 @@ import (
 	"os"
 
 	"github.com/censys/scan-takehome/cmd/consumer"
+	"github.com/censys/scan-takehome/cmd/migrate"
+	"github.com/censys/scan-takehome/cmd/scanner"
+	"github.com/censys/scan-takehome/cmd/server"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +19,9 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(consumer.NewConsumerCmd())
+	rootCmd.AddCommand(server.NewServeCmd())
+	rootCmd.AddCommand(migrate.NewMigrateCmd())
+	rootCmd.AddCommand(scanner.NewScannerCmd())
 }
 
 func main() {
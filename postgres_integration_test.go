@@ -0,0 +1,199 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/censys/scan-takehome/internal/managers/scan_manager"
+	"github.com/censys/scan-takehome/internal/repositories/postgres"
+	"github.com/censys/scan-takehome/internal/repositories/repotest"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// setupPostgres creates an ephemeral Postgres container and returns a pool
+// to a migrated database
+func setupPostgres(t *testing.T) (*pgxpool.Pool, func()) {
+	ctx := context.Background()
+
+	req := testcontainers.ContainerRequest{
+		Image:        "postgres:16-alpine",
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_PASSWORD": "postgres",
+		},
+		// The server restarts once after initdb, so wait for the second start
+		WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to start Postgres container: %v", err)
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get container host: %v", err)
+	}
+
+	port, err := container.MappedPort(ctx, "5432")
+	if err != nil {
+		t.Fatalf("Failed to get container port: %v", err)
+	}
+
+	pool, err := pgxpool.New(ctx, fmt.Sprintf("postgres://postgres:postgres@%s:%s/postgres?sslmode=disable", host, port.Port()))
+	if err != nil {
+		t.Fatalf("Failed to create Postgres pool: %v", err)
+	}
+
+	migrator, err := postgres.NewMigrator(&postgres.MigratorConfig{Pool: pool})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	if _, err := migrator.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate schema: %v", err)
+	}
+
+	cleanup := func() {
+		pool.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("Failed to terminate container: %v", err)
+		}
+	}
+
+	return pool, cleanup
+}
+
+// truncatePostgres empties the scan tables between tests sharing a container
+func truncatePostgres(t *testing.T, pool *pgxpool.Pool) {
+	if _, err := pool.Exec(context.Background(), `TRUNCATE scans, scan_history`); err != nil {
+		t.Fatalf("Failed to truncate tables: %v", err)
+	}
+}
+
+func TestIntegration_PostgresConformance(t *testing.T) {
+	pool, cleanup := setupPostgres(t)
+	defer cleanup()
+
+	repotest.Run(t, func(t *testing.T) scan_manager.Repository {
+		truncatePostgres(t, pool)
+
+		store, err := postgres.NewPostgres(&postgres.PostgresConfig{Pool: pool, History: true, RecordStale: true})
+		if err != nil {
+			t.Fatalf("Failed to create Postgres store: %v", err)
+		}
+
+		return store
+	})
+}
+
+func TestIntegration_PostgresConcurrentWriters(t *testing.T) {
+	pool, cleanup := setupPostgres(t)
+	defer cleanup()
+
+	store, err := postgres.NewPostgres(&postgres.PostgresConfig{Pool: pool, History: true})
+	if err != nil {
+		t.Fatalf("Failed to create Postgres store: %v", err)
+	}
+
+	// Every writer races to insert the key first, then to replace it
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	inserted := 0
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+		go func(ts int64) {
+			defer wg.Done()
+
+			put, err := store.Put(context.Background(), &scan_manager.ScanResult{
+				IP: "10.0.0.1", Port: 22, Service: "SSH", Timestamp: ts, Response: fmt.Sprintf("response %d", ts),
+			})
+			if err != nil {
+				t.Errorf("Failed to put scan %d: %v", ts, err)
+				return
+			}
+
+			if put.Outcome == scan_manager.Inserted {
+				mu.Lock()
+				inserted++
+				mu.Unlock()
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	if inserted != 1 {
+		t.Errorf("Expected exactly one insert, got %d", inserted)
+	}
+
+	key := scan_manager.ScanKey{IP: "10.0.0.1", Port: 22, Service: "SSH"}
+	stored, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Failed to get scan: %v", err)
+	}
+
+	if stored.Timestamp != 50 {
+		t.Errorf("Expected the newest scan to win, got timestamp %d", stored.Timestamp)
+	}
+
+	entries, err := store.History(context.Background(), key, 0, 100)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+
+	if len(entries) == 0 || entries[len(entries)-1].Timestamp != 50 {
+		t.Errorf("Expected history to end with the stored scan, got %+v", entries)
+	}
+}
+
+func TestIntegration_PostgresMigrate(t *testing.T) {
+	pool, cleanup := setupPostgres(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	migrator, err := postgres.NewMigrator(&postgres.MigratorConfig{Pool: pool})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	// setupPostgres already migrated, so the schema is current
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+
+	latest := postgres.Migrations()[len(postgres.Migrations())-1].Version
+	if version != latest {
+		t.Errorf("Expected schema version %d, got %d", latest, version)
+	}
+
+	// Concurrent re-runs are no-ops
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			applied, err := migrator.Migrate(ctx)
+			if err != nil {
+				t.Errorf("Failed to re-run migrations: %v", err)
+			}
+
+			if len(applied) != 0 {
+				t.Errorf("Expected no migrations to be applied, got %d", len(applied))
+			}
+		}()
+	}
+	wg.Wait()
+}